@@ -0,0 +1,84 @@
+//go:build cgo && mikmod_actor
+
+package mikmod
+
+// With the mikmod_actor build tag, every libmikmod call is made from a
+// single goroutine locked to its own OS thread, for libmikmod builds
+// compiled without thread support.  Callers still block until their
+// call completes, so the API behaves as in other builds.  Only calls
+// are funneled: libmikmod's global settings, such as md_volume, are
+// still read and written directly.  The native update thread is not
+// available, and the functions passed to SetTap run on the actor.
+
+/*
+static __thread int on_actor;
+
+static void set_on_actor(void) { on_actor = 1; }
+static int is_on_actor(void) { return on_actor; }
+*/
+import "C"
+
+import (
+	"runtime"
+	"sync"
+)
+
+// actorBuild is true if libmikmod calls go through the actor.
+const actorBuild = true
+
+var (
+	actorOnce  sync.Once
+	actorCalls chan func()
+)
+
+// startActor starts the goroutine making all libmikmod calls.
+func startActor() {
+	actorCalls = make(chan func())
+	ready := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		C.set_on_actor()
+		close(ready)
+		for fn := range actorCalls {
+			fn()
+		}
+	}()
+	<-ready
+}
+
+// do calls fn on the actor and waits for it to return.  Calls made on
+// the actor itself, e.g. from driver callbacks, run right away.  fn
+// must not wait for other goroutines that may call do.
+func do(fn func()) { doUntil(nil, fn) }
+
+// doUntil is like do, but gives up and returns false if cancel is
+// closed before the actor picks up fn.
+func doUntil(cancel <-chan struct{}, fn func()) bool {
+	select {
+	case <-cancel:
+		return false
+	default:
+	}
+	actorOnce.Do(startActor)
+	if C.is_on_actor() != 0 {
+		fn()
+		return true
+	}
+	var p any
+	done := make(chan struct{})
+	call := func() {
+		defer close(done)
+		defer func() { p = recover() }()
+		fn()
+	}
+	select {
+	case actorCalls <- call:
+	case <-cancel:
+		return false
+	}
+	<-done
+	if p != nil {
+		panic(p)
+	}
+	return true
+}
@@ -0,0 +1,23 @@
+//go:build cgo && !mikmod_actor
+
+package mikmod
+
+// actorBuild is true if libmikmod calls go through the actor; see
+// actor.go.
+const actorBuild = false
+
+// do calls fn.  With the mikmod_actor build tag, it calls fn on the
+// goroutine making all libmikmod calls instead.
+func do(fn func()) { fn() }
+
+// doUntil is like do, but returns false without calling fn if cancel
+// is closed.
+func doUntil(cancel <-chan struct{}, fn func()) bool {
+	select {
+	case <-cancel:
+		return false
+	default:
+	}
+	fn()
+	return true
+}
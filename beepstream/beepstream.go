@@ -0,0 +1,101 @@
+// Package beepstream exposes MikMod modules as beep streamers, so that
+// they can be mixed, resampled and controlled alongside other beep
+// sources.
+//
+// Streamers run at MikMod's output sample rate; resample them with
+// beep.Resample to mix them with sources at other rates.  See the
+// mikmod package documentation on using other audio libraries.
+package beepstream
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/death/go-mikmod"
+	"github.com/gopxl/beep/v2"
+)
+
+// Streamer is a beep.StreamCloser playing a module.
+type Streamer struct {
+	stream *mikmod.Stream
+	format mikmod.Format
+	buf    []byte
+	err    error
+}
+
+// New starts playing a module as a beep streamer, returning it along
+// with its format.  Any module currently playing is stopped.  Unless
+// loop is true, looping is disabled and the streamer drains when the
+// song ends.
+func New(m *mikmod.Module, loop bool) (*Streamer, beep.Format, error) {
+	stream, err := mikmod.NewStream(m, mikmod.StreamOptions{Loop: loop})
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	f := stream.Format()
+	s := &Streamer{stream: stream, format: f}
+	return s, beep.Format{
+		SampleRate:  beep.SampleRate(f.SampleRate),
+		NumChannels: f.Channels,
+		Precision:   f.BitsPerSample / 8,
+	}, nil
+}
+
+// Stream fills samples with the module's output, converted to stereo
+// floats.
+func (s *Streamer) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.err != nil {
+		return 0, false
+	}
+	frameSize := s.format.FrameSize()
+	if need := len(samples) * frameSize; cap(s.buf) < need {
+		s.buf = make([]byte, need)
+	}
+	for n < len(samples) {
+		k, err := s.stream.Read(s.buf[:(len(samples)-n)*frameSize])
+		for i := 0; i < k/frameSize; i++ {
+			samples[n+i] = s.frame(s.buf[i*frameSize:])
+		}
+		n += k / frameSize
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.err = err
+			break
+		}
+	}
+	return n, n > 0
+}
+
+// Err returns the error that stopped the streamer, if any.
+func (s *Streamer) Err() error { return s.err }
+
+// Close stops the module.
+func (s *Streamer) Close() error { return s.stream.Close() }
+
+// frame decodes the sample frame at the start of b.
+func (s *Streamer) frame(b []byte) [2]float64 {
+	var f [2]float64
+	size := s.format.BitsPerSample / 8
+	for c := 0; c < s.format.Channels; c++ {
+		f[c] = sample(b[c*size:], s.format.BitsPerSample)
+	}
+	if s.format.Channels == 1 {
+		f[1] = f[0]
+	}
+	return f
+}
+
+// sample decodes a sample of the given size from the start of b.
+func sample(b []byte, bits int) float64 {
+	switch bits {
+	case 8:
+		return (float64(b[0]) - 128) / 128
+	case 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+	default:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+}
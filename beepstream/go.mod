@@ -0,0 +1,12 @@
+module github.com/death/go-mikmod/beepstream
+
+go 1.24
+
+require (
+	github.com/death/go-mikmod v0.0.0
+	github.com/gopxl/beep/v2 v2.1.1
+)
+
+require github.com/pkg/errors v0.9.1 // indirect
+
+replace github.com/death/go-mikmod => ../
@@ -0,0 +1,104 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache keeps recently used modules loaded, so that switching back and
+// forth between them does not require reloading.  When the cache is
+// full, the least recently used module is evicted and closed, unless
+// it is playing.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// cacheEntry is a module held by a cache.
+type cacheEntry struct {
+	filename string
+	module   *Module
+}
+
+// NewCache returns a cache holding up to size modules.
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the module loaded from the file designated by filename,
+// loading it if it isn't cached.  The module belongs to the cache and
+// must not be closed by the caller.
+func (c *Cache) Get(filename string) (*Module, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[filename]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cacheEntry).module, nil
+	}
+
+	m, err := LoadModuleFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c.entries[filename] = c.lru.PushFront(&cacheEntry{filename, m})
+	c.evict()
+	return m, nil
+}
+
+// Len returns the number of modules in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Remove evicts the module loaded from filename, if it is cached,
+// closing it.
+func (c *Cache) Remove(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[filename]; ok {
+		c.remove(e)
+	}
+}
+
+// Close evicts and closes all the modules in the cache.  Modules must
+// not be playing when the cache is closed.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.lru.Front(); e != nil; e = c.lru.Front() {
+		c.remove(e)
+	}
+	return nil
+}
+
+// evict removes least recently used modules until the cache is within
+// its size, skipping modules that are playing.  It expects c.mu to be
+// locked.
+func (c *Cache) evict() {
+	for e := c.lru.Back(); e != nil && c.lru.Len() > c.size; {
+		prev := e.Prev()
+		if e.Value.(*cacheEntry).module != playing.Load() {
+			c.remove(e)
+		}
+		e = prev
+	}
+}
+
+// remove removes an element from the cache, closing its module.  It
+// expects c.mu to be locked.
+func (c *Cache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*cacheEntry)
+	delete(c.entries, entry.filename)
+	entry.module.Close()
+}
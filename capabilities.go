@@ -0,0 +1,69 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "fmt"
+
+// DriverCapabilities describes the output driver MikMod was
+// initialized with: what it supports, and what it settled on compared
+// to what was requested, e.g. to adapt a settings dialog or warn about
+// a downgraded output.
+type DriverCapabilities struct {
+	// Name and Alias are the driver's name and short name.
+	Name  string
+	Alias string
+
+	// HardwareMixing is true if the driver mixes voices in hardware,
+	// and MaxHardwareVoices and MaxSoftwareVoices are the largest
+	// number of voices it mixes in hardware and in software.
+	HardwareMixing    bool
+	MaxHardwareVoices int
+	MaxSoftwareVoices int
+
+	// Format is the output format in effect, and Requested the one
+	// the options passed to Init asked for.
+	Format    Format
+	Requested Format
+}
+
+// Capabilities returns the capabilities of the output driver.
+func Capabilities() (DriverCapabilities, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return DriverCapabilities{}, ErrNotInitialized
+	}
+	var c DriverCapabilities
+	do(func() {
+		d := &Driver{driver: C.md_driver}
+		c = DriverCapabilities{
+			Name:              d.Name(),
+			Alias:             d.Alias(),
+			HardwareMixing:    C.md_driver.HardVoiceLimit > 0,
+			MaxHardwareVoices: int(C.md_driver.HardVoiceLimit),
+			MaxSoftwareVoices: int(C.md_driver.SoftVoiceLimit),
+			Format:            currentFormat(),
+		}
+	})
+	c.Requested = options.format()
+	return c, nil
+}
+
+// Downgrades describes how the output format in effect falls short of
+// the requested one, if it does.
+func (c DriverCapabilities) Downgrades() []string {
+	var d []string
+	if c.Format.SampleRate < c.Requested.SampleRate {
+		d = append(d, fmt.Sprintf("mixing at %d Hz instead of %d Hz", c.Format.SampleRate, c.Requested.SampleRate))
+	}
+	if c.Format.Channels < c.Requested.Channels {
+		d = append(d, "mono output instead of stereo")
+	}
+	if c.Format.BitsPerSample < c.Requested.BitsPerSample {
+		d = append(d, fmt.Sprintf("%d-bit output instead of %d-bit", c.Format.BitsPerSample, c.Requested.BitsPerSample))
+	}
+	return d
+}
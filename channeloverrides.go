@@ -0,0 +1,281 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+extern void muteInstrumentVoices(void);
+extern void checkJumps(void);
+extern void recordClockRow(void);
+
+static MikMod_player_t prevPlayer;
+static int playerRegistered;
+
+// ticksPlayed counts the ticks played, for Stats.
+static ULONG ticksPlayed;
+
+static ULONG getTicksPlayed(void) { return ticksPlayed; }
+
+// Channel overrides: volume scales, out of 256, and pannings, from
+// PAN_LEFT to PAN_RIGHT, along with the voices playing the channels.
+static UWORD channelScale[UF_MAXCHAN];
+static UBYTE channelScaled[UF_MAXCHAN];
+static UBYTE channelPan[UF_MAXCHAN];
+static UBYTE channelPanned[UF_MAXCHAN];
+static SBYTE channelVoice[UF_MAXCHAN];
+
+// Channel reservations, as set by ReserveChannel, and how many
+// channels are reserved and duck-able.  Once there are any, ducking
+// applies per channel, scaling their volume by duckScale, out of 256.
+enum { channelNormal, channelReserved, channelDuckable };
+static UBYTE channelReservation[UF_MAXCHAN];
+static int numReserved, numDuckable;
+static UWORD duckScale = 256;
+
+// channelDucked returns true if ducking applies to channel ch: the
+// duck-able channels if there are any, and all but the reserved ones
+// otherwise.
+static int channelDucked(int ch) {
+	if (numDuckable)
+		return channelReservation[ch] == channelDuckable;
+	return channelReservation[ch] != channelReserved;
+}
+
+// Layer scales, out of 256, as set by applyLayers.
+static UWORD channelLayer[UF_MAXCHAN];
+static UBYTE channelLayered[UF_MAXCHAN];
+
+// channelTracked returns true if the voice of channel ch is tracked,
+// as some override applies to it, including ducking once it applies
+// per channel.
+static int channelTracked(int ch) {
+	return channelScaled[ch] || channelPanned[ch] || channelReservation[ch] || channelLayered[ch] ||
+		((numReserved || numDuckable) && channelDucked(ch));
+}
+
+// overridingPlayer runs the player for a tick, then checks the jump
+// policy, records the row for the clocks, and applies the channel
+// overrides to the voices of the channels, and the instrument mutes.
+// The player sets the voices' volume and panning on every tick, so the
+// overrides stick whatever the module's effects do.
+static void overridingPlayer(void) {
+	int ch;
+	ticksPlayed++;
+	if (prevPlayer)
+		prevPlayer();
+	checkJumps();
+	recordClockRow();
+	for (ch = 0; ch < UF_MAXCHAN; ch++) {
+		SBYTE voice = channelVoice[ch];
+		ULONG scale = channelScaled[ch] ? channelScale[ch] : 256;
+		if (voice < 0)
+			continue;
+		if (duckScale != 256 && channelDucked(ch))
+			scale = scale * duckScale / 256;
+		if (channelLayered[ch] && channelReservation[ch] != channelReserved)
+			scale = scale * channelLayer[ch] / 256;
+		if (scale != 256)
+			md_driver->VoiceSetVolume(voice,
+				(ULONG)md_driver->VoiceGetVolume(voice) * scale / 256);
+		if (channelPanned[ch])
+			md_driver->VoiceSetPanning(voice,
+				md_mode & DMODE_REVERSE ? PAN_RIGHT - channelPan[ch] : channelPan[ch]);
+	}
+	if (numReserved) {
+		// Reserved channels are exempt from instrument mutes.
+		UWORD volumes[UF_MAXCHAN];
+		for (ch = 0; ch < UF_MAXCHAN; ch++)
+			if (channelReservation[ch] == channelReserved && channelVoice[ch] >= 0)
+				volumes[ch] = md_driver->VoiceGetVolume(channelVoice[ch]);
+		muteInstrumentVoices();
+		for (ch = 0; ch < UF_MAXCHAN; ch++)
+			if (channelReservation[ch] == channelReserved && channelVoice[ch] >= 0)
+				md_driver->VoiceSetVolume(channelVoice[ch], volumes[ch]);
+		return;
+	}
+	muteInstrumentVoices();
+}
+
+static void registerPlayer(void) {
+	if (!playerRegistered) {
+		prevPlayer = MikMod_RegisterPlayer(overridingPlayer);
+		playerRegistered = 1;
+	}
+}
+
+// forgetVoice clears the voice of channel ch if it is not overridden,
+// until updateChannelVoices finds the right one.  It expects MikMod to
+// be locked.
+static void forgetVoice(int ch) {
+	if (!channelTracked(ch))
+		channelVoice[ch] = -1;
+}
+
+static void setChannelScale(int ch, int scale) {
+	registerPlayer();
+	MikMod_Lock();
+	forgetVoice(ch);
+	channelScale[ch] = scale;
+	channelScaled[ch] = scale != 256;
+	MikMod_Unlock();
+}
+
+static int channelScaleOf(int ch) {
+	return channelScaled[ch] ? channelScale[ch] : 256;
+}
+
+static void setChannelPan(int ch, int pan, int on) {
+	registerPlayer();
+	MikMod_Lock();
+	forgetVoice(ch);
+	channelPan[ch] = pan;
+	channelPanned[ch] = on;
+	MikMod_Unlock();
+}
+
+static int channelPanOf(int ch) {
+	return channelPanned[ch] ? channelPan[ch] : -1;
+}
+
+// setChannelReservation sets the reservation of channel ch.  It is
+// declared in reserve.go.
+void setChannelReservation(int ch, int r) {
+	registerPlayer();
+	MikMod_Lock();
+	forgetVoice(ch);
+	numReserved -= channelReservation[ch] == channelReserved;
+	numDuckable -= channelReservation[ch] == channelDuckable;
+	channelReservation[ch] = r;
+	numReserved += r == channelReserved;
+	numDuckable += r == channelDuckable;
+	MikMod_Unlock();
+}
+
+int channelReservationOf(int ch) { return channelReservation[ch]; }
+
+// setChannelLayers sets the layer scales of all channels.  It is
+// declared in layer.go.
+void setChannelLayers(const UWORD *scales) {
+	int ch;
+	registerPlayer();
+	MikMod_Lock();
+	for (ch = 0; ch < UF_MAXCHAN; ch++) {
+		forgetVoice(ch);
+		channelLayer[ch] = scales[ch];
+		channelLayered[ch] = scales[ch] != 256;
+	}
+	MikMod_Unlock();
+}
+
+// duckingPerChannel returns true if ducking applies per channel rather
+// than to the music volume.
+static int duckingPerChannel(void) { return numReserved || numDuckable; }
+
+static void setDuckScale(int scale) { duckScale = scale; }
+
+// updateChannelVoices records the voices playing the overridden
+// channels, as the player cannot be queried for them while it runs.
+static void updateChannelVoices(void) {
+	int ch;
+	SBYTE voices[UF_MAXCHAN];
+	for (ch = 0; ch < UF_MAXCHAN; ch++)
+		voices[ch] = channelTracked(ch) ? Player_GetChannelVoice(ch) : -1;
+	MikMod_Lock();
+	for (ch = 0; ch < UF_MAXCHAN; ch++)
+		channelVoice[ch] = voices[ch];
+	MikMod_Unlock();
+}
+*/
+import "C"
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// channelsOverridden is true once a channel override was set, so that
+// the update loop only tracks voices when needed.
+var channelsOverridden atomic.Bool
+
+// SetChannelVolume scales the volume of channel ch, between 0 and 1,
+// e.g. for a fader in a mixer UI.  Unlike MuteChannel, the setting is
+// not part of the module: it applies to channel ch of whichever module
+// plays, until it is set back to 1.
+func SetChannelVolume(ch int, v float64) {
+	if ch < 0 || ch >= C.UF_MAXCHAN {
+		return
+	}
+	channelsOverridden.Store(true)
+	do(func() { C.setChannelScale(C.int(ch), C.int(clamp(v, 0, 1)*256+0.5)) })
+}
+
+// ChannelVolume returns the volume of channel ch set by
+// SetChannelVolume.
+func ChannelVolume(ch int) float64 {
+	if ch < 0 || ch >= C.UF_MAXCHAN {
+		return 1
+	}
+	return call(func() float64 { return float64(C.channelScaleOf(C.int(ch))) / 256 })
+}
+
+// SetChannelPan forces the stereo position of channel ch, from -1
+// for left to 1 for right, overriding the module's panning and its
+// panning effects until ClearChannelPan is called.  Like
+// SetChannelVolume, it applies to whichever module plays.
+func SetChannelPan(ch int, pan float64) {
+	if ch < 0 || ch >= C.UF_MAXCHAN {
+		return
+	}
+	p := math.Round((clamp(pan, -1, 1) + 1) / 2 * C.PAN_RIGHT)
+	channelsOverridden.Store(true)
+	do(func() { C.setChannelPan(C.int(ch), C.int(p), 1) })
+}
+
+// ClearChannelPan lets the module control the stereo position of
+// channel ch again.
+func ClearChannelPan(ch int) {
+	if ch < 0 || ch >= C.UF_MAXCHAN {
+		return
+	}
+	do(func() { C.setChannelPan(C.int(ch), 0, 0) })
+}
+
+// ChannelPan returns the stereo position of channel ch set by
+// SetChannelPan, and false if it is not overridden.
+func ChannelPan(ch int) (float64, bool) {
+	if ch < 0 || ch >= C.UF_MAXCHAN {
+		return 0, false
+	}
+	p := call(func() int { return int(C.channelPanOf(C.int(ch))) })
+	if p < 0 {
+		return 0, false
+	}
+	return float64(p)/C.PAN_RIGHT*2 - 1, true
+}
+
+// setChannelDuck sets the gain applied by ducking to the channels it
+// applies to, and returns false if it does not apply per channel, in
+// which case the gain is to be applied to the music volume.
+func setChannelDuck(g float64) bool {
+	if C.duckingPerChannel() == 0 {
+		C.setDuckScale(256)
+		return false
+	}
+	C.setDuckScale(C.int(clamp(g, 0, 1)*256 + 0.5))
+	return true
+}
+
+// overridePlayer makes overridingPlayer run on every tick.  It expects
+// to be called on the actor.
+func overridePlayer() { C.registerPlayer() }
+
+// playerTicks returns the number of ticks played since the player was
+// hooked by overridePlayer, wrapping around at 2^32.
+func playerTicks() uint32 { return uint32(C.getTicksPlayed()) }
+
+// updateChannelOverrides keeps track of the voices of overridden
+// channels.  It is called by the update loop.
+func updateChannelOverrides() {
+	if channelsOverridden.Load() {
+		C.updateChannelVoices()
+	}
+}
@@ -0,0 +1,103 @@
+package mikmod
+
+import (
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Charset is a character set that module text, such as titles,
+// comments and instrument names, may be encoded in.
+type Charset int
+
+const (
+	// CharsetAuto keeps text that is valid UTF-8, and otherwise
+	// guesses the character set from the tracker: Amiga trackers
+	// use CharsetAmiga and the others CharsetCP437.
+	CharsetAuto Charset = iota
+
+	// CharsetUTF8 keeps text as is, replacing invalid sequences
+	// with U+FFFD.
+	CharsetUTF8
+
+	// CharsetCP437 is the IBM PC code page 437 used by DOS trackers
+	// such as Scream Tracker, FastTracker and Impulse Tracker.
+	CharsetCP437
+
+	// CharsetAmiga is the ISO 8859-1 character set used by Amiga
+	// trackers such as ProTracker.
+	CharsetAmiga
+)
+
+// textCharset holds the Charset set by SetCharset.
+var textCharset atomic.Int32
+
+// SetCharset sets the character set that the text of modules loaded
+// afterwards is decoded from.  The default is CharsetAuto.
+func SetCharset(c Charset) { textCharset.Store(int32(c)) }
+
+// CurrentCharset returns the character set set by SetCharset.
+func CurrentCharset() Charset { return Charset(textCharset.Load()) }
+
+// amigaTrackers are prefixes of the tracker names of Amiga trackers,
+// as reported by libmikmod's loaders.
+var amigaTrackers = []string{
+	"protracker", "noisetracker", "soundtracker", "startrekker",
+	"ultimate soundtracker", "his master's noise", "oktalyzer",
+	"med", "octamed",
+}
+
+// charsetFor resolves CharsetAuto for text b of a module made with
+// tracker.
+func charsetFor(c Charset, b []byte, tracker string) Charset {
+	if c != CharsetAuto {
+		return c
+	}
+	if utf8.Valid(b) {
+		return CharsetUTF8
+	}
+	tracker = strings.ToLower(tracker)
+	for _, prefix := range amigaTrackers {
+		if strings.HasPrefix(tracker, prefix) {
+			return CharsetAmiga
+		}
+	}
+	return CharsetCP437
+}
+
+// decodeText decodes text b of a module made with tracker to UTF-8.
+func decodeText(b []byte, c Charset, tracker string) string {
+	switch charsetFor(c, b, tracker) {
+	case CharsetCP437:
+		return decodeBytes(b, func(c byte) rune { return cp437[c-0x80] })
+	case CharsetAmiga:
+		return decodeBytes(b, func(c byte) rune { return rune(c) })
+	}
+	return strings.ToValidUTF8(string(b), "\uFFFD")
+}
+
+// decodeBytes decodes a single-byte character set, in which bytes
+// below 0x80 are ASCII and the others are mapped by high.
+func decodeBytes(b []byte, high func(c byte) rune) string {
+	var s strings.Builder
+	s.Grow(len(b))
+	for _, c := range b {
+		if c < 0x80 {
+			s.WriteByte(c)
+		} else {
+			s.WriteRune(high(c))
+		}
+	}
+	return s.String()
+}
+
+// cp437 maps bytes 0x80 to 0xFF of code page 437 to runes.
+var cp437 = []rune("" +
+	"ÇüéâäàåçêëèïîìÄÅ" +
+	"ÉæÆôöòûùÿÖÜ¢£¥₧ƒ" +
+	"áíóúñÑªº¿⌐¬½¼¡«»" +
+	"░▒▓│┤╡╢╖╕╣║╗╝╜╛┐" +
+	"└┴┬├─┼╞╟╚╔╩╦╠═╬╧" +
+	"╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀" +
+	"αßΓπΣσµτΦΘΩδ∞φε∩" +
+	"≡±≥≤⌠⌡÷≈°∙·√ⁿ²■ ")
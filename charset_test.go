@@ -0,0 +1,29 @@
+package mikmod
+
+import "testing"
+
+func TestDecodeText(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		in      string
+		charset Charset
+		tracker string
+		want    string
+	}{
+		{"ascii", "Hello", CharsetAuto, "Scream Tracker 3.21", "Hello"},
+		{"utf8", "Café", CharsetAuto, "Scream Tracker 3.21", "Café"},
+		{"cp437", "\x80a\xe1\xdb", CharsetAuto, "Scream Tracker 3.21", "Çaß█"},
+		{"amiga", "\xa9 1991 \xe9t\xe9", CharsetAuto, "Protracker", "© 1991 été"},
+		{"amiga case", "\xe9", CharsetAuto, "OctaMED SoundStudio", "é"},
+		{"unknown tracker", "\xe9", CharsetAuto, "", "Θ"},
+		{"explicit cp437", "\xe9", CharsetCP437, "Protracker", "Θ"},
+		{"explicit amiga", "\x80", CharsetAmiga, "Impulse Tracker", "\u0080"},
+		{"explicit utf8", "a\xe9b", CharsetUTF8, "Protracker", "a�b"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeText([]byte(tt.in), tt.charset, tt.tracker); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
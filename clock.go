@@ -0,0 +1,264 @@
+//go:build cgo
+
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// clockRow is a row reached by the module the clocks follow, with the
+// speed, tempo and playing time it started with.
+typedef struct {
+	int pos, row, speed, tempo;
+	ULONG sngtime;
+} clockRow;
+
+// The module the clocks follow, the last one started, which the player
+// hook only sees while it plays, the last row it reached, and the rows
+// queued for updateClocks.
+enum { clockQueueSize = 256 };
+static MODULE *clockModule;
+static int clockPos, clockRowIndex;
+static clockRow clockQueue[clockQueueSize];
+static int clockQueued;
+
+static void watchClock(MODULE *mod) {
+	MikMod_Lock();
+	clockModule = mod;
+	clockPos = clockRowIndex = -1;
+	clockQueued = 0;
+	MikMod_Unlock();
+}
+
+// unwatchClock stops following mod, which is being freed, dropping the
+// rows queued for it.
+static void unwatchClock(MODULE *mod) {
+	MikMod_Lock();
+	if (clockModule == mod) {
+		clockModule = NULL;
+		clockQueued = 0;
+	}
+	MikMod_Unlock();
+}
+
+// recordClockRow queues the row the module reached, if it is a new
+// one, so that clocks see every row, however many ticks an update
+// mixes.  Rows are dropped while the queue is full.  It is called by
+// overridingPlayer, in channeloverrides.go.
+void recordClockRow(void) {
+	MODULE *mod = clockModule;
+	clockRow *r;
+	if (mod == NULL || mod->sngpos >= mod->numpos)
+		return;
+	if (mod->sngpos == clockPos && mod->patpos == clockRowIndex)
+		return;
+	clockPos = mod->sngpos;
+	clockRowIndex = mod->patpos;
+	if (clockQueued == clockQueueSize)
+		return;
+	r = &clockQueue[clockQueued++];
+	r->pos = mod->sngpos;
+	r->row = mod->patpos;
+	r->speed = mod->sngspd;
+	r->tempo = mod->bpm;
+	r->sngtime = mod->sngtime;
+}
+
+// takeClockRows moves the rows queued to rows, which has room for
+// clockQueueSize of them, and returns how many there were.
+static int takeClockRows(clockRow *rows) {
+	int i, n;
+	MikMod_Lock();
+	n = clockQueued;
+	for (i = 0; i < n; i++)
+		rows[i] = clockQueue[i];
+	clockQueued = 0;
+	MikMod_Unlock();
+	return n;
+}
+*/
+import "C"
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// clockBuffer is the number of events buffered by a clock.  Events
+// are dropped rather than stalling playback when the buffer is full.
+const clockBuffer = 64
+
+// ClockEvent reports that the playing module reached a new row.
+type ClockEvent struct {
+	// Position and Row are the song position and row reached.
+	Position int
+	Row      int
+
+	// Beat is true if the row starts a beat, and Beats the number
+	// of beats since the clock started.
+	Beat  bool
+	Beats int
+
+	// Speed and Tempo are the speed and tempo in effect, from which
+	// RowDuration computes the time until the next row.
+	Speed int
+	Tempo int
+
+	// Elapsed is the playing time of the module when the row
+	// started.
+	Elapsed time.Duration
+
+	// Session is the session of the module playing the row.
+	Session Session
+}
+
+// RowDuration returns the duration of a row at the speed and tempo of
+// the event.
+func (e ClockEvent) RowDuration() time.Duration { return rowDuration(e.Speed, e.Tempo) }
+
+// Clock follows the playing module in musical time, reporting each row
+// and beat as the update loop mixes it, so that game logic can
+// schedule events "on the next beat".  Note that the audio is heard
+// later, by the output latency; see OutputLatency.
+type Clock struct {
+	// C delivers an event for each row.
+	C <-chan ClockEvent
+
+	c           chan ClockEvent
+	rowsPerBeat int
+	last        *Module
+	pos, row    int
+	beats       int
+	mu          sync.Mutex
+	waiters     []chan struct{}
+	stopped     bool
+}
+
+var (
+	clocksMu sync.Mutex
+	clocks   []*Clock
+)
+
+// NewClock returns a clock counting a beat every rowsPerBeat rows,
+// usually 4.  Call Stop when done with it.
+func NewClock(rowsPerBeat int) *Clock {
+	c := &Clock{
+		c:           make(chan ClockEvent, clockBuffer),
+		rowsPerBeat: max(rowsPerBeat, 1),
+		pos:         -1,
+		row:         -1,
+	}
+	c.C = c.c
+	clocksMu.Lock()
+	clocks = append(clocks, c)
+	clocksMu.Unlock()
+	return c
+}
+
+// Stop stops the clock.  No more events are delivered.
+func (c *Clock) Stop() {
+	clocksMu.Lock()
+	clocks = slices.DeleteFunc(clocks, func(other *Clock) bool { return other == c })
+	clocksMu.Unlock()
+	c.mu.Lock()
+	for _, w := range c.waiters {
+		close(w)
+	}
+	c.waiters, c.stopped = nil, true
+	c.mu.Unlock()
+}
+
+// NextBeat returns a channel that is closed when the next beat starts,
+// or when the clock is stopped.
+func (c *Clock) NextBeat() <-chan struct{} {
+	w := make(chan struct{})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		close(w)
+		return w
+	}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// RowDuration returns the duration of a row of the playing module at
+// its current speed and tempo, or 0 if no module is playing.
+func RowDuration() time.Duration {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return 0
+	}
+	return rowDuration(m.Speed(), m.Tempo())
+}
+
+// rowDuration returns the duration of a row: speed ticks at a rate of
+// tempo*2/5 ticks per second.
+func rowDuration(speed int, tempo int) time.Duration {
+	if tempo == 0 {
+		return 0
+	}
+	return time.Duration(speed) * 5 * time.Second / time.Duration(2*tempo)
+}
+
+// watchClock makes the clocks follow module m, which is starting.  It
+// expects to be called on the actor.
+func watchClock(m *Module) { C.watchClock(m.module) }
+
+// unwatchClock stops the clocks from following module m, which is
+// being freed.  It expects to be called on the actor.
+func unwatchClock(m *Module) { C.unwatchClock(m.module) }
+
+// updateClocks reports the rows reached by the playing module since
+// the last update to the clocks, as recorded by the player hook.  It
+// is called by the update loop after each update.
+func updateClocks() {
+	var rows [C.clockQueueSize]C.clockRow
+	n := int(C.takeClockRows(&rows[0]))
+	clocksMu.Lock()
+	defer clocksMu.Unlock()
+	m := playingModule()
+	if len(clocks) == 0 || m == nil {
+		return
+	}
+	for _, r := range rows[:n] {
+		e := ClockEvent{
+			Position: int(r.pos),
+			Row:      int(r.row),
+			Speed:    int(r.speed),
+			Tempo:    int(r.tempo),
+			Elapsed:  time.Duration(r.sngtime*1000/1024) * time.Millisecond,
+			Session:  Session(updateSession.Load()),
+		}
+		for _, c := range clocks {
+			c.observe(m, e)
+		}
+	}
+}
+
+// observe delivers event e if it reports a new row of module m.
+func (c *Clock) observe(m *Module, e ClockEvent) {
+	if m == c.last && e.Position == c.pos && e.Row == c.row {
+		return
+	}
+	c.last, c.pos, c.row = m, e.Position, e.Row
+	e.Beat = e.Row%c.rowsPerBeat == 0
+	if e.Beat {
+		c.beats++
+	}
+	e.Beats = c.beats
+	select {
+	case c.c <- e:
+	default:
+	}
+	if e.Beat {
+		c.mu.Lock()
+		for _, w := range c.waiters {
+			close(w)
+		}
+		c.waiters = nil
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,40 @@
+//go:build cgo
+
+package mikmod
+
+// Clone loads another copy of the module, independent of m, e.g. to
+// analyze one while the other is queued for playback.  A module loaded
+// from memory is parsed again from the data it was loaded from, as
+// translated, while one loaded from a file is read again, through the
+// translators if any.  The clone has the same gain and looping
+// settings as m.  The caller is responsible for closing it.
+func (m *Module) Clone() (*Module, error) {
+	if err := m.usable(); err != nil {
+		return nil, err
+	}
+	var (
+		c   *Module
+		err error
+	)
+	switch {
+	case m.data != nil:
+		if err = checkInitialized(); err == nil {
+			c, err = loadModule(m.data, m.filename)
+		}
+	case m.filename == "":
+		err = errNoData
+	default:
+		c, err = loadModuleFile(m.filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.filename = m.filename
+	c.digest = m.digest
+	c.modType = m.modType
+	c.charset, c.title, c.comment = m.charset, m.title, m.comment
+	c.SetGain(m.Gain())
+	c.duration.Store(m.duration.Load())
+	c.module.loop, c.module.fadeout = m.module.loop, m.module.fadeout
+	return c, nil
+}
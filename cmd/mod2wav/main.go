@@ -0,0 +1,78 @@
+//go:build cgo
+
+// Command mod2wav converts modules to WAV files.
+//
+// Usage:
+//
+//	mod2wav [-rate hz] [-loops n] [-fade duration] [-o dir] file...
+//
+// Each module is rendered as fast as possible into a WAV file named
+// after it, with the extension replaced by ".wav".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/death/go-mikmod"
+)
+
+func main() {
+	rate := flag.Int("rate", 44100, "sample rate in Hz")
+	loops := flag.Int("loops", 1, "number of times to play each song")
+	fade := flag.Duration("fade", 0, "fade out into the song's repeat over this duration")
+	outDir := flag.String("o", "", "output directory (default: next to each module)")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Supply one or more module filenames")
+	}
+
+	opts := mikmod.Options{
+		Driver:       mikmod.DriverNoSound,
+		MixFrequency: *rate,
+	}
+	if err := mikmod.InitWithOptions(opts); err != nil {
+		log.Fatal(err)
+	}
+	defer mikmod.Uninit()
+
+	renderOpts := mikmod.RenderOptions{Loops: *loops, Fade: *fade}
+	failed := false
+	for _, filename := range flag.Args() {
+		output := outputName(filename, *outDir)
+		if err := convert(filename, output, renderOpts); err != nil {
+			log.Printf("%s: %v", filename, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s -> %s\n", filename, output)
+	}
+	if failed {
+		mikmod.Uninit()
+		os.Exit(1)
+	}
+}
+
+// convert renders the module in filename into a WAV file named output.
+func convert(filename string, output string, opts mikmod.RenderOptions) error {
+	m, err := mikmod.LoadModuleFromFile(filename)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return mikmod.RenderToWAVWithOptions(m, output, opts)
+}
+
+// outputName returns the name of the WAV file for the module in
+// filename, in directory dir or alongside the module if dir is empty.
+func outputName(filename string, dir string) string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".wav"
+	if dir == "" {
+		return base
+	}
+	return filepath.Join(dir, filepath.Base(base))
+}
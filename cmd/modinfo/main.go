@@ -0,0 +1,149 @@
+//go:build cgo
+
+// Command modinfo prints information about modules.
+//
+// Usage:
+//
+//	modinfo [-json] [-duration=false] file...
+//	modinfo -version
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/death/go-mikmod"
+)
+
+// info is the information printed about a module.
+type info struct {
+	File        string        `json:"file"`
+	Title       string        `json:"title"`
+	Format      string        `json:"format"`
+	Tracker     string        `json:"tracker"`
+	Channels    int           `json:"channels"`
+	Positions   int           `json:"positions"`
+	Patterns    int           `json:"patterns"`
+	Instruments int           `json:"instruments"`
+	Samples     int           `json:"samples"`
+	Duration    time.Duration `json:"-"`
+	Seconds     float64       `json:"duration,omitempty"`
+	Comment     string        `json:"comment,omitempty"`
+	Issues      []string      `json:"issues,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+func main() {
+	asJSON := flag.Bool("json", false, "print JSON, one object per line")
+	duration := flag.Bool("duration", true, "estimate the duration by rendering each song")
+	version := flag.Bool("version", false, "print the libmikmod version, drivers and loaders")
+	flag.Parse()
+	if flag.NArg() == 0 && !*version {
+		log.Fatal("Supply one or more module filenames")
+	}
+
+	if err := mikmod.InitWithOptions(mikmod.Options{Driver: mikmod.DriverNoSound}); err != nil {
+		log.Fatal(err)
+	}
+	defer mikmod.Uninit()
+
+	if *version {
+		lib, err := mikmod.Library()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *asJSON {
+			json.NewEncoder(os.Stdout).Encode(lib)
+		} else {
+			fmt.Print(lib)
+		}
+		return
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	failed := false
+	for _, filename := range flag.Args() {
+		i := inspect(filename, *duration)
+		if i.Error != "" {
+			failed = true
+		}
+		if *asJSON {
+			enc.Encode(i)
+			continue
+		}
+		if i.Error != "" {
+			log.Printf("%s: %s", filename, i.Error)
+			continue
+		}
+		printInfo(i)
+	}
+	if failed {
+		mikmod.Uninit()
+		os.Exit(1)
+	}
+}
+
+// inspect returns information about the module in filename.
+func inspect(filename string, duration bool) info {
+	i := info{File: filename}
+	m, err := mikmod.LoadModuleFromFile(filename)
+	if err != nil {
+		i.Error = err.Error()
+		return i
+	}
+	defer m.Close()
+
+	i.Title = m.Title()
+	i.Format = strings.ToUpper(strings.TrimPrefix(filepath.Ext(filename), "."))
+	i.Tracker = m.Tracker()
+	i.Channels = m.NumChannels()
+	i.Positions = m.NumPositions()
+	i.Patterns = m.NumPatterns()
+	i.Instruments = m.NumInstruments()
+	i.Samples = m.NumSamples()
+	i.Comment = m.Comment()
+	issues, err := m.Validate()
+	if err != nil {
+		i.Error = err.Error()
+		return i
+	}
+	for _, issue := range issues {
+		i.Issues = append(i.Issues, issue.String())
+	}
+	if duration {
+		if i.Duration, err = mikmod.MeasureDuration(m); err != nil {
+			i.Error = err.Error()
+		}
+		i.Seconds = i.Duration.Seconds()
+	}
+	return i
+}
+
+// printInfo prints i in human readable form.
+func printInfo(i info) {
+	fmt.Printf("File:        %s\n", i.File)
+	fmt.Printf("Title:       %s\n", i.Title)
+	fmt.Printf("Format:      %s\n", i.Format)
+	fmt.Printf("Tracker:     %s\n", i.Tracker)
+	fmt.Printf("Channels:    %d\n", i.Channels)
+	fmt.Printf("Positions:   %d\n", i.Positions)
+	fmt.Printf("Patterns:    %d\n", i.Patterns)
+	fmt.Printf("Instruments: %d\n", i.Instruments)
+	fmt.Printf("Samples:     %d\n", i.Samples)
+	if i.Duration > 0 {
+		fmt.Printf("Duration:    %s\n", i.Duration.Round(time.Second))
+	}
+	if i.Comment != "" {
+		fmt.Printf("Comment:\n%s\n", i.Comment)
+	}
+	for _, issue := range i.Issues {
+		fmt.Printf("Issue:       %s\n", issue)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,201 @@
+//go:build cgo
+
+// Command modplay plays modules in the terminal.
+//
+// Usage:
+//
+//	modplay [-loop] [-preset name] file...
+//
+// The -preset flag selects a mikmod.Preset by name, e.g.
+// high-quality-archive.
+//
+// Keys:
+//
+//	space        pause or resume
+//	left, right  previous or next song position
+//	p, n         previous or next module
+//	-, +         lower or raise the volume
+//	1-9, 0       mute or unmute channels 1 to 10
+//	q            quit
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/death/go-mikmod"
+	"golang.org/x/term"
+)
+
+// refreshPeriod is the interval at which the display is redrawn.
+const refreshPeriod = 50 * time.Millisecond
+
+// meterWidth is the width of a channel's VU meter.
+const meterWidth = 40
+
+// maxChannels is the number of channels shown.
+const maxChannels = 32
+
+// volumeStep is the volume change per key press.
+const volumeStep = 1.0 / 16
+
+func main() {
+	loop := flag.Bool("loop", false, "start over when the last module ends")
+	preset := flag.String("preset", "", "initialize with the named `preset`, e.g. low-latency-game")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Supply one or more module filenames")
+	}
+
+	var opts mikmod.Options
+	if *preset != "" {
+		p, err := mikmod.ParsePreset(*preset)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = p.Options()
+	}
+	if err := mikmod.InitWithOptions(opts); err != nil {
+		log.Fatal(err)
+	}
+	defer mikmod.Uninit()
+
+	playlist := mikmod.NewPlaylist()
+	for _, filename := range flag.Args() {
+		playlist.AddFile(filename)
+	}
+	if err := playlist.Play(); err != nil {
+		log.Fatal(err)
+	}
+	defer playlist.Stop()
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), state)
+	}
+
+	keys := make(chan string)
+	go readKeys(keys)
+
+	var lastErr error
+	ticker := time.NewTicker(refreshPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-playlist.Events():
+			switch {
+			case e.Err != nil:
+				lastErr = e.Err
+			case e.Index < 0 && *loop:
+				if err := playlist.Play(); err != nil {
+					lastErr = err
+				}
+			case e.Index < 0:
+				return
+			}
+		case key, ok := <-keys:
+			if !ok || !handleKey(playlist, key) {
+				return
+			}
+		case <-ticker.C:
+			draw(playlist, lastErr)
+		}
+	}
+}
+
+// readKeys sends the keys read from standard input to keys, and closes
+// it when standard input ends.
+func readKeys(keys chan<- string) {
+	defer close(keys)
+	b := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(b)
+		if err != nil {
+			return
+		}
+		keys <- string(b[:n])
+	}
+}
+
+// handleKey acts on a key press, and returns false if the program
+// should exit.
+func handleKey(playlist *mikmod.Playlist, key string) bool {
+	switch key {
+	case "q", "Q", "\x03":
+		return false
+	case " ":
+		mikmod.TogglePause()
+	case "\x1b[D":
+		mikmod.PrevPosition()
+	case "\x1b[C":
+		mikmod.NextPosition()
+	case "p":
+		playlist.Previous()
+	case "n":
+		playlist.Next()
+	case "-":
+		mikmod.SetVolume(mikmod.Volume() - volumeStep)
+	case "+", "=":
+		mikmod.SetVolume(mikmod.Volume() + volumeStep)
+	default:
+		if len(key) == 1 && key[0] >= '0' && key[0] <= '9' {
+			ch := int(key[0] - '1')
+			if key[0] == '0' {
+				ch = 9
+			}
+			mikmod.ToggleMuteChannel(ch)
+		}
+	}
+	return true
+}
+
+// draw redraws the display.
+func draw(playlist *mikmod.Playlist, lastErr error) {
+	s := mikmod.Status()
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\r\n")
+	}
+
+	state := "playing"
+	switch {
+	case !s.Playing:
+		state = "stopped"
+	case s.Paused:
+		state = "paused"
+	}
+	line("[%d/%d] %s (%s)", playlist.Index()+1, playlist.Len(), s.Title, state)
+	if s.Module != nil {
+		elapsed, total, _ := mikmod.Progress()
+		line("%s, %d channels", s.Module.Tracker(), s.Module.NumChannels())
+		line("Position %d/%d, row %d, speed %d, tempo %d, %s",
+			s.Position, s.Module.NumPositions(), s.Row, s.Speed, s.Tempo,
+			mikmod.FormatProgress(elapsed, total))
+	}
+	line("Volume %d%%, driver %s", int(s.Volume*100+0.5), s.DriverName)
+	line("")
+
+	if s.Module != nil {
+		for ch := 0; ch < s.Module.NumChannels() && ch < maxChannels; ch++ {
+			meter := strings.Repeat("=", int(mikmod.ChannelLevel(ch)*meterWidth+0.5))
+			if mikmod.ChannelMuted(ch) {
+				meter = "(muted)"
+			}
+			line("%2d |%-*s|", ch+1, meterWidth, meter)
+		}
+	}
+	if lastErr != nil {
+		line("")
+		line("Error: %v", lastErr)
+	}
+	os.Stdout.WriteString(b.String())
+}
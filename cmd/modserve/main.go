@@ -0,0 +1,217 @@
+//go:build cgo
+
+// Command modserve serves a directory of modules over HTTP.
+//
+// Usage:
+//
+//	modserve [-addr :8080] [-dir .] [-cache dir] [-rate hz]
+//
+// Endpoints:
+//
+//	GET /api/modules         metadata of all modules, as JSON
+//	GET /api/modules/{name}  metadata of one module, as JSON
+//	GET /modules/{name}.wav  the module rendered as WAV
+//
+// Modules are rendered on first request and kept in the cache
+// directory, from which they are served with support for range
+// requests.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/death/go-mikmod"
+)
+
+// info is the metadata served about a module.
+type info struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Tracker     string `json:"tracker"`
+	Channels    int    `json:"channels"`
+	Positions   int    `json:"positions"`
+	Instruments int    `json:"instruments"`
+	Samples     int    `json:"samples"`
+	Comment     string `json:"comment,omitempty"`
+	URL         string `json:"url"`
+}
+
+// server serves the modules in a directory.
+type server struct {
+	dir   string
+	cache string
+
+	// mu serializes the use of MikMod, which renders one module at
+	// a time, and guards infos.
+	mu    sync.Mutex
+	infos map[string]info
+}
+
+var errNotFound = errors.New("no such module")
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	dir := flag.String("dir", ".", "directory of modules to serve")
+	cache := flag.String("cache", "", "directory of rendered modules (default: a temporary directory)")
+	rate := flag.Int("rate", 44100, "sample rate in Hz")
+	flag.Parse()
+
+	if *cache == "" {
+		tmp, err := os.MkdirTemp("", "modserve")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.RemoveAll(tmp)
+		*cache = tmp
+	}
+
+	opts := mikmod.Options{Driver: mikmod.DriverNoSound, MixFrequency: *rate}
+	if err := mikmod.InitWithOptions(opts); err != nil {
+		log.Fatal(err)
+	}
+	defer mikmod.Uninit()
+
+	s := &server{dir: *dir, cache: *cache, infos: make(map[string]info)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/modules", s.serveList)
+	mux.HandleFunc("GET /api/modules/{name}", s.serveInfo)
+	mux.HandleFunc("GET /modules/{file}", s.serveWAV)
+	log.Printf("Serving %s on %s", *dir, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) serveList(w http.ResponseWriter, r *http.Request) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := []info{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if i, err := s.info(e.Name()); err == nil {
+			infos = append(infos, i)
+		}
+	}
+	writeJSON(w, infos)
+}
+
+func (s *server) serveInfo(w http.ResponseWriter, r *http.Request) {
+	i, err := s.info(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, i)
+}
+
+func (s *server) serveWAV(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("file"), ".wav")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	filename, err := s.render(name)
+	if errors.Is(err, errNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "audio/wav")
+	http.ServeFile(w, r, filename)
+}
+
+// path returns the path of the module called name, which must be in
+// the served directory.
+func (s *server) path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", errNotFound
+	}
+	path := filepath.Join(s.dir, name)
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+		return "", errNotFound
+	}
+	return path, nil
+}
+
+// info returns the metadata of the module called name.
+func (s *server) info(name string) (info, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return info{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := s.infos[name]; ok {
+		return i, nil
+	}
+	m, err := mikmod.LoadModuleFromFile(path)
+	if err != nil {
+		return info{}, errNotFound
+	}
+	defer m.Close()
+	i := info{
+		Name:        name,
+		Title:       m.Title(),
+		Tracker:     m.Tracker(),
+		Channels:    m.NumChannels(),
+		Positions:   m.NumPositions(),
+		Instruments: m.NumInstruments(),
+		Samples:     m.NumSamples(),
+		Comment:     m.Comment(),
+		URL:         "/modules/" + name + ".wav",
+	}
+	s.infos[name] = i
+	return i, nil
+}
+
+// render returns the filename of the module called name rendered as
+// WAV, rendering it if it is not in the cache yet.
+func (s *server) render(name string) (string, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(s.cache, name+".wav")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(filename); err == nil {
+		return filename, nil
+	}
+	m, err := mikmod.LoadModuleFromFile(path)
+	if err != nil {
+		return "", errNotFound
+	}
+	defer m.Close()
+	// Render to a temporary file, so that a failed render does not
+	// leave a truncated file in the cache.
+	tmp := filename + ".tmp"
+	if err := mikmod.RenderToWAV(m, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return filename, os.Rename(tmp, filename)
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print(err)
+	}
+}
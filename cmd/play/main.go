@@ -1,3 +1,5 @@
+//go:build cgo
+
 package main
 
 import (
@@ -26,7 +28,9 @@ func main() {
 
 	log.Printf("Playing '%s'...\n", m.Title())
 
-	mikmod.Play(m)
+	if err := mikmod.Play(m); err != nil {
+		log.Fatal(err)
+	}
 	defer mikmod.Stop()
 	for mikmod.IsPlaying() {
 		time.Sleep(time.Second)
@@ -0,0 +1,47 @@
+package mikmod
+
+import "strings"
+
+// CommentLines returns the lines of the song comment, e.g. for a
+// scroller.  Trackers break lines with CR, LF or both, and lay text
+// out in fixed-width columns, so leading spaces are kept while
+// trailing spaces, NULs and empty lines at the end are removed, and
+// tabs are expanded to every eighth column.
+func (m *Module) CommentLines() []string {
+	return commentLines(m.Comment())
+}
+
+// commentLines splits and normalizes a song comment.
+func commentLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(expandTabs(line), " \x00")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// expandTabs replaces the tabs in line with spaces up to the next
+// multiple of eight columns.
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
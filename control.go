@@ -0,0 +1,113 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// Player_Mute and friends are variadic, which cgo cannot call.
+static void muteChannel(SLONG ch) { Player_Mute(ch); }
+static void unmuteChannel(SLONG ch) { Player_Unmute(ch); }
+static void toggleMuteChannel(SLONG ch) { Player_ToggleMute(ch); }
+*/
+import "C"
+
+import "errors"
+
+// maxVoiceVolume is the largest value returned by Voice_RealVolume.
+const maxVoiceVolume = 65535
+
+// TogglePause pauses the playing module, or resumes it if it is
+// paused.
+func TogglePause() {
+	var paused bool
+	do(func() {
+		C.Player_TogglePause()
+		paused = goBool(C.Player_Paused())
+	})
+	logger().Info("mikmod: paused", "paused", paused)
+}
+
+// Paused returns true if the player is paused, and false otherwise.
+func Paused() bool { return call(func() bool { return goBool(C.Player_Paused()) }) }
+
+// Position returns the index of the song position being played.
+func Position() int { return call(func() int { return int(C.Player_GetOrder()) }) }
+
+// Row returns the row being played in the current pattern.
+func Row() int { return call(func() int { return int(C.Player_GetRow()) }) }
+
+// SetPosition jumps to song position pos.
+func SetPosition(pos int) { do(func() { C.Player_SetPosition(C.UWORD(pos)) }) }
+
+// SeekPattern jumps to the first song position playing pattern pat of
+// the playing module, e.g. to audition a pattern.
+func SeekPattern(pat int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return errNothingPlaying
+	}
+	pos := m.PatternPosition(pat)
+	if pos < 0 {
+		return errPatternNotInSong
+	}
+	SetPosition(pos)
+	return nil
+}
+
+var errPatternNotInSong = errors.New("mikmod: pattern is not played by the song")
+
+// NextPosition jumps to the next song position.
+func NextPosition() { do(func() { C.Player_NextPosition() }) }
+
+// PrevPosition jumps to the previous song position.
+func PrevPosition() { do(func() { C.Player_PrevPosition() }) }
+
+// SetVolume sets MikMod's global volume, between 0 and 1.  Unlike the
+// music level controlled by fades and gain, it also applies to sound
+// effects.
+func SetVolume(v float64) { C.md_volume = C.UBYTE(clamp(v, 0, 1)*128 + 0.5) }
+
+// Volume returns MikMod's global volume, between 0 and 1.
+func Volume() float64 { return float64(C.md_volume) / 128 }
+
+// MuteChannel mutes channel ch of the playing module, unless it is
+// reserved; see ReserveChannel.
+func MuteChannel(ch int) {
+	do(func() {
+		if !channelReserved(ch) {
+			C.muteChannel(C.SLONG(ch))
+		}
+	})
+}
+
+// UnmuteChannel unmutes channel ch of the playing module.
+func UnmuteChannel(ch int) { do(func() { C.unmuteChannel(C.SLONG(ch)) }) }
+
+// ToggleMuteChannel mutes channel ch of the playing module, or unmutes
+// it if it is muted.  Reserved channels are never muted.
+func ToggleMuteChannel(ch int) {
+	do(func() {
+		if !channelReserved(ch) || C.Player_Muted(C.UBYTE(ch)) != 0 {
+			C.toggleMuteChannel(C.SLONG(ch))
+		}
+	})
+}
+
+// ChannelMuted returns true if channel ch of the playing module is
+// muted, and false otherwise.
+func ChannelMuted(ch int) bool {
+	return call(func() bool { return goBool(C.Player_Muted(C.UBYTE(ch))) })
+}
+
+// ChannelLevel returns the current output level of channel ch of the
+// playing module, between 0 and 1, e.g. for VU meters.
+func ChannelLevel(ch int) float64 {
+	return call(func() float64 {
+		voice := C.Player_GetChannelVoice(C.UBYTE(ch))
+		if voice < 0 {
+			return 0
+		}
+		return float64(C.Voice_RealVolume(C.SBYTE(voice))) / maxVoiceVolume
+	})
+}
@@ -0,0 +1,147 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// RenderJob is a module to render to a file with ConvertAll.
+type RenderJob struct {
+	// Input is the module file to render, and Output the file to
+	// create.
+	Input  string
+	Output string
+
+	// Encoder encodes the output.  If nil, a WAV file is written,
+	// with the module's metadata.
+	Encoder Encoder
+
+	// Options controls rendering.
+	Options RenderOptions
+}
+
+// renderMu serializes the use of libmikmod's single player by
+// ConvertAll's workers.
+var renderMu sync.Mutex
+
+// ConvertAll renders jobs using the given number of workers, and
+// returns the error of each job, nil if it succeeded.  libmikmod has a
+// single player, so modules are loaded and mixed one at a time, but
+// rendering is faster than real time, and while a worker mixes a
+// module the others read, encode and write theirs, which dominates
+// with compressing encoders.  Each worker holds the PCM data of a
+// module in memory between mixing and encoding.  Jobs not yet started
+// when ctx is done fail with ctx's error.  Any module currently playing
+// is stopped.
+func ConvertAll(ctx context.Context, jobs []RenderJob, workers int) []error {
+	errs := make([]error, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = convert(ctx, jobs[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+	close(next)
+	wg.Wait()
+	return errs
+}
+
+// convert runs job.
+func convert(ctx context.Context, job RenderJob) error {
+	data, err := translateFile(job.Input)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return ErrNotAModule
+	}
+	pcm, format, tags, err := renderJob(ctx, data, job.Input, job.Options)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(job.Output)
+	if err != nil {
+		return err
+	}
+	if job.Encoder != nil {
+		err = encodeTo(f, job.Encoder, format, pcm)
+	} else {
+		err = writeWAVTo(f, format, pcm, tags)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// renderJob loads a module from data, read from filename, and renders
+// it as specified by opts, returning the PCM data, its format and the
+// module's WAV tags.
+func renderJob(ctx context.Context, data []byte, filename string, opts RenderOptions) ([]byte, Format, []wavTag, error) {
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, Format{}, nil, err
+	}
+	if err := checkInitialized(); err != nil {
+		return nil, Format{}, nil, err
+	}
+	m, err := loadModule(data, filename)
+	if err != nil {
+		return nil, Format{}, nil, err
+	}
+	defer m.Close()
+	var pcm bytes.Buffer
+	err = render(m, opts, func(b []byte) error {
+		pcm.Write(b)
+		return ctx.Err()
+	})
+	return pcm.Bytes(), currentFormat(), wavTags(m), err
+}
+
+// encodeTo encodes PCM data in format f into w using enc.
+func encodeTo(w io.Writer, enc Encoder, f Format, pcm []byte) error {
+	e, err := enc.Encode(w, f)
+	if err != nil {
+		return err
+	}
+	_, err = e.Write(pcm)
+	if cerr := e.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeWAVTo writes PCM data in format f as a WAV file into w, along
+// with a LIST/INFO chunk holding tags.
+func writeWAVTo(w io.WriteSeeker, f Format, pcm []byte, tags []wavTag) error {
+	if err := writeWAVHeader(w, f, 0); err != nil {
+		return err
+	}
+	if _, err := w.Write(pcm); err != nil {
+		return err
+	}
+	return finishWAV(w, f, uint32(len(pcm)), wavInfoChunk(tags))
+}
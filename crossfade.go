@@ -0,0 +1,134 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+extern MDRIVER drv_go;
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+// crossfadeState is a crossfade in progress: the tail of the outgoing
+// module, mixed ahead of time, and how far into the ramp the output is,
+// in sample frames.
+type crossfadeState struct {
+	tail        []byte
+	pos, frames int
+}
+
+var (
+	// crossfade is the crossfade in progress, if frames is not 0.  It
+	// is only used with MikMod locked.
+	crossfade crossfadeState
+
+	// endCrossfade is the length of the crossfade into the modules
+	// returned by endHook, or 0 to start them once the playing module
+	// ends.  It is guarded by endHookMu.
+	endCrossfade time.Duration
+)
+
+// setEndCrossfade sets the length of the crossfade into the modules
+// returned by the end hook.  The hook is then called that long before
+// the playing module ends, with early set, so that the modules
+// overlap.
+func setEndCrossfade(d time.Duration) {
+	endHookMu.Lock()
+	defer endHookMu.Unlock()
+	endCrossfade = d
+}
+
+// crossfadeLength returns the length of the crossfade into the modules
+// returned by the end hook, or 0 if there is none.  Native drivers mix
+// internally, out of reach of mixCrossfade, so crossfades only happen
+// with Go output drivers.
+func crossfadeLength() time.Duration {
+	endHookMu.Lock()
+	defer endHookMu.Unlock()
+	if endHook == nil || C.md_driver != &C.drv_go {
+		return 0
+	}
+	return endCrossfade
+}
+
+// crossfadeDue returns true if the playing module ends within the
+// crossfade into the module the end hook returns, so that the update
+// loop calls the hook ahead of time.  Modules without a seek table, or
+// looping, never end early.  It is called by the update loop.
+func crossfadeDue() bool {
+	d := crossfadeLength()
+	m := playingModule()
+	if d <= 0 || m == nil || m.module.wrap != 0 {
+		return false
+	}
+	t := m.seekTable.Load()
+	if t == nil || t.loops && m.module.loop != 0 {
+		return false
+	}
+	at, ok := t.Time(int(m.module.sngpos), int(m.module.patpos))
+	return ok && t.Duration()-at <= d
+}
+
+// startCrossfade mixes d of the playing module ahead of time, as the
+// tail of a crossfade, to be mixed into the output, fading out, while
+// the next module fades in.  If the module has ended already, the next
+// one only fades in.  Taps never see the tail, which is mixed into
+// the output after them.  It is called right before the next module
+// starts, and does nothing with native drivers; see crossfadeLength.
+func startCrossfade(d time.Duration) {
+	if C.md_driver != &C.drv_go {
+		return
+	}
+	f := currentFormat()
+	frames := int(d.Seconds() * float64(f.SampleRate))
+	var tail []byte
+	C.MikMod_Lock()
+	defer C.MikMod_Unlock()
+	if C.Player_Active() != 0 && frames > 0 {
+		tail = make([]byte, frames*f.FrameSize())
+		C.VC_SetCallback(nil)
+		n := C.VC_WriteBytes((*C.SBYTE)(unsafe.Pointer(&tail[0])), C.ULONG(len(tail)))
+		tail = tail[:n]
+		tapMu.Lock()
+		enableTap(tap != nil)
+		tapMu.Unlock()
+	}
+	crossfade = crossfadeState{tail: tail, frames: frames}
+}
+
+// cancelCrossfade drops the crossfade in progress, if any.
+func cancelCrossfade() {
+	C.MikMod_Lock()
+	crossfade = crossfadeState{}
+	C.MikMod_Unlock()
+}
+
+// mixCrossfade ramps PCM data b in format f, freshly mixed for the
+// incoming module, up, and mixes the tail of the outgoing module into
+// it, ramped down, while a crossfade is in progress.  It expects MikMod
+// to be locked.
+func mixCrossfade(b []byte, f Format) {
+	x := &crossfade
+	if x.frames == 0 || f.Channels <= 0 {
+		return
+	}
+	tailSamples := f.numSamples(x.tail)
+	frames := f.numSamples(b) / f.Channels
+	for i := 0; i < frames && x.pos < x.frames; i, x.pos = i+1, x.pos+1 {
+		in := float64(x.pos) / float64(x.frames)
+		for c := range f.Channels {
+			j, k := i*f.Channels+c, x.pos*f.Channels+c
+			v := f.sampleAt(b, j) * in
+			if k < tailSamples {
+				v += f.sampleAt(x.tail, k) * (1 - in)
+			}
+			f.setSampleAt(b, j, v)
+		}
+	}
+	if x.pos >= x.frames {
+		*x = crossfadeState{}
+	}
+}
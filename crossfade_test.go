@@ -0,0 +1,32 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMixCrossfade(t *testing.T) {
+	f := Format{SampleRate: 4, Channels: 1, BitsPerSample: 16}
+	fill := func(n int, v float64) []byte {
+		b := make([]byte, n*f.FrameSize())
+		for i := range n {
+			f.setSampleAt(b, i, v)
+		}
+		return b
+	}
+	crossfade = crossfadeState{tail: fill(3, 0.5), frames: 4}
+	b := fill(6, 0.25)
+	mixCrossfade(b, f)
+	// The tail ends before the ramp does, and the ramp before b.
+	want := []float64{0.5, 0.4375, 0.375, 0.1875, 0.25, 0.25}
+	for i, w := range want {
+		if got := f.sampleAt(b, i); math.Abs(got-w) > 1e-4 {
+			t.Errorf("sample %d: got %.4f, want %.4f", i, got, w)
+		}
+	}
+	if crossfade.frames != 0 {
+		t.Errorf("crossfade still in progress after its ramp")
+	}
+}
@@ -0,0 +1,99 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// Driver is a MikMod output driver.
+type Driver struct {
+	driver *C.MDRIVER
+
+	// output is the name of the command line parameter that sets
+	// the driver's output target, if it has one.
+	output string
+}
+
+// Drivers that are part of every libmikmod build.
+var (
+	// DriverNoSound discards all output.
+	DriverNoSound = &Driver{driver: &C.drv_nos}
+
+	// DriverWAV writes output to a RIFF WAVE file.
+	DriverWAV = &Driver{driver: &C.drv_wav, output: "file"}
+
+	// DriverAIFF writes output to an AIFF file.
+	DriverAIFF = &Driver{driver: &C.drv_aiff, output: "file"}
+
+	// DriverRaw writes raw PCM output to a file.
+	DriverRaw = &Driver{driver: &C.drv_raw, output: "file"}
+)
+
+// Name returns the driver's descriptive name.
+func (d *Driver) Name() string { return C.GoString((*C.char)(d.driver.Name)) }
+
+// Alias returns the driver's short name, as accepted by libmikmod on
+// the command line.
+func (d *Driver) Alias() string { return C.GoString((*C.char)(d.driver.Alias)) }
+
+// Version returns the driver's version string.
+func (d *Driver) Version() string { return C.GoString((*C.char)(d.driver.Version)) }
+
+// commandLine returns the command line for the driver, combining
+// the output target with any other driver arguments.
+func (d *Driver) commandLine(output string, args string) string {
+	if d == nil || d.output == "" || output == "" {
+		return args
+	}
+	if args == "" {
+		return d.output + "=" + output
+	}
+	return d.output + "=" + output + "," + args
+}
+
+// register registers the driver with MikMod, if it isn't registered
+// already, and returns its ordinal number.
+func (d *Driver) register() int {
+	do(func() { C.MikMod_RegisterDriver(d.driver) })
+	return driverFromAlias(d.Alias())
+}
+
+// driverFromAlias returns the ordinal number of the registered driver
+// with the supplied alias, or 0 if there is no such driver.
+func driverFromAlias(alias string) int {
+	s := mikmodString(alias)
+	defer C.free(unsafe.Pointer(s))
+	return call(func() int { return int(C.MikMod_DriverFromAlias(s)) })
+}
+
+// deviceAlias returns the alias of the registered driver with ordinal
+// number device, for error messages.
+func deviceAlias(device int) string {
+	if device == 0 {
+		return "autodetected"
+	}
+	d := C.MikMod_DriverByOrdinal(C.int(device))
+	if d == nil {
+		return strconv.Itoa(device)
+	}
+	return (&Driver{driver: d}).Alias()
+}
+
+// registerDrivers registers the supplied drivers with MikMod, or all
+// the drivers compiled into the library if none are supplied.
+func registerDrivers(drivers []*Driver) {
+	do(func() {
+		if len(drivers) == 0 {
+			C.MikMod_RegisterAllDrivers()
+			return
+		}
+		for _, d := range drivers {
+			C.MikMod_RegisterDriver(d.driver)
+		}
+	})
+}
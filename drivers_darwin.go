@@ -0,0 +1,92 @@
+package mikmod
+
+/*
+#cgo LDFLAGS: -framework CoreAudio
+#include <mikmod.h>
+#include <CoreAudio/CoreAudio.h>
+
+extern OSStatus goDefaultOutputChanged(AudioObjectID, UInt32, AudioObjectPropertyAddress*, void*);
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+// Drivers that are only part of libmikmod builds on macOS.
+var (
+	// DriverCoreAudio plays through CoreAudio.  libmikmod's driver
+	// always uses the system's default output device, and has no
+	// parameter to select another.
+	DriverCoreAudio = &Driver{driver: &C.drv_osx}
+)
+
+var (
+	deviceListenerOnce sync.Once
+	deviceListenerErr  error
+
+	// followDefaultDevice is true if the CoreAudio driver is reopened
+	// when the default output device changes.  It is guarded by mu.
+	followDefaultDevice bool
+)
+
+// SetFollowDefaultDevice makes the package reopen the CoreAudio driver
+// whenever the system's default output device changes, e.g. when
+// headphones connect, so that playback continues on the new device
+// instead of going silent.  The module playing keeps playing.  It has
+// no effect with other drivers.
+func SetFollowDefaultDevice(on bool) error {
+	if on {
+		deviceListenerOnce.Do(func() { deviceListenerErr = listenDefaultDevice() })
+		if deviceListenerErr != nil {
+			return deviceListenerErr
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	followDefaultDevice = on
+	return nil
+}
+
+// listenDefaultDevice registers goDefaultOutputChanged to be called
+// when the default output device changes.
+func listenDefaultDevice() error {
+	addr := C.AudioObjectPropertyAddress{
+		mSelector: C.kAudioHardwarePropertyDefaultOutputDevice,
+		mScope:    C.kAudioObjectPropertyScopeGlobal,
+		mElement:  0, // kAudioObjectPropertyElementMain
+	}
+	status := C.AudioObjectAddPropertyListener(C.kAudioObjectSystemObject, &addr,
+		C.AudioObjectPropertyListenerProc(C.goDefaultOutputChanged), nil)
+	if status != 0 {
+		return errDeviceListener
+	}
+	return nil
+}
+
+var errDeviceListener = errors.New("mikmod: cannot watch the default output device")
+
+//export goDefaultOutputChanged
+func goDefaultOutputChanged(id C.AudioObjectID, n C.UInt32, addrs *C.AudioObjectPropertyAddress, data unsafe.Pointer) C.OSStatus {
+	// CoreAudio calls listeners on its own thread, which must not
+	// block on the driver.
+	go reopenCoreAudio()
+	return 0
+}
+
+// reopenCoreAudio reopens the CoreAudio driver, if it is the driver in
+// use and SetFollowDefaultDevice is on, so that it plays through the
+// new default output device.
+func reopenCoreAudio() {
+	mu.Lock()
+	defer mu.Unlock()
+	if !followDefaultDevice || initCount == 0 || C.md_driver != &C.drv_osx {
+		return
+	}
+	logger().Info("mikmod: default output device changed, reopening driver")
+	if err := reset(); err != nil {
+		reportError(err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+// Drivers that are only part of libmikmod builds on Unix systems.
+var (
+	// DriverStdout writes raw PCM output to standard output.
+	DriverStdout = &Driver{driver: &C.drv_stdout}
+
+	// DriverPipe pipes raw PCM output to a command.
+	DriverPipe = &Driver{driver: &C.drv_pipe, output: "pipe"}
+)
@@ -0,0 +1,103 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"sync"
+	"time"
+)
+
+// Ducking lowers the music volume while important sound effects play,
+// as set by SetDucking, or only that of some channels; see
+// ReserveChannel.
+type Ducking struct {
+	// Threshold is the lowest priority of the sound effects that
+	// duck the music; see SampleOptions.Priority.
+	Threshold int
+
+	// Gain is the linear gain applied to the music while ducked,
+	// e.g. 0.5 for about -6 dB.
+	Gain float64
+
+	// Attack is how long the music takes to dip, and Release how
+	// long it takes to come back once the sound effects stop.
+	Attack  time.Duration
+	Release time.Duration
+}
+
+var (
+	// ducking is the setting set by SetDucking, or nil, and duck
+	// the gain currently applied by ducking.  duckUpdated is the
+	// time of the last update of duck.  They are guarded by duckMu.
+	duckMu      sync.Mutex
+	ducking     *Ducking
+	duck        = 1.0
+	duckUpdated time.Time
+)
+
+// SetDucking makes the music dip while sound effects played with
+// PlaySample at or above d.Threshold are playing.  Passing nil turns
+// ducking off.
+func SetDucking(d *Ducking) {
+	duckMu.Lock()
+	defer duckMu.Unlock()
+	if d != nil {
+		c := *d
+		d = &c
+	}
+	ducking = d
+}
+
+// resetDucking restores the gain applied by ducking to full volume.
+func resetDucking() {
+	duckMu.Lock()
+	defer duckMu.Unlock()
+	duck = 1
+	duckUpdated = time.Time{}
+}
+
+// duckGain returns the gain currently applied by ducking.
+func duckGain() float64 {
+	duckMu.Lock()
+	defer duckMu.Unlock()
+	return duck
+}
+
+// applyDucking moves the ducking gain toward its target, depending on
+// the sound effects playing.  It is called by the update loop.
+func applyDucking() {
+	duckMu.Lock()
+	d := ducking
+	now := time.Now()
+	var elapsed time.Duration
+	if !duckUpdated.IsZero() {
+		elapsed = now.Sub(duckUpdated)
+	}
+	duckUpdated = now
+	old := duck
+	duckMu.Unlock()
+
+	target, ramp := 1.0, time.Duration(0)
+	if d != nil {
+		ramp = d.Release
+		if p, ok := playingSFXPriority(); ok && p >= d.Threshold {
+			target, ramp = clamp(d.Gain, 0, 1), d.Attack
+		}
+	}
+	if old == target {
+		return
+	}
+	next := target
+	if ramp > 0 {
+		step := float64(elapsed) / float64(ramp)
+		if old < target {
+			next = min(old+step, target)
+		} else {
+			next = max(old-step, target)
+		}
+	}
+	duckMu.Lock()
+	duck = next
+	duckMu.Unlock()
+	applyGain()
+}
@@ -0,0 +1,52 @@
+//go:build cgo
+
+package mikmod
+
+import "io"
+
+// Encoder encodes PCM data into a compressed audio format, such as Ogg
+// Vorbis or FLAC, for RenderEncoded.  Encoders are provided by other
+// packages, e.g. by wrapping a pure-Go encoder or an encoding command.
+type Encoder interface {
+	// Encode returns a writer encoding the PCM data in format f
+	// written to it into w.  Closing it flushes the encoded stream,
+	// but does not close w.
+	Encode(w io.Writer, f Format) (io.WriteCloser, error)
+}
+
+// TaggingEncoder is an Encoder that also writes metadata tags, such as
+// Vorbis comments.  Tags are named as in Vorbis comments, e.g. TITLE,
+// and LOOPSTART and LOOPLENGTH for loop points, in sample frames, as
+// read by game engines.
+type TaggingEncoder interface {
+	Encoder
+
+	// EncodeTagged is like Encode, but also writes tags.
+	EncodeTagged(w io.Writer, f Format, tags map[string]string) (io.WriteCloser, error)
+}
+
+// EncoderFunc adapts a function to the Encoder interface.
+type EncoderFunc func(w io.Writer, f Format) (io.WriteCloser, error)
+
+// Encode calls fn(w, f).
+func (fn EncoderFunc) Encode(w io.Writer, f Format) (io.WriteCloser, error) { return fn(w, f) }
+
+// RenderEncoded is like RenderToWithOptions, but encodes the PCM data
+// with enc as it is mixed.  Any module currently playing is stopped.
+func RenderEncoded(m *Module, w io.Writer, enc Encoder, opts RenderOptions) error {
+	if err := checkInitialized(); err != nil {
+		return err
+	}
+	e, err := enc.Encode(w, currentFormat())
+	if err != nil {
+		return err
+	}
+	err = render(m, opts, func(b []byte) error {
+		_, err := e.Write(b)
+		return err
+	})
+	if cerr := e.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
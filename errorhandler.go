@@ -0,0 +1,60 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+extern void goErrorHandler(void);
+*/
+import "C"
+
+import "sync"
+
+var (
+	errorHandlerMu sync.Mutex
+	errorHandler   func(err error)
+)
+
+// SetErrorHandler registers fn to be called with errors reported by
+// MikMod, including asynchronous ones from the driver or mixer during
+// playback, such as a lost device or failed writes.  fn is called on
+// its own goroutine, so it may safely call back into this package.
+// The errors are *SessionError values, carrying the session during
+// which they were reported; see SessionOf.  Passing nil removes the
+// handler.
+func SetErrorHandler(fn func(err error)) {
+	errorHandlerMu.Lock()
+	errorHandler = fn
+	errorHandlerMu.Unlock()
+	registerErrorHandler()
+}
+
+// registerErrorHandler registers goErrorHandler as MikMod's error
+// handler.
+func registerErrorHandler() {
+	do(func() { C.MikMod_RegisterErrorHandler(C.MikMod_handler_t(C.goErrorHandler)) })
+}
+
+//export goErrorHandler
+func goErrorHandler() {
+	err := mikmodError()
+	s := runningUpdateSession()
+	reportSessionError(s, err)
+	maybeRecover(err, s)
+}
+
+// reportError calls the error handler with err, if there is one, as
+// an error of the current session.
+func reportError(err error) { reportSessionError(CurrentSession(), err) }
+
+// reportSessionError calls the error handler with err, an error of
+// session s, if there is one.
+func reportSessionError(s Session, err error) {
+	logger().Info("mikmod: error", "err", err, "session", s)
+	metrics.errors.Add(1)
+	errorHandlerMu.Lock()
+	fn := errorHandler
+	errorHandlerMu.Unlock()
+	if fn != nil {
+		go fn(&SessionError{Session: s, Err: err})
+	}
+}
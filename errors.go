@@ -0,0 +1,82 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "errors"
+
+// Error is an error reported by MikMod, identified by its errno value.
+// Use errors.Is with the sentinel errors below to tell them apart.
+type Error struct {
+	Code int
+
+	// Critical is true if the error left the output driver unusable,
+	// in which case MikMod needs to be reset or reinitialized.
+	Critical bool
+}
+
+// Error returns MikMod's description of the error.
+func (e Error) Error() string {
+	return call(func() string { return C.GoString(C.MikMod_strerror(C.int(e.Code))) })
+}
+
+// Is returns true if target is an Error with the same code, and false
+// otherwise.  Criticality is not taken into account.
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.Code == e.Code
+}
+
+// Sentinel errors for common MikMod errno values.
+var (
+	ErrOpeningFile       = Error{Code: C.MMERR_OPENING_FILE}
+	ErrOutOfMemory       = Error{Code: C.MMERR_OUT_OF_MEMORY}
+	ErrDynamicLinking    = Error{Code: C.MMERR_DYNAMIC_LINKING}
+	ErrSampleTooBig      = Error{Code: C.MMERR_SAMPLE_TOO_BIG}
+	ErrOutOfHandles      = Error{Code: C.MMERR_OUT_OF_HANDLES}
+	ErrUnknownWaveType   = Error{Code: C.MMERR_UNKNOWN_WAVE_TYPE}
+	ErrLoadingPattern    = Error{Code: C.MMERR_LOADING_PATTERN}
+	ErrLoadingTrack      = Error{Code: C.MMERR_LOADING_TRACK}
+	ErrLoadingHeader     = Error{Code: C.MMERR_LOADING_HEADER}
+	ErrLoadingSampleInfo = Error{Code: C.MMERR_LOADING_SAMPLEINFO}
+	ErrNotAModule        = Error{Code: C.MMERR_NOT_A_MODULE}
+	ErrNotAStream        = Error{Code: C.MMERR_NOT_A_STREAM}
+	ErrDetectingDevice   = Error{Code: C.MMERR_DETECTING_DEVICE}
+	ErrInvalidDevice     = Error{Code: C.MMERR_INVALID_DEVICE}
+	ErrInitializingMixer = Error{Code: C.MMERR_INITIALIZING_MIXER}
+	ErrDeviceOpen        = Error{Code: C.MMERR_OPENING_AUDIO}
+)
+
+// ErrNotInitialized is returned when the package is used before the
+// MikMod library is initialized.
+var ErrNotInitialized = errors.New("mikmod: not initialized")
+
+// IsLoadError returns true if the error means that a module or sample
+// could not be loaded because its data is invalid, and false
+// otherwise.
+func (e Error) IsLoadError() bool {
+	switch e.Code {
+	case C.MMERR_LOADING_PATTERN, C.MMERR_LOADING_TRACK, C.MMERR_LOADING_HEADER,
+		C.MMERR_LOADING_SAMPLEINFO, C.MMERR_NOT_A_MODULE, C.MMERR_NOT_A_STREAM,
+		C.MMERR_MED_SYNTHSAMPLES, C.MMERR_ITPACK_INVALID_DATA:
+		return true
+	}
+	return false
+}
+
+// IsDeviceError returns true if the error concerns the audio device or
+// output driver, and false otherwise.
+func (e Error) IsDeviceError() bool {
+	return e.Code >= C.MMERR_DETECTING_DEVICE && e.Code < C.MMERR_MAX
+}
+
+// mikmodError returns a Go error corresponding to the current MikMod
+// error.
+func mikmodError() error {
+	return Error{
+		Code:     int(C.MikMod_errno),
+		Critical: goBool(C.MikMod_critical),
+	}
+}
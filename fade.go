@@ -0,0 +1,134 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"time"
+)
+
+// fade is a ramp of the music volume, applied by the update loop.
+type fade struct {
+	from, to float64
+	start    time.Time
+	duration time.Duration
+	done     chan struct{}
+}
+
+var (
+	fadeMu      sync.Mutex
+	currentFade *fade
+)
+
+// FadeOut ramps the music volume down to silence over duration d, and
+// returns a channel that is closed when the fade completes or is
+// replaced.  The module keeps playing, silently, until stopped; the
+// music volume comes back with the next fade in, e.g. one requested
+// with PlayOptions.FadeIn.
+func FadeOut(d time.Duration) <-chan struct{} { return startFade(0, d) }
+
+// FadeIn ramps the music volume up from its current level to full
+// volume over duration d, and returns a channel that is closed when
+// the fade completes or is replaced.
+func FadeIn(d time.Duration) <-chan struct{} { return startFade(1, d) }
+
+// startFade starts ramping the music volume from its current level to
+// level to, between 0 and 1, over duration d.  It replaces any fade in
+// progress and returns a channel that is closed when the fade
+// completes or is replaced.  Fades only progress while the update loop
+// runs.
+func startFade(to float64, d time.Duration) <-chan struct{} {
+	fadeMu.Lock()
+	defer fadeMu.Unlock()
+	if currentFade != nil {
+		close(currentFade.done)
+	}
+	currentFade = &fade{
+		from:     musicLevel(),
+		to:       to,
+		start:    time.Now(),
+		duration: d,
+		done:     make(chan struct{}),
+	}
+	return currentFade.done
+}
+
+// cancelFade stops any fade in progress, leaving the music volume at
+// its current level.
+func cancelFade() {
+	fadeMu.Lock()
+	defer fadeMu.Unlock()
+	if currentFade != nil {
+		close(currentFade.done)
+		currentFade = nil
+	}
+}
+
+// applyFade sets the music volume according to the fade in progress,
+// if any.  It is called by the update loop.
+func applyFade() {
+	fadeMu.Lock()
+	defer fadeMu.Unlock()
+	f := currentFade
+	if f == nil {
+		return
+	}
+	t := 1.0
+	if f.duration > 0 {
+		t = float64(time.Since(f.start)) / float64(f.duration)
+	}
+	if t >= 1 {
+		setMusicLevel(f.to)
+		close(f.done)
+		currentFade = nil
+		return
+	}
+	setMusicLevel(f.from + (f.to-f.from)*t)
+}
+
+var (
+	// level is the music level set by fades, between 0 and 1.  It
+	// is guarded by levelMu.
+	levelMu sync.Mutex
+	level   = 1.0
+)
+
+// musicLevel returns the music level, between 0 and 1.
+func musicLevel() float64 {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	return level
+}
+
+// setMusicLevel sets the music level, between 0 and 1.
+func setMusicLevel(v float64) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	level = clamp(v, 0, 1)
+	updateMusicVolume()
+}
+
+// applyGain updates the music volume after the playing module changed,
+// so that its gain is taken into account.
+func applyGain() {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	updateMusicVolume()
+}
+
+// updateMusicVolume sets MikMod's music volume from the music level,
+// the gain of the playing module and ducking, unless ducking applies
+// per channel; see ReserveChannel.  It expects levelMu to be locked.
+func updateMusicVolume() {
+	g := duckGain()
+	if setChannelDuck(g) {
+		g = 1
+	}
+	if m := playingModule(); m != nil {
+		g *= m.Gain()
+	}
+	C.md_musicvolume = C.UBYTE(clamp(level*g, 0, 1)*128 + 0.5)
+}
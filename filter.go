@@ -0,0 +1,102 @@
+package mikmod
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Filter processes interleaved 16-bit PCM samples in format f, in
+// place.  Filters may keep state between calls, such as the history
+// of an IIR filter, so a filter should only be used by one stream.
+type Filter func(samples []int16, f Format)
+
+// LowPass returns a first-order low-pass filter with the given cutoff
+// frequency, in Hz.
+func LowPass(cutoff float64) Filter {
+	var prev []float64
+	return func(samples []int16, f Format) {
+		if len(prev) != f.Channels {
+			prev = make([]float64, f.Channels)
+		}
+		a := onePoleCoefficient(cutoff, f.SampleRate)
+		for i := range samples {
+			c := i % f.Channels
+			prev[c] += a * (float64(samples[i]) - prev[c])
+			samples[i] = clampInt16(prev[c])
+		}
+	}
+}
+
+// HighPass returns a first-order high-pass filter with the given
+// cutoff frequency, in Hz.
+func HighPass(cutoff float64) Filter {
+	var low []float64
+	return func(samples []int16, f Format) {
+		if len(low) != f.Channels {
+			low = make([]float64, f.Channels)
+		}
+		a := onePoleCoefficient(cutoff, f.SampleRate)
+		for i := range samples {
+			c := i % f.Channels
+			v := float64(samples[i])
+			low[c] += a * (v - low[c])
+			samples[i] = clampInt16(v - low[c])
+		}
+	}
+}
+
+// StereoWidth returns a filter scaling the stereo image: 0 collapses
+// it to mono, 1 leaves it unchanged and values above 1 widen it.  It
+// does nothing to mono output.
+func StereoWidth(width float64) Filter {
+	return func(samples []int16, f Format) {
+		if f.Channels != 2 {
+			return
+		}
+		for i := 0; i+1 < len(samples); i += 2 {
+			l, r := float64(samples[i]), float64(samples[i+1])
+			mid, side := (l+r)/2, (l-r)/2*width
+			samples[i] = clampInt16(mid + side)
+			samples[i+1] = clampInt16(mid - side)
+		}
+	}
+}
+
+// onePoleCoefficient returns the smoothing coefficient of a one-pole
+// filter with the given cutoff frequency.
+func onePoleCoefficient(cutoff float64, sampleRate int) float64 {
+	return 1 - math.Exp(-2*math.Pi*cutoff/float64(sampleRate))
+}
+
+// clampInt16 rounds v to the nearest 16-bit sample value.
+func clampInt16(v float64) int16 {
+	return int16(math.Round(clamp(v, math.MinInt16, math.MaxInt16)))
+}
+
+// filterChain applies filters to 16-bit PCM data.
+type filterChain struct {
+	filters []Filter
+	samples []int16
+}
+
+// apply runs the filters over PCM data b in format f, in place.  It
+// does nothing unless f has 16-bit samples.
+func (fc *filterChain) apply(b []byte, f Format) {
+	if len(fc.filters) == 0 || f.BitsPerSample != 16 {
+		return
+	}
+	n := len(b) / 2
+	if cap(fc.samples) < n {
+		fc.samples = make([]int16, n)
+	}
+	samples := fc.samples[:n]
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(b[i*2:]))
+	}
+	for _, filter := range fc.filters {
+		filter(samples, f)
+	}
+	for i, v := range samples {
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(v))
+	}
+}
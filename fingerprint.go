@@ -0,0 +1,91 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"unsafe"
+)
+
+// sampleFingerprintFlags are the sample flags that affect how a sample
+// sounds.
+const sampleFingerprintFlags = C.SF_LOOP | C.SF_BIDI | C.SF_16BITS | C.SF_STEREO
+
+// Fingerprint returns a SHA-256 digest of the module's musical content:
+// its order table, pattern data, and the properties of its samples and
+// instruments, but not its title, comment, names or file format
+// details, so that copies of a module differing only in those have the
+// same fingerprint.  libmikmod hands sample data over to the mixer, so
+// samples are represented by their length, loop points, rate and
+// volume rather than by their data.  Songs converted between formats
+// usually get different fingerprints.
+func (m *Module) Fingerprint() []byte {
+	return call(func() []byte {
+		mod := m.module
+		h := sha256.New()
+		put := func(vs ...int) { putInts(h, vs...) }
+
+		put(int(mod.numchn), int(mod.initspeed), int(mod.inittempo), int(mod.initvolume))
+		put(m.Orders()...)
+		numChn := int(mod.numchn)
+		if mod.patterns != nil && mod.pattrows != nil && mod.tracks != nil {
+			rows := unsafe.Slice(mod.pattrows, mod.numpat)
+			patterns := unsafe.Slice(mod.patterns, int(mod.numpat)*numChn)
+			tracks := unsafe.Slice(mod.tracks, mod.numtrk)
+			for pat := range int(mod.numpat) {
+				put(int(rows[pat]))
+				for _, track := range patterns[pat*numChn:][:numChn] {
+					if int(track) < len(tracks) {
+						h.Write(trackBytes(tracks[track]))
+					}
+					put(-1)
+				}
+			}
+		}
+		if mod.samples != nil {
+			for _, s := range unsafe.Slice(mod.samples, mod.numsmp) {
+				put(int(s.length), int(s.loopstart), int(s.loopend),
+					int(s.flags&sampleFingerprintFlags), int(s.speed),
+					int(s.volume), int(s.globvol), int(s.panning))
+			}
+		}
+		if mod.instruments != nil {
+			for _, ins := range unsafe.Slice(mod.instruments, mod.numins) {
+				put(int(ins.globvol), int(ins.panning), int(ins.volfade))
+			}
+		}
+		return h.Sum(nil)
+	})
+}
+
+// putInts writes vs to h as 32-bit little-endian integers.
+func putInts(h hash.Hash, vs ...int) {
+	var b [4]byte
+	for _, v := range vs {
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		h.Write(b[:])
+	}
+}
+
+// trackBytes returns the data of UniMod track t, made of rows whose
+// first byte holds the row's length in its low 5 bits, and ending with
+// a 0 byte, which is not included.
+func trackBytes(t *C.UBYTE) []byte {
+	if t == nil {
+		return nil
+	}
+	n := 0
+	for {
+		l := int(*(*C.UBYTE)(unsafe.Add(unsafe.Pointer(t), n)) & 0x1f)
+		if l == 0 {
+			break
+		}
+		n += l
+	}
+	return C.GoBytes(unsafe.Pointer(t), C.int(n))
+}
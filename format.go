@@ -0,0 +1,49 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+// OutputFormat returns the format MikMod's software mixer produces,
+// as negotiated with the output driver during initialization.
+func OutputFormat() Format { return currentFormat() }
+
+// MixFrequency returns the mixing frequency, in Hz, which is the
+// sample rate of MikMod's output.
+func MixFrequency() int { return mixFrequency() }
+
+// SetMixFrequency changes the mixing frequency, in Hz.  The driver is
+// reset for the change to take effect if MikMod is initialized; the
+// driver may settle on a different frequency, which MixFrequency then
+// reports.
+func SetMixFrequency(hz int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	unapplyPitch()
+	C.md_mixfreq = C.UWORD(hz)
+	if initCount == 0 {
+		return nil
+	}
+	return reset()
+}
+
+// currentFormat returns the format MikMod is currently configured to
+// mix in.
+func currentFormat() Format {
+	f := Format{
+		SampleRate:    mixFrequency(),
+		Channels:      1,
+		BitsPerSample: 8,
+	}
+	if C.md_mode&C.DMODE_STEREO != 0 {
+		f.Channels = 2
+	}
+	switch {
+	case C.md_mode&C.DMODE_FLOAT != 0:
+		f.BitsPerSample = 32
+	case C.md_mode&C.DMODE_16BITS != 0:
+		f.BitsPerSample = 16
+	}
+	return f
+}
@@ -0,0 +1,67 @@
+package mikmod
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits FuzzLoad applies, tighter than LoadUntrusted's defaults so
+// that fuzzers spend their time on the loaders.
+const (
+	fuzzMaxSize = 1 << 20
+	fuzzTimeout = 2 * time.Second
+)
+
+var (
+	fuzzInitOnce sync.Once
+	fuzzInitErr  error
+)
+
+// FuzzLoad loads b as LoadUntrusted does, with strict limits, and
+// inspects and closes the module, as a fuzzing entry point for the
+// loaders, e.g. with go test -fuzz:
+//
+//	func FuzzLoad(f *testing.F) {
+//		for _, b := range mikmod.FuzzSeeds() {
+//			f.Add(b)
+//		}
+//		f.Fuzz(func(t *testing.T, b []byte) { mikmod.FuzzLoad(b) })
+//	}
+//
+// MikMod is initialized with the nosound driver on first use, and left
+// initialized.  FuzzLoad returns 1 if b loaded and 0 otherwise, as
+// go-fuzz expects, and panics if MikMod cannot be initialized or a
+// load times out, as those are findings too.
+func FuzzLoad(b []byte) int {
+	fuzzInitOnce.Do(func() { fuzzInitErr = InitNoSound() })
+	if fuzzInitErr != nil {
+		panic(fuzzInitErr)
+	}
+	m, err := LoadUntrusted(b, UntrustedOptions{MaxSize: fuzzMaxSize, Timeout: fuzzTimeout})
+	if err == ErrLoadTimeout {
+		panic(err)
+	}
+	if err != nil {
+		return 0
+	}
+	defer m.Close()
+	m.Title()
+	m.Tracker()
+	m.Comment()
+	m.NumPositions()
+	return 1
+}
+
+// FuzzSeeds returns inputs to seed a fuzzing corpus with: a minimal
+// ProTracker module, from which fuzzers reach the other formats'
+// headers quickly.
+func FuzzSeeds() [][]byte {
+	// Title, 31 samples, song length, restart position, order
+	// table, signature and one empty pattern.
+	mod := make([]byte, 20+31*30+2+128+4+64*4*4)
+	copy(mod, "fuzz")
+	mod[950] = 1
+	mod[951] = 127
+	copy(mod[1080:], "M.K.")
+	return [][]byte{mod}
+}
@@ -0,0 +1,16 @@
+package mikmod_test
+
+import (
+	"testing"
+
+	"github.com/death/go-mikmod"
+)
+
+// FuzzLoad fuzzes the loaders, from FuzzSeeds and the corpus in
+// testdata/fuzz/FuzzLoad, e.g. with go test -fuzz FuzzLoad.
+func FuzzLoad(f *testing.F) {
+	for _, b := range mikmod.FuzzSeeds() {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) { mikmod.FuzzLoad(b) })
+}
@@ -0,0 +1,7 @@
+module github.com/death/go-mikmod
+
+go 1.24
+
+require golang.org/x/term v0.30.0
+
+require golang.org/x/sys v0.31.0 // indirect
@@ -0,0 +1,87 @@
+#include <mikmod.h>
+#include "_cgo_export.h"
+
+static void GoDriver_CommandLine(const CHAR *cmdline)
+{
+	goDriverCommandLine((char *)cmdline);
+}
+
+static BOOL GoDriver_IsPresent(void)
+{
+	return 1;
+}
+
+static int GoDriver_Init(void)
+{
+	if (VC_Init())
+		return 1;
+	if (goDriverInit()) {
+		VC_Exit();
+		MikMod_errno = MMERR_OPENING_AUDIO;
+		return 1;
+	}
+	return 0;
+}
+
+static void GoDriver_Exit(void)
+{
+	goDriverExit();
+	VC_Exit();
+}
+
+static int GoDriver_PlayStart(void)
+{
+	if (VC_PlayStart())
+		return 1;
+	if (goDriverPlayStart()) {
+		VC_PlayStop();
+		MikMod_errno = MMERR_OPENING_AUDIO;
+		return 1;
+	}
+	return 0;
+}
+
+static void GoDriver_PlayStop(void)
+{
+	goDriverPlayStop();
+	VC_PlayStop();
+}
+
+static void GoDriver_Update(void)
+{
+	goDriverUpdate();
+}
+
+MDRIVER drv_go = {
+	.Name = "Go",
+	.Version = "Go output driver",
+	.HardVoiceLimit = 0,
+	.SoftVoiceLimit = 255,
+	.Alias = "go",
+	.CmdLineHelp = NULL,
+	.CommandLine = GoDriver_CommandLine,
+	.IsPresent = GoDriver_IsPresent,
+	.SampleLoad = VC_SampleLoad,
+	.SampleUnload = VC_SampleUnload,
+	.FreeSampleSpace = VC_SampleSpace,
+	.RealSampleLength = VC_SampleLength,
+	.Init = GoDriver_Init,
+	.Exit = GoDriver_Exit,
+	.Reset = NULL,
+	.SetNumVoices = VC_SetNumVoices,
+	.PlayStart = GoDriver_PlayStart,
+	.PlayStop = GoDriver_PlayStop,
+	.Update = GoDriver_Update,
+	.Pause = NULL,
+	.VoiceSetVolume = VC_VoiceSetVolume,
+	.VoiceGetVolume = VC_VoiceGetVolume,
+	.VoiceSetFrequency = VC_VoiceSetFrequency,
+	.VoiceGetFrequency = VC_VoiceGetFrequency,
+	.VoiceSetPanning = VC_VoiceSetPanning,
+	.VoiceGetPanning = VC_VoiceGetPanning,
+	.VoicePlay = VC_VoicePlay,
+	.VoiceStop = VC_VoiceStop,
+	.VoiceStopped = VC_VoiceStopped,
+	.VoiceGetPosition = VC_VoiceGetPosition,
+	.VoiceRealVolume = VC_VoiceRealVolume,
+};
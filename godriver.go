@@ -0,0 +1,152 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+extern MDRIVER drv_go;
+*/
+import "C"
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// OutputDriver is an output driver implemented in Go.  MikMod mixes
+// in software and the driver is responsible for sending the mixed PCM
+// data wherever it needs to go.
+type OutputDriver interface {
+	// CommandLine receives the driver arguments, before Init is
+	// called.
+	CommandLine(args string)
+
+	// Init prepares the driver to receive PCM data in format f.
+	Init(f Format) error
+
+	// Exit releases the driver's resources.
+	Exit()
+
+	// PlayStart is called when playback starts.
+	PlayStart() error
+
+	// PlayStop is called when playback stops.
+	PlayStop()
+
+	// Update is called periodically during playback.  The driver
+	// should call mix with a buffer of its choosing, which mix fills
+	// with PCM data, returning the number of bytes written, and then
+	// output that data.
+	Update(mix func(b []byte) int)
+}
+
+// PullDriver is an OutputDriver for audio devices that request PCM
+// data from a callback, such as oto, PortAudio or miniaudio.  Rather
+// than being fed through Update on the update loop's timer, it is
+// handed a function that mixes exactly as much audio as the device
+// asks for, so that the device's buffer size and the update period
+// cannot disagree.  The update loop still handles fades, clocks and
+// starting the next module.
+type PullDriver interface {
+	OutputDriver
+
+	// StartPull is called instead of PlayStart when playback
+	// starts.  Until PlayStop is called, the device's callback
+	// should call fill with each buffer to fill, which fill fills
+	// with PCM data, returning the number of bytes written.
+	//
+	// PlayStop is called with MikMod locked, as is fill, so
+	// PlayStop must not wait for a callback in progress.
+	StartPull(fill func(b []byte) int) error
+}
+
+// pulling is true while a PullDriver is started.
+var pulling atomic.Bool
+
+var (
+	goDriver      OutputDriver
+	goDriverName  *C.char
+	goDriverAlias *C.char
+)
+
+// RegisterOutputDriver registers an output driver implemented in Go,
+// returning a Driver that can be selected in Options.  Only one Go
+// output driver can exist at a time; registering another replaces
+// the previous one.  Do not call it while MikMod is initialized.
+func RegisterOutputDriver(name string, alias string, d OutputDriver) *Driver {
+	if goDriverName != nil {
+		C.free(unsafe.Pointer(goDriverName))
+		C.free(unsafe.Pointer(goDriverAlias))
+	}
+	goDriver = d
+	goDriverName = C.CString(name)
+	goDriverAlias = C.CString(alias)
+	C.drv_go.Name = (*C.CHAR)(goDriverName)
+	C.drv_go.Alias = (*C.CHAR)(goDriverAlias)
+	mu.Lock()
+	statusDriver.driver = nil
+	mu.Unlock()
+	drv := &Driver{driver: &C.drv_go}
+	drv.register()
+	return drv
+}
+
+//export goDriverCommandLine
+func goDriverCommandLine(cmdline *C.char) {
+	goDriver.CommandLine(C.GoString(cmdline))
+}
+
+//export goDriverInit
+func goDriverInit() C.int {
+	if err := goDriver.Init(currentFormat()); err != nil {
+		return 1
+	}
+	return 0
+}
+
+//export goDriverExit
+func goDriverExit() {
+	goDriver.Exit()
+}
+
+//export goDriverPlayStart
+func goDriverPlayStart() C.int {
+	var err error
+	if d, ok := goDriver.(PullDriver); ok {
+		pulling.Store(true)
+		if err = d.StartPull(pull); err != nil {
+			pulling.Store(false)
+		}
+	} else {
+		err = goDriver.PlayStart()
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+//export goDriverPlayStop
+func goDriverPlayStop() {
+	pulling.Store(false)
+	goDriver.PlayStop()
+}
+
+// pull fills b with PCM data for a PullDriver, or with silence once
+// the driver has been stopped.
+func pull(b []byte) int {
+	if !pulling.Load() {
+		clear(b)
+		if C.md_mode&C.DMODE_16BITS == 0 && C.md_mode&C.DMODE_FLOAT == 0 {
+			for i := range b {
+				b[i] = 0x80
+			}
+		}
+		return len(b)
+	}
+	return mix(b)
+}
+
+//export goDriverUpdate
+func goDriverUpdate() {
+	goDriver.Update(writeBytes)
+}
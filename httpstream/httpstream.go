@@ -0,0 +1,78 @@
+// Package httpstream serves the rendered audio of MikMod modules over
+// HTTP, as WAV streams, so that web applications can play modules with
+// a plain <audio> element.
+//
+// Initialize MikMod with mikmod.InitNoSound, so that libmikmod itself
+// does not open an audio device.  libmikmod plays one module at a
+// time, so requests are served one after the other.
+package httpstream
+
+import (
+	"net/http"
+
+	"github.com/death/go-mikmod"
+)
+
+// bufferSize is the number of bytes read from the stream at a time.
+const bufferSize = 16384
+
+// busy serializes streams across all handlers, as libmikmod plays one
+// module at a time.
+var busy = make(chan struct{}, 1)
+
+// Handler is an http.Handler streaming a module's audio as WAV.
+type Handler struct {
+	module *mikmod.Module
+	opts   mikmod.StreamOptions
+}
+
+// NewHandler returns a handler streaming m, mixed as specified by
+// opts.  The caller remains responsible for closing m once the handler
+// is no longer used, and should not play m by other means meanwhile.
+func NewHandler(m *mikmod.Module, opts mikmod.StreamOptions) *Handler {
+	return &Handler{module: m, opts: opts}
+}
+
+// ServeHTTP streams the module from its start.  The response is sent
+// with chunked encoding as it is mixed, until the song ends or the
+// client goes away.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	select {
+	case busy <- struct{}{}:
+		defer func() { <-busy }()
+	case <-r.Context().Done():
+		return
+	}
+
+	stream, err := mikmod.NewStream(h.module, h.opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "audio/wav")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := mikmod.WriteWAVHeader(w, stream.Format(), mikmod.WAVStreamSize); err != nil {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, bufferSize)
+	for r.Context().Err() == nil {
+		n, err := stream.Read(buf)
+		if _, werr := w.Write(buf[:n]); werr != nil || err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
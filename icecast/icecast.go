@@ -0,0 +1,308 @@
+//go:build cgo
+
+// Package icecast feeds MikMod's rendered audio to an Icecast or
+// SHOUTcast server as a source client, updating the stream's metadata
+// with the title of each module played, so that a tracker music web
+// radio can run entirely from Go.
+//
+// Initialize MikMod with mikmod.InitNoSound, so that libmikmod itself
+// does not open an audio device, and supply an encoder for the
+// stream's format, e.g. MP3:
+//
+//	src, err := icecast.Dial(ctx, icecast.Config{
+//		URL:         "http://localhost:8000/radio.mp3",
+//		Password:    "hackme",
+//		ContentType: "audio/mpeg",
+//		Encoder:     mp3Encoder,
+//		Name:        "Tracker radio",
+//	})
+//	...
+//	for _, m := range modules {
+//		if err := src.Play(m, mikmod.StreamOptions{}); err != nil {
+//			...
+//		}
+//	}
+//	src.Close()
+package icecast
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/death/go-mikmod"
+)
+
+// defaultTimeout bounds connecting, each write and metadata updates,
+// unless Config.Timeout says otherwise.
+const defaultTimeout = 10 * time.Second
+
+// Config describes the mountpoint a Source feeds.
+type Config struct {
+	// URL is the URL of the mountpoint, e.g.
+	// "http://localhost:8000/radio.mp3", or https for TLS.  The port
+	// defaults to Icecast's, 8000.
+	URL string
+
+	// User and Password authenticate the source.  If User is "",
+	// Icecast's default, "source", is used.
+	User     string
+	Password string
+
+	// ContentType is the MIME type of the encoded stream, e.g.
+	// "audio/mpeg" or "audio/ogg", and Encoder encodes it.
+	ContentType string
+	Encoder     mikmod.Encoder
+
+	// Name, Description, Genre and Public describe the stream in the
+	// server's directory listings.
+	Name        string
+	Description string
+	Genre       string
+	Public      bool
+
+	// Legacy makes the source connect with the SOURCE method, for
+	// servers older than Icecast 2.4 and SHOUTcast, rather than
+	// with PUT.
+	Legacy bool
+
+	// Timeout, if not 0, replaces the 10 second limit on connecting,
+	// each write and metadata updates.
+	Timeout time.Duration
+}
+
+// timeout returns the timeout to apply.
+func (c Config) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultTimeout
+}
+
+// auth returns the value of the Authorization header for c.
+func (c Config) auth() string {
+	user := c.User
+	if user == "" {
+		user = "source"
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+c.Password))
+}
+
+var (
+	errNoEncoder = errors.New("icecast: no encoder")
+	errClosed    = errors.New("icecast: source is closed")
+)
+
+// Source is a connection feeding a mountpoint.  It is a mikmod.Sink:
+// the samples written are encoded and sent in real time, as listeners
+// hear them.  It is safe for concurrent use.
+type Source struct {
+	cfg  Config
+	url  *url.URL
+	conn net.Conn
+
+	mu      sync.Mutex
+	enc     io.WriteCloser
+	format  mikmod.Format
+	buf     []byte
+	start   time.Time
+	sent    time.Duration
+	closed  bool
+	closing error
+}
+
+// Dial connects to the mountpoint described by cfg, and returns a
+// source feeding it.  Close it when done.
+func Dial(ctx context.Context, cfg Config) (*Source, error) {
+	if cfg.Encoder == nil {
+		return nil, errNoEncoder
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "8000")
+	}
+	d := &net.Dialer{Timeout: cfg.timeout()}
+	var conn net.Conn
+	if u.Scheme == "https" {
+		conn, err = (&tls.Dialer{NetDialer: d}).DialContext(ctx, "tcp", u.Host)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", u.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{cfg: cfg, url: u, conn: conn}
+	if err := s.handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// handshake sends the source request, and waits for the server to
+// accept it.
+func (s *Source) handshake() error {
+	method := http.MethodPut
+	if s.cfg.Legacy {
+		method = "SOURCE"
+	}
+	public := "0"
+	if s.cfg.Public {
+		public = "1"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", method, s.url.RequestURI())
+	header := http.Header{}
+	header.Set("Host", s.url.Host)
+	header.Set("Authorization", s.cfg.auth())
+	header.Set("User-Agent", "go-mikmod")
+	header.Set("Content-Type", s.cfg.ContentType)
+	header.Set("Ice-Name", s.cfg.Name)
+	header.Set("Ice-Description", s.cfg.Description)
+	header.Set("Ice-Genre", s.cfg.Genre)
+	header.Set("Ice-Public", public)
+	if !s.cfg.Legacy {
+		header.Set("Expect", "100-continue")
+	}
+	header.Write(&b)
+	b.WriteString("\r\n")
+
+	s.conn.SetDeadline(time.Now().Add(s.cfg.timeout()))
+	defer s.conn.SetDeadline(time.Time{})
+	if _, err := io.WriteString(s.conn, b.String()); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(s.conn), &http.Request{Method: method})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusContinue && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("icecast: server refused the source: %s", resp.Status)
+	}
+	return nil
+}
+
+// WriteSamples encodes samples and sends them, waiting as needed so as
+// not to get ahead of real time by more than half the timeout, as
+// servers would buffer or drop the excess.
+func (s *Source) WriteSamples(samples []int16, sampleRate, channels int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errClosed
+	}
+	f := mikmod.Format{SampleRate: sampleRate, Channels: channels, BitsPerSample: 16}
+	if s.enc == nil {
+		enc, err := s.cfg.Encoder.Encode(&deadlineWriter{s.conn, s.cfg.timeout()}, f)
+		if err != nil {
+			return err
+		}
+		s.enc, s.format, s.start = enc, f, time.Now()
+	} else if f != s.format {
+		return fmt.Errorf("icecast: format changed from %+v to %+v", s.format, f)
+	}
+	s.buf = s.buf[:0]
+	for _, v := range samples {
+		s.buf = append(s.buf, byte(v), byte(v>>8))
+	}
+	if _, err := s.enc.Write(s.buf); err != nil {
+		return err
+	}
+	s.sent += time.Duration(len(samples)/channels) * time.Second / time.Duration(sampleRate)
+	if ahead := s.sent - time.Since(s.start) - s.cfg.timeout()/2; ahead > 0 {
+		time.Sleep(ahead)
+	}
+	return nil
+}
+
+// deadlineWriter is a connection writer failing writes taking longer
+// than timeout.
+type deadlineWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	return w.conn.Write(b)
+}
+
+// SetTitle updates the title listeners see, through the server's admin
+// interface, which only supports it for MP3 and AAC streams.
+func (s *Source) SetTitle(ctx context.Context, title string) error {
+	u := *s.url
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	u.Path = "/admin/metadata"
+	u.RawQuery = url.Values{
+		"mount":   {s.url.Path},
+		"mode":    {"updinfo"},
+		"song":    {title},
+		"charset": {"UTF-8"},
+	}.Encode()
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", s.cfg.auth())
+	req.Header.Set("User-Agent", "go-mikmod")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("icecast: updating metadata: %s", resp.Status)
+	}
+	return nil
+}
+
+// Play streams m, mixed as specified by opts, to the mountpoint until
+// the song ends, after setting the title to m's.  Failing to set the
+// title does not stop it.  Any module currently playing is stopped,
+// and m should not be played by other means meanwhile.
+func (s *Source) Play(m *mikmod.Module, opts mikmod.StreamOptions) error {
+	if title := m.Title(); title != "" {
+		s.SetTitle(context.Background(), title)
+	}
+	stream, err := mikmod.NewStream(m, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	return stream.CopyTo(s)
+}
+
+// Close flushes the encoder and disconnects from the server.  Closing
+// a source again returns the same error.
+func (s *Source) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return s.closing
+	}
+	s.closed = true
+	if s.enc != nil {
+		s.closing = s.enc.Close()
+	}
+	if err := s.conn.Close(); s.closing == nil {
+		s.closing = err
+	}
+	return s.closing
+}
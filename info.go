@@ -0,0 +1,128 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"slices"
+	"unsafe"
+)
+
+// ModuleInfo is a complete record of a module's metadata.
+type ModuleInfo struct {
+	Title           string           `json:"title"`
+	Tracker         string           `json:"tracker"`
+	Type            string           `json:"type"`
+	Comment         string           `json:"comment,omitempty"`
+	Channels        int              `json:"channels"`
+	Voices          int              `json:"voices"`
+	Patterns        int              `json:"patterns"`
+	InitialSpeed    int              `json:"initialSpeed"`
+	InitialTempo    int              `json:"initialTempo"`
+	InitialVolume   int              `json:"initialVolume"`
+	RestartPosition int              `json:"restartPosition"`
+	Orders          []int            `json:"orders"`
+	Instruments     []InstrumentInfo `json:"instruments"`
+	Samples         []SampleInfo     `json:"samples"`
+}
+
+// InstrumentInfo describes an instrument of a module.
+type InstrumentInfo struct {
+	Name         string `json:"name"`
+	GlobalVolume int    `json:"globalVolume"`
+	Panning      int    `json:"panning"`
+	VolumeFade   int    `json:"volumeFade"`
+}
+
+// SampleInfo describes a sample of a module.  Lengths and loop points
+// are in sample frames.
+type SampleInfo struct {
+	Name         string `json:"name"`
+	Length       int    `json:"length"`
+	LoopStart    int    `json:"loopStart"`
+	LoopEnd      int    `json:"loopEnd"`
+	Looping      bool   `json:"looping"`
+	Bidi         bool   `json:"bidi"`
+	SixteenBit   bool   `json:"16bit"`
+	Stereo       bool   `json:"stereo"`
+	Speed        int    `json:"speed"`
+	Volume       int    `json:"volume"`
+	GlobalVolume int    `json:"globalVolume"`
+	Panning      int    `json:"panning"`
+}
+
+// Info returns the module's metadata, including its order table,
+// instruments and samples.
+func (m *Module) Info() ModuleInfo {
+	mod := m.mod()
+	info := ModuleInfo{
+		Title:           m.Title(),
+		Tracker:         m.Tracker(),
+		Type:            m.Type().String(),
+		Comment:         m.Comment(),
+		Channels:        m.NumChannels(),
+		Voices:          m.NumVoices(),
+		Patterns:        m.NumPatterns(),
+		InitialSpeed:    int(mod.initspeed),
+		InitialTempo:    int(mod.inittempo),
+		InitialVolume:   int(mod.initvolume),
+		RestartPosition: int(mod.reppos),
+		Orders:          m.Orders(),
+		Instruments:     []InstrumentInfo{},
+		Samples:         []SampleInfo{},
+	}
+	if mod.instruments != nil {
+		for _, ins := range unsafe.Slice(mod.instruments, mod.numins) {
+			info.Instruments = append(info.Instruments, InstrumentInfo{
+				Name:         m.text(ins.insname),
+				GlobalVolume: int(ins.globvol),
+				Panning:      int(ins.panning),
+				VolumeFade:   int(ins.volfade),
+			})
+		}
+	}
+	if mod.samples != nil {
+		for _, s := range unsafe.Slice(mod.samples, mod.numsmp) {
+			info.Samples = append(info.Samples, SampleInfo{
+				Name:         m.text(s.samplename),
+				Length:       int(s.length),
+				LoopStart:    int(s.loopstart),
+				LoopEnd:      int(s.loopend),
+				Looping:      s.flags&C.SF_LOOP != 0,
+				Bidi:         s.flags&C.SF_BIDI != 0,
+				SixteenBit:   s.flags&C.SF_16BITS != 0,
+				Stereo:       s.flags&C.SF_STEREO != 0,
+				Speed:        int(s.speed),
+				Volume:       int(s.volume),
+				GlobalVolume: int(s.globvol),
+				Panning:      int(s.panning),
+			})
+		}
+	}
+	return info
+}
+
+// Orders returns the module's order table, i.e. the pattern played at
+// each song position.
+func (m *Module) Orders() []int {
+	orders := []int{}
+	if m.mod().positions == nil {
+		return orders
+	}
+	for _, pat := range unsafe.Slice(m.module.positions, m.module.numpos) {
+		orders = append(orders, int(pat))
+	}
+	return orders
+}
+
+// PatternPosition returns the first song position playing pattern
+// pat, or -1 if no position plays it.
+func (m *Module) PatternPosition(pat int) int {
+	return slices.Index(m.Orders(), pat)
+}
+
+// MarshalJSON encodes the module's metadata as returned by Info.
+func (m *Module) MarshalJSON() ([]byte, error) { return json.Marshal(m.Info()) }
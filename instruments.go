@@ -0,0 +1,297 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+#define maxHandles 1024
+#define maxVoices 256
+#define maxHooks 8
+
+typedef void (*voicePlayFunc)(UBYTE, SWORD, ULONG, ULONG, ULONG, ULONG, UWORD);
+
+// handleMuted flags the samples muted by MuteInstrument, by handle, and
+// voiceMuted the voices playing them.
+static UBYTE handleMuted[maxHandles];
+static UBYTE voiceMuted[maxVoices];
+static int muting;
+
+// voicePlays counts the samples started on each voice, telling Voice
+// handles apart.
+static ULONG voicePlays[maxVoices];
+
+// hooks are the drivers whose VoicePlay was replaced by mutingVoicePlay,
+// along with the original.
+static struct {
+	MDRIVER *driver;
+	voicePlayFunc play;
+} hooks[maxHooks];
+static int numHooks;
+
+// mutingVoicePlay records whether the sample started on voice is
+// muted, and counts it, then starts it.  It stands in for the driver's
+// VoicePlay, as the player cannot be queried for the samples its
+// voices play.
+static void mutingVoicePlay(UBYTE voice, SWORD handle, ULONG start, ULONG size,
+		ULONG reppos, ULONG repend, UWORD flags) {
+	int i;
+	voiceMuted[voice] = handle >= 0 && handle < maxHandles && handleMuted[handle];
+	voicePlays[voice]++;
+	for (i = 0; i < numHooks; i++)
+		if (hooks[i].driver == md_driver) {
+			hooks[i].play(voice, handle, start, size, reppos, repend, flags);
+			return;
+		}
+}
+
+// hookDriver makes the driver in use call mutingVoicePlay, if it does
+// not yet.  It expects MikMod to be locked.
+static void hookDriver(void) {
+	if (md_driver == NULL || md_driver->VoicePlay == mutingVoicePlay || numHooks == maxHooks)
+		return;
+	hooks[numHooks].driver = md_driver;
+	hooks[numHooks].play = md_driver->VoicePlay;
+	numHooks++;
+	md_driver->VoicePlay = mutingVoicePlay;
+}
+
+static void hookDriverLocked(void) {
+	MikMod_Lock();
+	hookDriver();
+	MikMod_Unlock();
+}
+
+static ULONG voicePlayCount(int voice) {
+	return voicePlays[voice];
+}
+
+static void setHandleMuted(int handle, int on) {
+	MikMod_Lock();
+	muting = 1;
+	hookDriver();
+	if (handle >= 0 && handle < maxHandles)
+		handleMuted[handle] = on;
+	MikMod_Unlock();
+}
+
+// muteInstrumentVoices silences the music voices playing muted
+// samples, after the player set their volume for the tick.  It is
+// called by overridingPlayer.
+void muteInstrumentVoices(void) {
+	int v;
+	// The driver may have changed since the last tick.
+	hookDriver();
+	if (!muting)
+		return;
+	for (v = 0; v < md_sngchn && v < maxVoices; v++)
+		if (voiceMuted[v])
+			md_driver->VoiceSetVolume(v, 0);
+}
+
+// instrumentOperands stores in ops, up to max of them, pointers to the
+// operands of the instrument opcodes of the n tracks, and returns the
+// number found.  Like decodeCell, it only looks at the notes,
+// instruments and ProTracker effects leading each row.
+static int instrumentOperands(UBYTE **tracks, int n, UBYTE **ops, int max) {
+	int i, count = 0;
+	for (i = 0; i < n; i++) {
+		UBYTE *t = tracks[i];
+		if (t == NULL)
+			continue;
+		for (; *t & 0x1f; t += *t & 0x1f) {
+			UBYTE *p, *end = t + (*t & 0x1f);
+			for (p = t + 1; p + 1 < end; p += 2) {
+				if (*p == 2) {
+					if (count < max)
+						ops[count] = p + 1;
+					count++;
+				} else if (*p != 1 && (*p < 3 || *p > 18))
+					break;
+			}
+		}
+	}
+	return count;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"slices"
+	"unsafe"
+)
+
+// hookVoices makes the driver count the samples started on each voice,
+// for Voice.  It is called by Init; the update loop hooks drivers
+// changed afterwards.
+func hookVoices() { C.hookDriverLocked() }
+
+// voicePlays returns the number of samples started on voice v since it
+// was hooked, wrapping around at 2^32.  It expects to be called on the
+// actor.
+func voicePlays(v int) uint32 { return uint32(C.voicePlayCount(C.int(v))) }
+
+// instrumentEdits records the changes made to a module by MuteInstrument
+// and RemapInstrument.
+type instrumentEdits struct {
+	muted map[int]bool
+	remap map[int]int
+
+	// operands point to the instrument operands of the module's
+	// tracks, and original holds their values as loaded.
+	operands []*C.UBYTE
+	original []C.UBYTE
+}
+
+var errInvalidInstrument = errors.New("mikmod: invalid instrument")
+
+// numInstruments returns the number of instruments of the module, or
+// of samples if it has no instruments, as patterns refer to them.
+func (m *Module) numInstruments() int {
+	if m.module.flags&C.UF_INST != 0 {
+		return m.NumInstruments()
+	}
+	return m.NumSamples()
+}
+
+// edited returns the module's instrument edits, creating them if
+// needed.
+func (m *Module) edited() *instrumentEdits {
+	if m.edits == nil {
+		m.edits = &instrumentEdits{muted: map[int]bool{}, remap: map[int]int{}}
+	}
+	return m.edits
+}
+
+// MuteInstrument silences every note the module plays with instrument
+// ins, counted from 0 as in PatternCell, on all channels, e.g. to
+// audition a module minus its lead, or unmutes it.  Samples shared
+// with other instruments are muted for them too.  Notes already
+// playing when the instrument is muted are not cut.
+func (m *Module) MuteInstrument(ins int, mute bool) error {
+	if err := m.usable(); err != nil {
+		return err
+	}
+	if ins < 0 || ins >= m.numInstruments() {
+		return errInvalidInstrument
+	}
+	e := m.edited()
+	if mute {
+		e.muted[ins] = true
+	} else {
+		delete(e.muted, ins)
+	}
+	do(func() {
+		overridePlayer()
+		m.setSamplesMuted(false)
+		m.setSamplesMuted(true)
+	})
+	return nil
+}
+
+// InstrumentMuted returns true if instrument ins is muted by
+// MuteInstrument, and false otherwise.
+func (m *Module) InstrumentMuted(ins int) bool {
+	return m.edits != nil && m.edits.muted[ins]
+}
+
+// setSamplesMuted sets the muting of the samples of the instruments
+// muted, or of all the module's samples if on is false.  It expects to
+// be called on the actor.
+func (m *Module) setSamplesMuted(on bool) {
+	samples := unsafe.Slice(m.module.samples, m.module.numsmp)
+	if !on {
+		for _, s := range samples {
+			C.setHandleMuted(C.int(s.handle), 0)
+		}
+		return
+	}
+	for ins := range m.edits.muted {
+		for _, smp := range m.instrumentSamples(ins) {
+			C.setHandleMuted(C.int(samples[smp].handle), 1)
+		}
+	}
+}
+
+// instrumentSamples returns the indices of the samples played by
+// instrument ins.
+func (m *Module) instrumentSamples(ins int) []int {
+	if m.module.flags&C.UF_INST == 0 {
+		return []int{ins}
+	}
+	var samples []int
+	for _, smp := range unsafe.Slice(m.module.instruments, m.module.numins)[ins].samplenumber {
+		if int(smp) < int(m.module.numsmp) && !slices.Contains(samples, int(smp)) {
+			samples = append(samples, int(smp))
+		}
+	}
+	return samples
+}
+
+// RemapInstrument makes the notes the module plays with instrument
+// from use instrument to instead, e.g. to try alternative samples
+// without editing the module, or restores them if to equals from.
+// Instruments count from 0, as in PatternCell.  It works by rewriting
+// the patterns as shown by PatternRows, so effects specific to the
+// original instrument, such as its default volume, are not carried
+// over.  It is experimental.
+func (m *Module) RemapInstrument(from, to int) error {
+	if err := m.usable(); err != nil {
+		return err
+	}
+	n := m.numInstruments()
+	if from < 0 || from >= n || to < 0 || to >= n {
+		return errInvalidInstrument
+	}
+	e := m.edited()
+	if from == to {
+		delete(e.remap, from)
+	} else {
+		e.remap[from] = to
+	}
+	if e.operands == nil {
+		m.findInstrumentOperands()
+	}
+	do(func() {
+		C.MikMod_Lock()
+		defer C.MikMod_Unlock()
+		for i, p := range e.operands {
+			v := e.original[i]
+			if to, ok := e.remap[int(v)]; ok {
+				v = C.UBYTE(to)
+			}
+			*p = v
+		}
+	})
+	return nil
+}
+
+// findInstrumentOperands records the instrument operands of the
+// module's tracks along with their values.
+func (m *Module) findInstrumentOperands() {
+	e := m.edits
+	mod := m.module
+	if mod.tracks == nil || mod.numtrk == 0 {
+		e.operands = []*C.UBYTE{}
+		return
+	}
+	tracks, n := mod.tracks, C.int(mod.numtrk)
+	count := C.instrumentOperands(tracks, n, nil, 0)
+	e.operands = make([]*C.UBYTE, count)
+	if count > 0 {
+		C.instrumentOperands(tracks, n, &e.operands[0], count)
+	}
+	e.original = make([]C.UBYTE, count)
+	for i, p := range e.operands {
+		e.original[i] = *p
+	}
+}
+
+// clearInstrumentEdits unmutes the module's samples before it is freed,
+// as their handles may be reused by other modules.  It expects to be
+// called on the actor.
+func (m *Module) clearInstrumentEdits() {
+	if m.edits != nil {
+		m.setSamplesMuted(false)
+		m.edits = nil
+	}
+}
@@ -0,0 +1,131 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// The jump policy, its limits being 0 if disabled, and the module it
+// watches: the last one started, which the player hook only sees
+// while it plays.
+static int maxRowRepeats, maxBackwardJumps;
+static MODULE *jumpModule;
+
+// The last position and row seen, the repeats of the rows of the
+// position and the backward jumps seen, and the action due.
+enum { jumpNone, jumpNext, jumpEnd };
+static int jumpPos, jumpRow, rowRepeats, backwardJumps, jumpAction;
+
+static void watchJumps(MODULE *mod) {
+	MikMod_Lock();
+	jumpModule = mod;
+	jumpPos = mod->sngpos;
+	jumpRow = mod->patpos;
+	rowRepeats = backwardJumps = 0;
+	jumpAction = jumpNone;
+	MikMod_Unlock();
+}
+
+// unwatchJumps stops watching mod, which is being freed, dropping any
+// action due for it.
+static void unwatchJumps(MODULE *mod) {
+	MikMod_Lock();
+	if (jumpModule == mod) {
+		jumpModule = NULL;
+		jumpAction = jumpNone;
+	}
+	MikMod_Unlock();
+}
+
+static void setJumpPolicy(int rows, int jumps) {
+	MikMod_Lock();
+	maxRowRepeats = rows;
+	maxBackwardJumps = jumps;
+	MikMod_Unlock();
+}
+
+// checkJumps counts the repeats of rows and the backward jumps of the
+// playing module, and records the action due once the policy's limits
+// are exceeded, as the player cannot be moved while it plays a tick.
+// It is called by overridingPlayer, in channeloverrides.go.
+void checkJumps(void) {
+	MODULE *mod = jumpModule;
+	int pos, row;
+	if (mod == NULL || (!maxRowRepeats && !maxBackwardJumps))
+		return;
+	pos = mod->sngpos;
+	row = mod->patpos;
+	if (pos == jumpPos && row == jumpRow)
+		return;
+	if (pos == jumpPos && row < jumpRow) {
+		if (maxRowRepeats && ++rowRepeats > maxRowRepeats)
+			jumpAction = jumpNext;
+	} else if (pos != jumpPos) {
+		rowRepeats = 0;
+		if (pos < jumpPos && maxBackwardJumps && ++backwardJumps > maxBackwardJumps)
+			jumpAction = jumpEnd;
+	}
+	jumpPos = pos;
+	jumpRow = row;
+}
+
+// takeJumpAction returns the action due, and clears it.
+static int takeJumpAction(void) {
+	int action;
+	MikMod_Lock();
+	action = jumpAction;
+	jumpAction = jumpNone;
+	MikMod_Unlock();
+	return action;
+}
+*/
+import "C"
+
+// JumpPolicy bounds the repeats caused by pattern loop (E6x) and
+// position jump effects, so that broken or malicious modules whose
+// songs would never end, e.g. with infinite pattern loops, end anyway,
+// as batch rendering needs.  The zero value disables it.
+type JumpPolicy struct {
+	// MaxRowRepeats, if not 0, is the number of times the player may
+	// go back to earlier rows of a song position, as pattern loops
+	// and jumps to the position itself do, before it breaks out to
+	// the next position.  Songs rarely loop a position more than 16
+	// times.
+	MaxRowRepeats int
+
+	// MaxBackwardJumps, if not 0, is the number of jumps back to an
+	// earlier song position after which the song ends.  Songs
+	// looping to their restart position, and seeking back, e.g.
+	// with PrevPosition, count too.
+	MaxBackwardJumps int
+}
+
+// SetJumpPolicy sets the policy applied to the playing module and the
+// ones played from now on, including when rendering.  Counts start
+// over when a module starts.
+func SetJumpPolicy(p JumpPolicy) {
+	do(func() { C.setJumpPolicy(C.int(max(p.MaxRowRepeats, 0)), C.int(max(p.MaxBackwardJumps, 0))) })
+}
+
+// watchJumps makes the jump policy apply to module m, which is
+// starting.  It expects to be called on the actor.
+func watchJumps(m *Module) { C.watchJumps(m.module) }
+
+// unwatchJumps stops the jump policy from applying to module m, which
+// is being freed.  It expects to be called on the actor.
+func unwatchJumps(m *Module) { C.unwatchJumps(m.module) }
+
+// applyJumpPolicy moves the player as the jump policy requires, once
+// its limits are exceeded.  It is called by the update loop and after
+// mixing when rendering, on the actor, with MikMod unlocked.
+func applyJumpPolicy() {
+	switch C.takeJumpAction() {
+	case C.jumpNext:
+		pos := int(C.Player_GetOrder()) + 1
+		logger().Warn("mikmod: breaking out of repeated rows", "position", pos-1)
+		C.Player_SetPosition(C.UWORD(pos))
+	case C.jumpEnd:
+		logger().Warn("mikmod: ending song after too many backward jumps")
+		// Moving past the last position would wrap to the restart
+		// position of a looping module.
+		C.Player_Stop()
+	}
+}
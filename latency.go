@@ -0,0 +1,104 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// updatePeriod is the interval at which the update loop feeds the
+// output driver.
+const updatePeriod = 10 * time.Millisecond
+
+// Limits and assumed default of the "buffer" driver argument, the base
+// 2 logarithm of the audio buffer size in bytes, as understood by
+// drivers such as OSS.
+const (
+	minBufferShift     = 7
+	maxBufferShift     = 17
+	defaultBufferShift = 14
+)
+
+// Latency describes how much audio is buffered between the mixer and
+// the speakers.
+type Latency struct {
+	// BufferSize is the size of the driver's audio buffer in bytes,
+	// or 0 if it is unknown.
+	BufferSize int
+
+	// Buffer is the duration of audio held by the driver's buffer,
+	// or 0 if it is unknown.
+	Buffer time.Duration
+
+	// UpdatePeriod is the interval at which the update loop feeds
+	// the driver, taking Options.UpdateBatch into account.
+	UpdatePeriod time.Duration
+}
+
+// Total returns the estimated output latency.
+func (l Latency) Total() time.Duration { return l.Buffer + l.UpdatePeriod }
+
+// OutputLatency returns the estimated output latency.  libmikmod does
+// not report the buffer sizes its drivers settle on, so the buffer
+// size is derived from the "buffer" and "count" driver arguments, and
+// is unknown if they were not supplied.
+func OutputLatency() Latency {
+	mu.Lock()
+	args := options.commandLine()
+	batch := options.updateBatch()
+	mu.Unlock()
+
+	l := Latency{UpdatePeriod: time.Duration(batch) * updatePeriod}
+	shift, ok := driverArgInt(args, "buffer")
+	if !ok {
+		return l
+	}
+	count, ok := driverArgInt(args, "count")
+	if !ok {
+		count = 1
+	}
+	l.BufferSize = (1 << shift) * count
+	if f := currentFormat(); f.SampleRate > 0 && f.FrameSize() > 0 {
+		frames := l.BufferSize / f.FrameSize()
+		l.Buffer = time.Duration(frames) * time.Second / time.Duration(f.SampleRate)
+	}
+	return l
+}
+
+// bufferShift returns the "buffer" driver argument giving a buffer
+// that holds about d worth of audio in format f.
+func bufferShift(d time.Duration, f Format) int {
+	bytes := d.Seconds() * float64(f.SampleRate*f.FrameSize())
+	shift := int(math.Ceil(math.Log2(bytes)))
+	return max(minBufferShift, min(maxBufferShift, shift))
+}
+
+// driverArgInt returns the integer value of the named argument in a
+// driver command line.
+func driverArgInt(args string, name string) (int, bool) {
+	for _, arg := range strings.Split(args, ",") {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || strings.TrimSpace(key) != name {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// setDriverArg sets the named argument in a driver command line,
+// replacing any previous value.
+func setDriverArg(args string, name string, value string) string {
+	var out []string
+	for _, arg := range strings.Split(args, ",") {
+		key, _, _ := strings.Cut(arg, "=")
+		if arg != "" && strings.TrimSpace(key) != name {
+			out = append(out, arg)
+		}
+	}
+	return strings.Join(append(out, name+"="+value), ",")
+}
@@ -0,0 +1,158 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// It is defined in channeloverrides.go.
+extern void setChannelLayers(const UWORD *scales);
+*/
+import "C"
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Layer is a group of channels of the music, e.g. "drums" or "pads",
+// faded in and out together, for dynamic music remixed from a single
+// module as the game goes.  Like SetChannelVolume, a layer applies to
+// its channels in whichever module plays, scaling their volume with
+// the other settings.  A channel may belong to several layers, whose
+// levels multiply, but reserved channels are never faded; see
+// ReserveChannel.
+type Layer struct {
+	name     string
+	channels []int
+
+	// level is the current level of the layer and fade the fade in
+	// progress, if any.  They are guarded by layersMu.
+	level float64
+	fade  *fade
+}
+
+var (
+	// layers are the layers created by NewLayer and not closed, and
+	// layerScales the channel scales last applied, guarded by
+	// layersMu.
+	layersMu    sync.Mutex
+	layers      []*Layer
+	layerScales [C.UF_MAXCHAN]C.UWORD
+)
+
+// NewLayer returns a layer grouping the channels, at full level.  Close
+// it when done.
+func NewLayer(name string, channels ...int) *Layer {
+	l := &Layer{name: name, channels: slices.Clone(channels), level: 1}
+	layersMu.Lock()
+	defer layersMu.Unlock()
+	layers = append(layers, l)
+	channelsOverridden.Store(true)
+	return l
+}
+
+// Name returns the name of the layer.
+func (l *Layer) Name() string { return l.name }
+
+// Channels returns the channels of the layer.
+func (l *Layer) Channels() []int { return slices.Clone(l.channels) }
+
+// Level returns the current level of the layer, between 0 and 1.
+func (l *Layer) Level() float64 {
+	layersMu.Lock()
+	defer layersMu.Unlock()
+	return l.level
+}
+
+// SetLevel sets the level of the layer, between 0 and 1, cancelling
+// any fade in progress.
+func (l *Layer) SetLevel(v float64) { l.FadeTo(v, 0) }
+
+// FadeIn ramps the layer up to full level over duration d, and returns
+// a channel that is closed when the fade completes or is replaced.
+func (l *Layer) FadeIn(d time.Duration) <-chan struct{} { return l.FadeTo(1, d) }
+
+// FadeOut ramps the layer down to silence over duration d, and returns
+// a channel that is closed when the fade completes or is replaced.
+func (l *Layer) FadeOut(d time.Duration) <-chan struct{} { return l.FadeTo(0, d) }
+
+// FadeTo ramps the layer from its current level to level to, between 0
+// and 1, over duration d, and returns a channel that is closed when
+// the fade completes or is replaced.  As with FadeOut, layers only
+// change while the update loop runs.
+func (l *Layer) FadeTo(to float64, d time.Duration) <-chan struct{} {
+	layersMu.Lock()
+	defer layersMu.Unlock()
+	if l.fade != nil {
+		close(l.fade.done)
+	}
+	l.fade = &fade{
+		from:     l.level,
+		to:       clamp(to, 0, 1),
+		start:    time.Now(),
+		duration: d,
+		done:     make(chan struct{}),
+	}
+	return l.fade.done
+}
+
+// Close removes the layer, restoring the volume of its channels.
+// Closing a layer again does nothing.
+func (l *Layer) Close() error {
+	layersMu.Lock()
+	defer layersMu.Unlock()
+	if l.fade != nil {
+		close(l.fade.done)
+		l.fade = nil
+	}
+	layers = slices.DeleteFunc(layers, func(o *Layer) bool { return o == l })
+	return nil
+}
+
+// advance moves the layer's level along its fade, if any.  It expects
+// layersMu to be locked.
+func (l *Layer) advance(now time.Time) {
+	f := l.fade
+	if f == nil {
+		return
+	}
+	t := 1.0
+	if f.duration > 0 {
+		t = float64(now.Sub(f.start)) / float64(f.duration)
+	}
+	if t >= 1 {
+		l.level = f.to
+		close(f.done)
+		l.fade = nil
+		return
+	}
+	l.level = f.from + (f.to-f.from)*t
+}
+
+// applyLayers advances the fades of the layers and scales the volume
+// of their channels.  It is called by the update loop.
+func applyLayers() {
+	layersMu.Lock()
+	defer layersMu.Unlock()
+	now := time.Now()
+	var gains [C.UF_MAXCHAN]float64
+	for ch := range gains {
+		gains[ch] = 1
+	}
+	for _, l := range layers {
+		l.advance(now)
+		for _, ch := range l.channels {
+			if ch >= 0 && ch < len(gains) {
+				gains[ch] *= l.level
+			}
+		}
+	}
+	var scales [C.UF_MAXCHAN]C.UWORD
+	for ch, g := range gains {
+		scales[ch] = C.UWORD(clamp(g, 0, 1)*256 + 0.5)
+	}
+	if scales != layerScales {
+		layerScales = scales
+		C.setChannelLayers(&scales[0])
+	}
+}
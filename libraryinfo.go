@@ -0,0 +1,79 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// LibraryInfo describes the libmikmod linked into the program, e.g. to
+// include in bug reports: its version, and the drivers and loaders
+// compiled into it.
+type LibraryInfo struct {
+	Version string       `json:"version"`
+	Drivers []DriverInfo `json:"drivers"`
+	Loaders []string     `json:"loaders"`
+}
+
+// DriverInfo describes an output driver registered with libmikmod.
+type DriverInfo struct {
+	Name    string `json:"name"`
+	Alias   string `json:"alias"`
+	Version string `json:"version"`
+}
+
+// Library returns a description of the libmikmod linked in, listing
+// the drivers registered by Init, i.e. those of Options.Drivers or all
+// of those compiled in, and the loaders compiled in, with their own
+// version strings.
+func Library() (LibraryInfo, error) {
+	if err := checkInitialized(); err != nil {
+		return LibraryInfo{}, err
+	}
+	major, minor, rev := Version()
+	info := LibraryInfo{Version: fmt.Sprintf("%d.%d.%d", major, minor, rev)}
+	var loaders string
+	do(func() {
+		for i := 1; ; i++ {
+			d := C.MikMod_DriverByOrdinal(C.int(i))
+			if d == nil {
+				break
+			}
+			drv := &Driver{driver: d}
+			info.Drivers = append(info.Drivers, DriverInfo{
+				Name:    drv.Name(),
+				Alias:   drv.Alias(),
+				Version: strings.TrimSpace(drv.Version()),
+			})
+		}
+		if s := C.MikMod_InfoLoader(); s != nil {
+			loaders = C.GoString((*C.char)(s))
+			C.MikMod_free(unsafe.Pointer(s))
+		}
+	})
+	for _, l := range strings.Split(loaders, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			info.Loaders = append(info.Loaders, l)
+		}
+	}
+	return info, nil
+}
+
+// String returns the description as lines of text, ready to paste in
+// a bug report.
+func (l LibraryInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "libmikmod %s\n", l.Version)
+	for _, d := range l.Drivers {
+		fmt.Fprintf(&b, "driver %s (%s): %s\n", d.Alias, d.Name, d.Version)
+	}
+	for _, ld := range l.Loaders {
+		fmt.Fprintf(&b, "loader %s\n", ld)
+	}
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "sync"
+
+var (
+	// libMu is held for reading by the calls allocating memory in
+	// libmikmod, such as loads, and for writing by Uninit while it
+	// shuts libmikmod down, so that they cannot overlap.
+	libMu sync.RWMutex
+
+	// liveModules and liveSamples are the modules and samples not
+	// closed yet, which Uninit closes before libmikmod's mixer goes
+	// away with their sample data.  They are guarded by liveMu.
+	liveMu      sync.Mutex
+	liveModules = map[*Module]struct{}{}
+	liveSamples = map[*Sample]struct{}{}
+)
+
+// withLibrary calls fn, unless MikMod is not initialized, in which case
+// it returns ErrNotInitialized.  Uninit waits for fn to return before
+// shutting MikMod down.
+func withLibrary(fn func()) error {
+	libMu.RLock()
+	defer libMu.RUnlock()
+	if err := checkInitialized(); err != nil {
+		return err
+	}
+	fn()
+	return nil
+}
+
+// trackModule records that m is loaded.
+func trackModule(m *Module) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	liveModules[m] = struct{}{}
+}
+
+// untrackModule records that m is being closed, and returns false if
+// it was closed already.
+func untrackModule(m *Module) bool {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	if _, ok := liveModules[m]; !ok {
+		return false
+	}
+	delete(liveModules, m)
+	return true
+}
+
+// trackSample records that s is loaded.
+func trackSample(s *Sample) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	liveSamples[s] = struct{}{}
+}
+
+// untrackSample records that s is being closed, and returns false if
+// it was closed already.
+func untrackSample(s *Sample) bool {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	if _, ok := liveSamples[s]; !ok {
+		return false
+	}
+	delete(liveSamples, s)
+	return true
+}
+
+// closeAll closes the modules and samples still loaded.  It is called
+// by Uninit, with playback stopped.
+func closeAll() {
+	liveMu.Lock()
+	modules, samples := liveModules, liveSamples
+	liveModules, liveSamples = map[*Module]struct{}{}, map[*Sample]struct{}{}
+	liveMu.Unlock()
+	do(func() {
+		sfxMu.Lock()
+		defer sfxMu.Unlock()
+		endAllSFX()
+	})
+	for m := range modules {
+		m.free()
+	}
+	for s := range samples {
+		s.free()
+	}
+	if len(modules)+len(samples) > 0 {
+		logger().Info("mikmod: closed on uninit", "modules", len(modules), "samples", len(samples))
+	}
+}
@@ -0,0 +1,177 @@
+package mikmod
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter is a soft limiter on the mixed output, which turns the gain
+// down smoothly when the music, sound effects and master volume
+// combined peak above a threshold, rather than letting them clip
+// harshly.  See SetLimiter.
+type Limiter struct {
+	// Threshold is the level peaks are held to, between 0 and 1 of
+	// full scale, e.g. 0.89 for about -1 dBFS.  If 0, it is 0.9.
+	Threshold float64
+
+	// Release is how long the gain takes to recover once peaks
+	// subside.  If 0, it is 100 ms.
+	Release time.Duration
+}
+
+// LimiterStats reports how often the limiter engaged, as returned by
+// ReadLimiterStats.  The counts accumulate over the life of the
+// program, across initializations.
+type LimiterStats struct {
+	// Frames is the number of sample frames the limiter processed,
+	// and Limited how many of them it turned down.
+	Frames  uint64 `json:"frames"`
+	Limited uint64 `json:"limited"`
+
+	// Engaged is the number of times the limiter started turning
+	// the gain down after letting the output through unchanged.
+	Engaged uint64 `json:"engaged"`
+
+	// Clipped is the number of samples reaching full scale as they
+	// were mixed, i.e. which libmikmod's mixer clipped before the
+	// limiter could turn them down.  If it grows, lower the master
+	// volume.
+	Clipped uint64 `json:"clipped"`
+
+	// MaxReduction is the deepest gain reduction applied, in dB, as
+	// a negative number, or 0.
+	MaxReduction float64 `json:"maxReduction"`
+}
+
+const (
+	defaultLimiterThreshold = 0.9
+	defaultLimiterRelease   = 100 * time.Millisecond
+)
+
+var (
+	// limiter is the setting set by SetLimiter, or nil, guarded by
+	// limiterMu.
+	limiterMu sync.Mutex
+	limiter   *Limiter
+
+	// limiterStats holds the counters returned by ReadLimiterStats;
+	// minGain is the bits of the lowest gain applied.
+	limiterStats struct {
+		frames  atomic.Uint64
+		limited atomic.Uint64
+		engaged atomic.Uint64
+		clipped atomic.Uint64
+		minGain atomic.Uint64
+	}
+)
+
+func init() { limiterStats.minGain.Store(math.Float64bits(1)) }
+
+// SetLimiter turns on the soft limiter on the output.  It applies to
+// the PCM data mixed for Go output drivers, PullDrivers, Streams and
+// renders, after taps see it and before stream filters do.  Native
+// drivers mix internally, so their output is not limited.  Passing nil
+// turns the limiter off.
+func SetLimiter(l *Limiter) {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	if l != nil {
+		c := *l
+		if c.Threshold <= 0 {
+			c.Threshold = defaultLimiterThreshold
+		}
+		c.Threshold = math.Min(c.Threshold, 1)
+		if c.Release <= 0 {
+			c.Release = defaultLimiterRelease
+		}
+		l = &c
+	}
+	limiter = l
+}
+
+// currentLimiter returns the setting set by SetLimiter, or nil.
+func currentLimiter() *Limiter {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	return limiter
+}
+
+// ReadLimiterStats returns the limiter's counters.
+func ReadLimiterStats() LimiterStats {
+	s := LimiterStats{
+		Frames:  limiterStats.frames.Load(),
+		Limited: limiterStats.limited.Load(),
+		Engaged: limiterStats.engaged.Load(),
+		Clipped: limiterStats.clipped.Load(),
+	}
+	if g := math.Float64frombits(limiterStats.minGain.Load()); g < 1 {
+		s.MaxReduction = 20 * math.Log10(g)
+	}
+	return s
+}
+
+// limiterState is the gain envelope of an output the limiter
+// processes.  The zero value lets the output through unchanged.
+type limiterState struct {
+	reduction float64
+}
+
+// apply limits PCM data b in format f, in place, if the limiter is on.
+// Peaks are caught immediately, across all channels alike so as not to
+// shift the stereo image, and the gain then recovers exponentially.
+func (s *limiterState) apply(b []byte, f Format) {
+	l := currentLimiter()
+	if l == nil || f.Channels <= 0 {
+		s.reduction = 0
+		return
+	}
+	release := math.Exp(-1 / (l.Release.Seconds() * float64(f.SampleRate)))
+	frames := f.numSamples(b) / f.Channels
+	full := 1 - math.Ldexp(1, 1-f.BitsPerSample)
+	var limited, engaged, clipped uint64
+	minGain := 1.0
+	for i := range frames {
+		peak := 0.0
+		for c := range f.Channels {
+			v := math.Abs(f.sampleAt(b, i*f.Channels+c))
+			if v >= full {
+				clipped++
+			}
+			peak = math.Max(peak, v)
+		}
+		// reduction is the fraction of the gain to take off, so
+		// that it decays toward 0 as the gain recovers.
+		was := s.reduction
+		s.reduction *= release
+		if peak > l.Threshold {
+			s.reduction = math.Max(s.reduction, 1-l.Threshold/peak)
+		}
+		if s.reduction < 1e-6 {
+			s.reduction = 0
+			continue
+		}
+		if was == 0 {
+			engaged++
+		}
+		limited++
+		gain := 1 - s.reduction
+		minGain = math.Min(minGain, gain)
+		for c := range f.Channels {
+			j := i*f.Channels + c
+			f.setSampleAt(b, j, f.sampleAt(b, j)*gain)
+		}
+	}
+	limiterStats.frames.Add(uint64(frames))
+	limiterStats.limited.Add(limited)
+	limiterStats.engaged.Add(engaged)
+	limiterStats.clipped.Add(clipped)
+	for {
+		old := limiterStats.minGain.Load()
+		if minGain >= math.Float64frombits(old) ||
+			limiterStats.minGain.CompareAndSwap(old, math.Float64bits(minGain)) {
+			break
+		}
+	}
+}
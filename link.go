@@ -0,0 +1,10 @@
+//go:build !mikmod_static
+
+package mikmod
+
+// libmikmod is linked dynamically: against libmikmod.so or
+// libmikmod.dylib on Unix systems, and with MinGW-w64 on Windows
+// against libmikmod.dll.a, the import library of libmikmod-3.dll.
+
+// #cgo LDFLAGS: -lmikmod
+import "C"
@@ -0,0 +1,24 @@
+//go:build mikmod_static
+
+package mikmod
+
+// Build with -tags mikmod_static to link libmikmod statically, so that
+// binaries do not depend on a shared libmikmod at run time.  This needs
+// libmikmod.a, and the static libraries of any audio systems it was
+// built against, e.g. ALSA or PulseAudio, which can be added with
+// CGO_LDFLAGS.  macOS's linker has no -Bstatic and takes a shared
+// libmikmod over a static one in the same directory, so point it with
+// CGO_LDFLAGS="-L..." at a directory holding only libmikmod.a.
+//
+// libmikmod's sources are not part of this repository, so the tag
+// links a libmikmod.a built beforehand, e.g. with libmikmod's CMake
+// build and -DENABLE_SHARED=0, rather than compiling libmikmod itself.
+
+/*
+#cgo CFLAGS: -DMIKMOD_STATIC
+#cgo linux LDFLAGS: -Wl,-Bstatic -lmikmod -Wl,-Bdynamic -lm -lpthread -ldl
+#cgo freebsd openbsd netbsd LDFLAGS: -Wl,-Bstatic -lmikmod -Wl,-Bdynamic -lm -lpthread
+#cgo windows LDFLAGS: -Wl,-Bstatic -lmikmod -Wl,-Bdynamic -lwinmm -ldsound -ldxguid -lole32
+#cgo darwin LDFLAGS: -lmikmod -framework CoreAudio -framework AudioToolbox -framework AudioUnit -framework CoreFoundation
+*/
+import "C"
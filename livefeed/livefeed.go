@@ -0,0 +1,226 @@
+//go:build cgo
+
+// Package livefeed publishes the state of the MikMod player as a
+// Server-Sent Events feed, so that browser-based visualizers can
+// follow a server-side player in real time.
+//
+// In the browser:
+//
+//	const feed = new EventSource("/feed");
+//	feed.addEventListener("track", e => showTrack(JSON.parse(e.data)));
+//	feed.addEventListener("status", e => draw(JSON.parse(e.data)));
+package livefeed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/death/go-mikmod"
+)
+
+// clientBuffer is the number of messages buffered per client.
+// Messages are dropped for clients that fall further behind.
+const clientBuffer = 16
+
+// Track is sent as a "track" event when the playing module changes.
+type Track struct {
+	Playing   bool   `json:"playing"`
+	Title     string `json:"title"`
+	Tracker   string `json:"tracker"`
+	Channels  int    `json:"channels"`
+	Positions int    `json:"positions"`
+}
+
+// Status is sent as a "status" event on every tick of the feed.
+type Status struct {
+	Playing  bool      `json:"playing"`
+	Paused   bool      `json:"paused"`
+	Elapsed  float64   `json:"elapsed"`
+	Position int       `json:"position"`
+	Row      int       `json:"row"`
+	Speed    int       `json:"speed"`
+	Tempo    int       `json:"tempo"`
+	Levels   []float64 `json:"levels"`
+}
+
+// Feed samples the player periodically and publishes its state to the
+// connected clients.  It is an http.Handler serving the event stream.
+type Feed struct {
+	period time.Duration
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	track   []byte
+	closed  bool
+	stop    chan struct{}
+}
+
+// New returns a feed sampling the player every period, e.g. 50ms.
+// Call Close when done with it.
+func New(period time.Duration) *Feed {
+	f := &Feed{
+		period:  period,
+		clients: make(map[chan []byte]struct{}),
+		stop:    make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// Close stops the feed and disconnects its clients.
+func (f *Feed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	close(f.stop)
+	for c := range f.clients {
+		close(c)
+		delete(f.clients, c)
+	}
+}
+
+// ServeHTTP streams events to the client until it goes away or the
+// feed is closed.  The current track is sent on connection.
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan []byte, clientBuffer)
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		http.Error(w, "feed closed", http.StatusServiceUnavailable)
+		return
+	}
+	f.clients[c] = struct{}{}
+	if f.track != nil {
+		c <- f.track
+	}
+	f.mu.Unlock()
+	defer f.remove(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+	for {
+		select {
+		case msg, ok := <-c:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// remove disconnects client c.
+func (f *Feed) remove(c chan []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clients[c]; ok {
+		delete(f.clients, c)
+		close(c)
+	}
+}
+
+// run samples the player until the feed is closed.
+func (f *Feed) run() {
+	ticker := time.NewTicker(f.period)
+	defer ticker.Stop()
+	s := mikmod.Status()
+	last := s.Module
+	f.publishTrack(s)
+	for {
+		select {
+		case <-ticker.C:
+		case <-f.stop:
+			return
+		}
+		s = mikmod.Status()
+		if s.Module != last {
+			last = s.Module
+			f.publishTrack(s)
+		}
+		if f.hasClients() {
+			f.publish(message("status", status(s)))
+		}
+	}
+}
+
+// hasClients returns true if any client is connected.
+func (f *Feed) hasClients() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.clients) > 0
+}
+
+// publishTrack publishes the track of status s, and remembers it for
+// clients connecting later.
+func (f *Feed) publishTrack(s mikmod.PlayerStatus) {
+	t := Track{Playing: s.Module != nil, Title: s.Title}
+	if s.Module != nil {
+		t.Tracker = s.Module.Tracker()
+		t.Channels = s.Module.NumChannels()
+		t.Positions = s.Module.NumPositions()
+	}
+	msg := message("track", t)
+	f.mu.Lock()
+	f.track = msg
+	f.mu.Unlock()
+	f.publish(msg)
+}
+
+// publish sends msg to all clients, dropping it for those that are
+// not keeping up.
+func (f *Feed) publish(msg []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.clients {
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
+
+// status returns the status event for player status s.
+func status(s mikmod.PlayerStatus) Status {
+	st := Status{
+		Playing:  s.Playing,
+		Paused:   s.Paused,
+		Elapsed:  s.Elapsed.Seconds(),
+		Position: s.Position,
+		Row:      s.Row,
+		Speed:    s.Speed,
+		Tempo:    s.Tempo,
+		Levels:   []float64{},
+	}
+	if s.Module != nil {
+		for ch := 0; ch < s.Module.NumChannels(); ch++ {
+			st.Levels = append(st.Levels, mikmod.ChannelLevel(ch))
+		}
+	}
+	return st
+}
+
+// message formats an event named name with data v as JSON.
+func message(name string, v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = []byte("null")
+	}
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", name, data))
+}
@@ -0,0 +1,88 @@
+package mikmod
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Translator is an input translator implemented in Go, which turns
+// data in a format libmikmod cannot load into one it can, e.g.
+// unpacking a compressed module, before libmikmod's loaders see it.
+//
+// It is not a libmikmod loader: libmikmod's loaders build modules
+// using library internals that are not part of its public API, so no
+// MLOADER can be registered from Go.
+type Translator interface {
+	// Test returns true if data is in the translator's format.
+	Test(data []byte) bool
+
+	// Translate translates data into a module format libmikmod
+	// supports.
+	Translate(data []byte) ([]byte, error)
+}
+
+var (
+	translatorsMu sync.Mutex
+	translators   []Translator
+)
+
+// RegisterTranslator registers an input translator.  Translators are
+// tried in registration order whenever a module is loaded, and the
+// first one recognizing the data translates it for libmikmod's
+// loaders.
+func RegisterTranslator(t Translator) {
+	translatorsMu.Lock()
+	defer translatorsMu.Unlock()
+	translators = append(translators, t)
+}
+
+// hasTranslators returns true if any translators are registered.
+func hasTranslators() bool {
+	translatorsMu.Lock()
+	defer translatorsMu.Unlock()
+	return len(translators) != 0
+}
+
+// translate passes data through the first translator that recognizes
+// it.  Data that no translator recognizes is returned as is.
+func translate(data []byte) ([]byte, error) {
+	translatorsMu.Lock()
+	ts := translators
+	translatorsMu.Unlock()
+	for _, t := range ts {
+		if t.Test(data) {
+			logger().Debug("mikmod: translating input", "translator", fmt.Sprintf("%T", t))
+			return t.Translate(data)
+		}
+	}
+	return data, nil
+}
+
+// translateFile is like translate, but reads the data from the file
+// designated by filename.
+func translateFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err = translate(data)
+	if err != nil {
+		return nil, loadError(filename, -1, err)
+	}
+	return data, nil
+}
+
+// loadError adds to err, an error loading a module, the file the
+// module comes from, if any, and the offset at which the loader gave
+// up, if known, i.e. not negative.
+func loadError(filename string, offset int, err error) error {
+	what := "module"
+	if filename != "" {
+		what = filename
+	}
+	if offset < 0 {
+		return fmt.Errorf("mikmod: loading %s: %w", what, err)
+	}
+	return fmt.Errorf("mikmod: loading %s at byte %d: %w", what, offset, err)
+}
@@ -0,0 +1,27 @@
+package mikmod
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// currentLogger holds the logger set by SetLogger.
+var currentLogger atomic.Pointer[slog.Logger]
+
+// discardLogger is used when no logger is set.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// SetLogger sets the logger recording what the package does: driver
+// selection and loader decisions at the debug level, and module loads,
+// playback state changes and errors at the info level.  Passing nil
+// turns logging off, which is the default.
+func SetLogger(l *slog.Logger) { currentLogger.Store(l) }
+
+// logger returns the logger set by SetLogger, or one discarding
+// records.
+func logger() *slog.Logger {
+	if l := currentLogger.Load(); l != nil {
+		return l
+	}
+	return discardLogger
+}
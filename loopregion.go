@@ -0,0 +1,69 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// loopRegion is a section of a module's song looped by LoopBetween.
+type loopRegion struct {
+	module     *Module
+	start, end int
+}
+
+// region is the section being looped, if any.
+var region atomic.Pointer[loopRegion]
+
+// LoopBetween makes the playing module loop the song positions from
+// startPos to endPos inclusive, until ClearLoop is called or another
+// module is played.  If the song is outside the section, it jumps to
+// startPos.  The jump back happens on the first update after the song
+// leaves the section, so a little of the following position may be
+// heard, depending on the driver's buffer size.
+func LoopBetween(startPos, endPos int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return errNothingPlaying
+	}
+	if startPos < 0 || startPos > endPos || endPos >= m.NumPositions() {
+		return errInvalidLoop
+	}
+	region.Store(&loopRegion{module: m, start: startPos, end: endPos})
+	do(func() { applyLoop() })
+	return nil
+}
+
+// ClearLoop stops looping the section set by LoopBetween, letting the
+// song play on.
+func ClearLoop() {
+	region.Store(nil)
+}
+
+var errInvalidLoop = errors.New("mikmod: invalid loop positions")
+
+// applyLoop jumps back to the start of the looped section if the song
+// has left it, even by ending, and returns true if it did.  It is
+// called by the update loop.
+func applyLoop() bool {
+	r := region.Load()
+	if r == nil {
+		return false
+	}
+	m := playingModule()
+	if m != r.module {
+		region.CompareAndSwap(r, nil)
+		return false
+	}
+	if pos := int(m.module.sngpos); pos >= r.start && pos <= r.end {
+		return false
+	}
+	C.Player_SetPosition(C.UWORD(r.start))
+	return true
+}
@@ -0,0 +1,240 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+typedef struct {
+	int frame;
+	int pos;
+	int row;
+} rowChange;
+
+// mixTracked mixes frames frames of frameSize bytes into buf one at a
+// time, so as to record the frame at which each row of mod starts, up
+// to max of them, in changes.  It returns the number of changes
+// recorded, and expects MikMod to be locked.
+static int mixTracked(SBYTE *buf, int frames, int frameSize, MODULE *mod,
+		rowChange *changes, int max) {
+	int i, n = 0;
+	int pos = mod->sngpos, row = mod->patpos;
+	for (i = 0; i < frames; i++) {
+		VC_WriteBytes(buf + i * frameSize, frameSize);
+		if (mod->sngpos == pos && mod->patpos == row)
+			continue;
+		pos = mod->sngpos;
+		row = mod->patpos;
+		if (n < max) {
+			changes[n].frame = i;
+			changes[n].pos = pos;
+			changes[n].row = row;
+			n++;
+		}
+	}
+	return n;
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// loopRenderFrames is the number of frames mixed per iteration when
+// rendering a loop.
+const loopRenderFrames = 4096
+
+// maxLoopRenderDuration bounds the duration rendered by RenderLoop,
+// in case no loop is detected.
+const maxLoopRenderDuration = 20 * time.Minute
+
+var errNoLoop = errors.New("mikmod: the song does not loop")
+
+// LoopRender is a rendering of a song up to the end of its first loop.
+type LoopRender struct {
+	// Data is the PCM data, in Format.
+	Data   []byte
+	Format Format
+
+	// LoopStart is the frame at which the loop starts.  The loop
+	// ends at the end of Data, so that the frames from LoopStart on
+	// can be repeated seamlessly, after playing the intro before
+	// them once.
+	LoopStart int
+}
+
+// RenderLoop renders the module offline up to the point where the song
+// first jumps back to a row it already played, as songs that loop do,
+// or restarts from its restart position.  Loop boundaries are accurate
+// to the sample.  Jumps within a single position cannot be told apart
+// from pattern loops, so songs looping that way are rendered for 20
+// minutes before giving up.  Any module currently playing is stopped.
+func RenderLoop(m *Module) (LoopRender, error) {
+	if err := checkInitialized(); err != nil {
+		return LoopRender{}, err
+	}
+	// startPull restores the module's settings afterwards.
+	defer startPull(m, true)()
+	m.module.loop, m.module.wrap = 1, 1
+
+	format := currentFormat()
+	frameSize := format.FrameSize()
+	buf := make([]byte, loopRenderFrames*frameSize)
+	var data bytes.Buffer
+	type row struct{ pos, row int }
+	rowStarts := map[row]int{{int(m.module.sngpos), int(m.module.patpos)}: 0}
+	lastPos := int(m.module.sngpos)
+	maxSize := int(maxLoopRenderDuration.Seconds()) * format.SampleRate * frameSize
+	for data.Len() < maxSize {
+		for _, c := range mixRows(m, buf, frameSize) {
+			frame := data.Len()/frameSize + c.frame
+			r := row{c.pos, c.row}
+			start, seen := rowStarts[r]
+			if seen && r.pos != lastPos {
+				data.Write(buf[:c.frame*frameSize])
+				return LoopRender{Data: data.Bytes(), Format: format, LoopStart: start}, nil
+			}
+			if !seen {
+				rowStarts[r] = frame
+			}
+			lastPos = r.pos
+		}
+		data.Write(buf)
+	}
+	return LoopRender{}, errNoLoop
+}
+
+// rowStart is the frame at which a row starts in mixed PCM data.
+type rowStart struct {
+	frame    int
+	pos, row int
+}
+
+// mixRows fills b, made of frames of frameSize bytes, with PCM data
+// from MikMod's software mixer, as mix does, and returns the frames at
+// which the rows of module m start in b.
+func mixRows(m *Module, b []byte, frameSize int) []rowStart {
+	frames := len(b) / frameSize
+	changes := make([]C.rowChange, frames)
+	n := call(func() int {
+		C.MikMod_Lock()
+		n := int(C.mixTracked((*C.SBYTE)(unsafe.Pointer(&b[0])), C.int(frames),
+			C.int(frameSize), m.module, &changes[0], C.int(frames)))
+		C.MikMod_Unlock()
+		applyJumpPolicy()
+		return n
+	})
+	starts := make([]rowStart, n)
+	for i, c := range changes[:n] {
+		starts[i] = rowStart{int(c.frame), int(c.pos), int(c.row)}
+	}
+	metrics.bytesMixed.Add(uint64(frames * frameSize))
+	return starts
+}
+
+// wavSmplChunkSize is the size of a smpl chunk describing one loop.
+const wavSmplChunkSize = 8 + 36 + 24
+
+// wavSmplChunk returns a smpl chunk describing the loop of r, from
+// which samplers and game engines read loop points.
+func wavSmplChunk(r LoopRender) []byte {
+	b := make([]byte, wavSmplChunkSize)
+	copy(b, "smpl")
+	binary.LittleEndian.PutUint32(b[4:], wavSmplChunkSize-8)
+	binary.LittleEndian.PutUint32(b[16:], uint32(1e9/r.Format.SampleRate))
+	binary.LittleEndian.PutUint32(b[20:], 60)
+	binary.LittleEndian.PutUint32(b[36:], 1)
+	end := len(r.Data) / r.Format.FrameSize()
+	binary.LittleEndian.PutUint32(b[52:], uint32(r.LoopStart))
+	binary.LittleEndian.PutUint32(b[56:], uint32(end-1))
+	return b
+}
+
+// WriteWAV writes the rendering as a WAV file, with a smpl chunk
+// holding the loop points and a LIST/INFO chunk describing module m,
+// if not nil.
+func (r LoopRender) WriteWAV(w io.WriteSeeker, m *Module) error {
+	if err := writeWAVHeader(w, r.Format, 0); err != nil {
+		return err
+	}
+	if _, err := w.Write(r.Data); err != nil {
+		return err
+	}
+	trailer := wavSmplChunk(r)
+	if m != nil {
+		trailer = append(trailer, wavInfoChunk(wavTags(m))...)
+	}
+	return finishWAV(w, r.Format, uint32(len(r.Data)), trailer)
+}
+
+// LoopTags returns the tags describing the loop of r, LOOPSTART and
+// LOOPLENGTH, in sample frames, along with the TITLE, DESCRIPTION and
+// ENCODER tags describing module m, if not nil.
+func (r LoopRender) LoopTags(m *Module) map[string]string {
+	end := len(r.Data) / r.Format.FrameSize()
+	tags := map[string]string{
+		"LOOPSTART":  strconv.Itoa(r.LoopStart),
+		"LOOPLENGTH": strconv.Itoa(end - r.LoopStart),
+	}
+	if m != nil {
+		for _, t := range wavTags(m) {
+			if name := vorbisTagNames[t.id]; name != "" && t.value != "" {
+				tags[name] = t.value
+			}
+		}
+	}
+	return tags
+}
+
+// vorbisTagNames map the IDs of LIST/INFO tags to the matching Vorbis
+// comment names.
+var vorbisTagNames = map[string]string{
+	"INAM": "TITLE",
+	"ICMT": "DESCRIPTION",
+	"ISFT": "ENCODER",
+}
+
+var errEncoderTags = errors.New("mikmod: the encoder cannot write loop tags")
+
+// Encode encodes the rendering into w with enc, along with the tags
+// returned by LoopTags, so that the encoded file loops seamlessly.
+// enc must be a TaggingEncoder.
+func (r LoopRender) Encode(w io.Writer, enc Encoder, m *Module) error {
+	te, ok := enc.(TaggingEncoder)
+	if !ok {
+		return errEncoderTags
+	}
+	e, err := te.EncodeTagged(w, r.Format, r.LoopTags(m))
+	if err != nil {
+		return err
+	}
+	_, err = e.Write(r.Data)
+	if cerr := e.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// RenderLoopToWAV renders the module up to the end of its first loop,
+// as RenderLoop does, into a WAV file designated by filename.
+func RenderLoopToWAV(m *Module, filename string) error {
+	r, err := RenderLoop(m)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteWAV(f, m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
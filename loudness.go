@@ -0,0 +1,89 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "math"
+
+// Loudness holds loudness measurements of a module, in dBFS.
+type Loudness struct {
+	// RMS is the root mean square level of the whole song.
+	RMS float64
+
+	// Peak is the highest sample level.
+	Peak float64
+}
+
+// MeasureLoudness renders the module offline and measures its
+// loudness.  Any module currently playing is stopped.
+func MeasureLoudness(m *Module) (Loudness, error) {
+	oldVolume := C.md_musicvolume
+	C.md_musicvolume = 128
+	defer func() { C.md_musicvolume = oldVolume }()
+
+	format := currentFormat()
+	var (
+		sum  float64
+		n    int
+		peak float64
+	)
+	err := render(m, RenderOptions{}, func(b []byte) error {
+		for i := 0; i < format.numSamples(b); i++ {
+			v := format.sampleAt(b, i)
+			sum += v * v
+			peak = math.Max(peak, math.Abs(v))
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return Loudness{}, err
+	}
+	if n == 0 {
+		return Loudness{RMS: math.Inf(-1), Peak: math.Inf(-1)}, nil
+	}
+	return Loudness{
+		RMS:  decibels(math.Sqrt(sum / float64(n))),
+		Peak: decibels(peak),
+	}, nil
+}
+
+// Gain returns the linear gain that brings the measured RMS level to
+// target, in dBFS.  As MikMod can only attenuate, gains above 1 are
+// clipped; pick a target low enough for all modules, e.g. -20.
+func (l Loudness) Gain(target float64) float64 {
+	if math.IsInf(l.RMS, -1) {
+		return 1
+	}
+	return math.Min(1, math.Pow(10, (target-l.RMS)/20))
+}
+
+// Normalize measures the module's loudness and sets its gain so that
+// it plays at target RMS level, in dBFS.  Any module currently playing
+// is stopped.
+func Normalize(m *Module, target float64) error {
+	l, err := MeasureLoudness(m)
+	if err != nil {
+		return err
+	}
+	m.SetGain(l.Gain(target))
+	return nil
+}
+
+// SetGain sets the linear gain, between 0 and 1, applied to the
+// module's music volume whenever it is played by Play, Swap, QueueNext
+// or a Playlist.  The default gain is 1.
+func (m *Module) SetGain(g float64) {
+	m.gain.Store(math.Float64bits(clamp(g, 0, 1)))
+	if playing.Load() == m {
+		applyGain()
+	}
+}
+
+// Gain returns the module's gain.
+func (m *Module) Gain() float64 { return math.Float64frombits(m.gain.Load()) }
+
+// decibels converts a linear level to decibels.
+func decibels(v float64) float64 { return 20 * math.Log10(v) }
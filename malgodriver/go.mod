@@ -0,0 +1,10 @@
+module github.com/death/go-mikmod/malgodriver
+
+go 1.24
+
+require (
+	github.com/death/go-mikmod v0.0.0
+	github.com/gen2brain/malgo v0.11.23
+)
+
+replace github.com/death/go-mikmod => ../
@@ -0,0 +1,141 @@
+// Package malgodriver plays MikMod modules through miniaudio, using
+// malgo.  miniaudio handles device enumeration and the native audio
+// APIs (WASAPI, CoreAudio, ALSA, PulseAudio, ...) uniformly, which
+// makes it easier to ship cross-platform than libmikmod's own drivers.
+//
+// A Context lists the playback devices, so that players can be opened
+// on a chosen one.  See the mikmod package documentation on using
+// other audio libraries.
+package malgodriver
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/death/go-mikmod"
+	"github.com/gen2brain/malgo"
+)
+
+// Context is a miniaudio context.  Remember to Close it when done.
+type Context struct {
+	ctx *malgo.AllocatedContext
+}
+
+// NewContext creates a miniaudio context using the best backend
+// available.
+func NewContext() (*Context, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Context{ctx}, nil
+}
+
+// Close frees the context.
+func (c *Context) Close() error {
+	err := c.ctx.Uninit()
+	c.ctx.Free()
+	return err
+}
+
+// Device is a playback device.
+type Device struct {
+	Name      string
+	IsDefault bool
+	id        malgo.DeviceID
+}
+
+// Devices returns the available playback devices.
+func (c *Context) Devices() ([]Device, error) {
+	infos, err := c.ctx.Devices(malgo.Playback)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]Device, len(infos))
+	for i, info := range infos {
+		devices[i] = Device{
+			Name:      info.Name(),
+			IsDefault: info.IsDefault != 0,
+			id:        info.ID,
+		}
+	}
+	return devices, nil
+}
+
+// Player plays a module through a miniaudio device.  Remember to Close
+// it when done.
+type Player struct {
+	stream *mikmod.Stream
+	device *malgo.Device
+}
+
+// NewPlayer opens a playback device for playing a module.  If dev is
+// nil, the default device is used.  If onStop is not nil, it is called
+// when the device stops, including when it is lost, e.g. unplugged.
+// Any module currently playing is stopped.  Call Start to begin
+// playback.
+func (c *Context) NewPlayer(m *mikmod.Module, dev *Device, onStop func()) (*Player, error) {
+	stream, err := mikmod.NewStream(m, mikmod.StreamOptions{Loop: true})
+	if err != nil {
+		return nil, err
+	}
+	f := stream.Format()
+	format, err := malgoFormat(f)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+
+	cfg := malgo.DefaultDeviceConfig(malgo.Playback)
+	cfg.Playback.Format = format
+	cfg.Playback.Channels = uint32(f.Channels)
+	cfg.SampleRate = uint32(f.SampleRate)
+	if dev != nil {
+		cfg.Playback.DeviceID = dev.id.Pointer()
+	}
+
+	p := &Player{stream: stream}
+	p.device, err = malgo.InitDevice(c.ctx.Context, cfg, malgo.DeviceCallbacks{
+		Data: p.process,
+		Stop: onStop,
+	})
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Start starts playback.
+func (p *Player) Start() error { return p.device.Start() }
+
+// Stop stops playback, which can later be resumed with Start.
+func (p *Player) Stop() error { return p.device.Stop() }
+
+// Close stops playback and frees the player.
+func (p *Player) Close() error {
+	p.device.Uninit()
+	return p.stream.Close()
+}
+
+// process is the device's data callback.  The module is mixed straight
+// into the output buffer, padded with silence once the song has ended.
+func (p *Player) process(out, in []byte, frames uint32) {
+	n, err := io.ReadFull(p.stream, out)
+	if err != nil {
+		clear(out[n:])
+	}
+}
+
+// malgoFormat returns the miniaudio sample format corresponding to f.
+func malgoFormat(f mikmod.Format) (malgo.FormatType, error) {
+	switch f.BitsPerSample {
+	case 8:
+		return malgo.FormatU8, nil
+	case 16:
+		return malgo.FormatS16, nil
+	case 32:
+		return malgo.FormatF32, nil
+	}
+	return 0, fmt.Errorf("malgodriver: unsupported sample size %d", f.BitsPerSample)
+}
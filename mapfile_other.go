@@ -0,0 +1,15 @@
+//go:build !unix
+
+package mikmod
+
+import "os"
+
+// mapFile reads the file designated by filename, as it cannot be
+// mapped into memory on this system.
+func mapFile(filename string) ([]byte, func(), error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, func() {}, nil
+}
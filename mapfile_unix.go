@@ -0,0 +1,35 @@
+//go:build unix
+
+package mikmod
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file designated by filename into memory, read-only,
+// and returns its contents along with a function unmapping them.
+func mapFile(filename string) ([]byte, func(), error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, func() {}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("mikmod: %s is too large to map", filename)
+	}
+	b, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: filename, Err: err}
+	}
+	return b, func() { syscall.Munmap(b) }, nil
+}
@@ -0,0 +1,37 @@
+//go:build cgo
+
+package mikmod
+
+// LoadModuleFromFileMapped is like LoadModuleFromFile, but maps the
+// file into memory and has the loaders read it in place, where the
+// system supports it, rather than reading it into the Go heap first
+// when translators are registered.  libmikmod still decodes all sample
+// data into the mixer while loading, so this lowers the peak memory of
+// loading large modules, not the memory they take once loaded, nor
+// the time spent decoding samples.  Sample loading cannot be deferred:
+// Player_Load loads every sample before returning, and libmikmod has
+// no way to load one later.  The file must not be truncated while the
+// module loads.
+func LoadModuleFromFileMapped(filename string) (*Module, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	b, unmap, err := mapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+	b, err = translate(b)
+	if err != nil {
+		return nil, loadError(filename, -1, err)
+	}
+	m, err := loadModule(b, filename)
+	if err != nil {
+		return nil, err
+	}
+	// The mapping goes away on return; Clone and SwitchDriver read
+	// the file again, passing it through the translators again.
+	m.data = nil
+	m.filename = filename
+	return m, nil
+}
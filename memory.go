@@ -0,0 +1,54 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// trackSize returns the size of a UniMod track, made of rows whose
+// first byte holds the row's length in its low 5 bits, and ending
+// with a 0 byte.
+static size_t trackSize(const UBYTE *t) {
+	size_t n = 0;
+	if (t == NULL)
+		return 0;
+	while (t[n] & 0x1f)
+		n += t[n] & 0x1f;
+	return n + 1;
+}
+*/
+import "C"
+
+import "unsafe"
+
+// sampleLoadFrames is the number of frames the software mixer adds
+// to each sample it loads, for interpolation past its end.
+const sampleLoadFrames = 20
+
+// MemoryUsage returns an estimate of the memory used by the module on
+// the C side, in bytes: its patterns, instruments and sample data as
+// held by the software mixer.  Playback state is not counted.
+func (m *Module) MemoryUsage() int64 {
+	return call(func() int64 {
+		mod := m.module
+		n := int64(unsafe.Sizeof(*mod))
+		n += int64(len(m.title) + len(m.comment))
+		if mod.tracks != nil {
+			for _, t := range unsafe.Slice(mod.tracks, mod.numtrk) {
+				n += int64(C.trackSize(t)) + int64(unsafe.Sizeof(t))
+			}
+		}
+		n += int64(mod.numpat) * int64(mod.numchn+1) * int64(unsafe.Sizeof(C.UWORD(0)))
+		n += int64(mod.numpos) * int64(unsafe.Sizeof(C.UWORD(0)))
+		n += int64(mod.numins) * int64(unsafe.Sizeof(C.INSTRUMENT{}))
+		if mod.samples != nil {
+			for _, s := range unsafe.Slice(mod.samples, mod.numsmp) {
+				n += int64(unsafe.Sizeof(s))
+				frameSize := int64(2)
+				if s.flags&C.SF_STEREO != 0 {
+					frameSize *= 2
+				}
+				n += (int64(s.length) + sampleLoadFrames) * frameSize
+			}
+		}
+		return n
+	})
+}
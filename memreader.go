@@ -0,0 +1,80 @@
+package mikmod
+
+/*
+#include <stdio.h>
+#include <string.h>
+#include <mikmod.h>
+
+// memReader reads a module from memory, like the reader behind
+// Player_LoadMem, but lets the caller find out where the loader
+// stopped reading.
+typedef struct {
+	MREADER core;
+	const char *data;
+	long len, pos;
+} memReader;
+
+static int memSeek(MREADER *r, long offset, int whence) {
+	memReader *m = (memReader *)r;
+	long pos;
+	switch (whence) {
+	case SEEK_SET: pos = offset; break;
+	case SEEK_CUR: pos = m->pos + offset; break;
+	case SEEK_END: pos = m->len + offset; break;
+	default: return -1;
+	}
+	if (pos < 0)
+		return -1;
+	m->pos = pos < m->len ? pos : m->len;
+	return 0;
+}
+
+static long memTell(MREADER *r) { return ((memReader *)r)->pos; }
+
+static BOOL memRead(MREADER *r, void *p, size_t size) {
+	memReader *m = (memReader *)r;
+	BOOL ok = 1;
+	if (m->pos >= m->len)
+		return 0;
+	if (size > (size_t)(m->len - m->pos)) {
+		size = m->len - m->pos;
+		ok = 0;
+	}
+	memcpy(p, m->data + m->pos, size);
+	m->pos += size;
+	return ok;
+}
+
+static int memGet(MREADER *r) {
+	memReader *m = (memReader *)r;
+	if (m->pos >= m->len)
+		return EOF;
+	return (UBYTE)m->data[m->pos++];
+}
+
+static BOOL memEof(MREADER *r) {
+	memReader *m = (memReader *)r;
+	return m->pos >= m->len;
+}
+
+// loadMem loads a module from the len bytes at data, storing the
+// offset at which the loader stopped reading in *offset.
+static MODULE *loadMem(const char *data, long len, long *offset) {
+	memReader r = {{memSeek, memTell, memRead, memGet, memEof, 0, 0}, data, len, 0};
+	MODULE *m = Player_LoadGeneric(&r.core, 128, 0);
+	*offset = r.pos;
+	return m;
+}
+*/
+import "C"
+
+import "unsafe"
+
+// loadMem loads a MikMod module from b, and returns the offset at which
+// the loader stopped reading, e.g. where it found the data invalid.
+// It is called on the actor.
+func loadMem(b []byte) (*C.MODULE, int) {
+	var offset C.long
+	module := C.loadMem((*C.char)(unsafe.Pointer(&b[0])), C.long(len(b)), &offset)
+	return module, int(offset)
+}
@@ -0,0 +1,96 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// activeVoices returns the number of voices that are not stopped.
+static int activeVoices(void) {
+	int i, n = 0;
+	for (i = 0; i < md_sngchn; i++)
+		if (!Voice_Stopped(i))
+			n++;
+	return n;
+}
+*/
+import "C"
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics are counters and gauges describing the health of playback,
+// e.g. for graphing.  Counters only ever increase, so rates such as
+// updates per second are derived by sampling them periodically.
+type Metrics struct {
+	// Updates is the number of times the update loop serviced
+	// MikMod.
+	Updates uint64 `json:"updates"`
+
+	// UpdateTime is the total time spent servicing MikMod, and
+	// LastUpdateTime the time spent by the latest update.
+	UpdateTime     time.Duration `json:"updateTime"`
+	LastUpdateTime time.Duration `json:"lastUpdateTime"`
+
+	// BytesMixed is the number of bytes of PCM data mixed for Go
+	// output drivers, PullDrivers, Streams and renders.  Native
+	// drivers mix internally, so their output is not counted.
+	BytesMixed uint64 `json:"bytesMixed"`
+
+	// Errors is the number of errors reported to the error
+	// handler.
+	Errors uint64 `json:"errors"`
+
+	// ActiveVoices is the number of voices playing as of the
+	// latest update.
+	ActiveVoices int `json:"activeVoices"`
+}
+
+// metrics holds the counters and gauges returned by ReadMetrics.
+var metrics struct {
+	updates        atomic.Uint64
+	updateTime     atomic.Int64
+	lastUpdateTime atomic.Int64
+	bytesMixed     atomic.Uint64
+	errors         atomic.Uint64
+	activeVoices   atomic.Int64
+}
+
+// ReadMetrics returns the current metrics.  They accumulate over the
+// life of the program, across initializations.
+func ReadMetrics() Metrics {
+	return Metrics{
+		Updates:        metrics.updates.Load(),
+		UpdateTime:     time.Duration(metrics.updateTime.Load()),
+		LastUpdateTime: time.Duration(metrics.lastUpdateTime.Load()),
+		BytesMixed:     metrics.bytesMixed.Load(),
+		Errors:         metrics.errors.Load(),
+		ActiveVoices:   int(metrics.activeVoices.Load()),
+	}
+}
+
+// PublishMetrics publishes the metrics returned by ReadMetrics as an
+// expvar variable named name, e.g. for scraping from /debug/vars.
+// Like expvar.Publish, it panics if name is already in use.
+func PublishMetrics(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return ReadMetrics() }))
+}
+
+// updateVoiceMetrics records the number of active voices.  It is
+// called by the update loop.
+func updateVoiceMetrics() {
+	n := int64(C.activeVoices())
+	metrics.activeVoices.Store(n)
+	storeMax(&stats.peakVoices, n)
+}
+
+// recordUpdate records an update that took d.
+func recordUpdate(d time.Duration) {
+	metrics.updates.Add(1)
+	metrics.updateTime.Add(int64(d))
+	metrics.lastUpdateTime.Store(int64(d))
+	stats.updates.Add(1)
+	stats.updateTime.Add(int64(d))
+	storeMax(&stats.maxUpdateTime, int64(d))
+}
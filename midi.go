@@ -0,0 +1,242 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"unsafe"
+)
+
+// midiDivision is the number of MIDI ticks per quarter note.  A MIDI
+// tick is a module tick, so that a quarter note is 4 rows at speed 6
+// and the MIDI tempo in beats per minute is the module's tempo.
+const midiDivision = 24
+
+// midiEvent is an event of a MIDI track.
+type midiEvent struct {
+	tick int
+	data []byte
+}
+
+// midiExport holds the state of WriteMIDI.
+type midiExport struct {
+	m        *Module
+	tempo    []midiEvent
+	tracks   [][]midiEvent
+	playing  []int // note sounding on each channel, or -1
+	program  []int // program of each channel, or -1
+	warnings []string
+}
+
+// WriteMIDI writes the module's notes to w as a Standard MIDI File,
+// e.g. to carry on with a tracker sketch in a DAW.  The song is played
+// through once, following pattern breaks and position jumps, with one
+// track per channel, speed and tempo changes as the tempo map,
+// instruments as programs, set volume effects as velocities and
+// panning effects as the pan controller.  Other effects cannot be
+// expressed in MIDI and are dropped; WriteMIDI returns a warning for
+// each kind dropped.  Only the ProTracker effects decoded by
+// PatternRows are taken into account.
+func (m *Module) WriteMIDI(w io.Writer) (warnings []string, err error) {
+	if err := m.usable(); err != nil {
+		return nil, err
+	}
+	numChn := m.NumChannels()
+	e := &midiExport{
+		m:       m,
+		tracks:  make([][]midiEvent, numChn),
+		playing: slices.Repeat([]int{-1}, numChn),
+		program: slices.Repeat([]int{-1}, numChn),
+	}
+	if numChn > 15 {
+		e.warn(fmt.Sprintf("%d channels share 15 MIDI channels", numChn))
+	}
+	end := e.play()
+	return e.warnings, e.write(w, end)
+}
+
+// warn records a warning, unless it was already recorded.
+func (e *midiExport) warn(w string) {
+	if !slices.Contains(e.warnings, w) {
+		e.warnings = append(e.warnings, w)
+	}
+}
+
+// play walks the song, recording its events, and returns the tick at
+// which it ends.
+func (e *midiExport) play() int {
+	mod := e.m.module
+	speed, tempo := int(mod.initspeed), int(mod.inittempo)
+	e.setTempo(0, tempo)
+	tick := 0
+	e.m.walkSong(0, func(pos int, r PatternRow) {
+		for _, c := range r.Cells {
+			if c.Speed > 0 {
+				speed = c.Speed
+			}
+			if c.Tempo > 0 && c.Tempo != tempo {
+				tempo = c.Tempo
+				e.setTempo(tick, tempo)
+			}
+		}
+		for ch, c := range r.Cells {
+			e.cell(ch, c, tick, speed)
+		}
+		tick += speed
+	})
+	for ch := range e.tracks {
+		e.noteOff(ch, tick)
+	}
+	return tick
+}
+
+// midiEffectNames name the ProTracker effects WriteMIDI drops.
+var midiEffectNames = [16]string{
+	0x0: "arpeggio", 0x1: "portamento up", 0x2: "portamento down",
+	0x3: "tone portamento", 0x4: "vibrato", 0x5: "tone portamento and volume slide",
+	0x6: "vibrato and volume slide", 0x7: "tremolo", 0x9: "sample offset",
+	0xA: "volume slide",
+}
+
+// cell records the events of cell c of channel ch, played at tick at
+// speed speed.
+func (e *midiExport) cell(ch int, c PatternCell, tick, speed int) {
+	delay := 0
+	switch c.Effect {
+	case -1, 0xB, 0xC, 0xD, 0xF:
+	case 0x8:
+		// Panning is approximated by the pan controller.
+		e.add(ch, tick, 0xB0|midiChannel(ch), 10, byte(c.Param>>1))
+	case 0xE:
+		switch c.Param >> 4 {
+		case 0xC:
+			if c.Note < 0 {
+				e.noteOff(ch, tick+min(c.Param&0xf, speed))
+				return
+			}
+		case 0xD:
+			delay = min(c.Param&0xf, speed)
+		default:
+			e.warn(fmt.Sprintf("effect E%X dropped", c.Param>>4))
+		}
+	default:
+		if c.Effect != 0 || c.Param != 0 {
+			e.warn(fmt.Sprintf("effect %X (%s) dropped", c.Effect, midiEffectNames[c.Effect]))
+		}
+	}
+	if c.Note < 0 {
+		return
+	}
+	tick += delay
+	midiCh := midiChannel(ch)
+	e.noteOff(ch, tick)
+	if c.Instrument >= 0 && c.Instrument%128 != e.program[ch] {
+		e.program[ch] = c.Instrument % 128
+		e.add(ch, tick, 0xC0|midiCh, byte(e.program[ch]))
+	}
+	note := min(c.Note+12, 127)
+	e.add(ch, tick, 0x90|midiCh, byte(note), e.velocity(c))
+	e.playing[ch] = note
+	if c.Effect == 0xE && c.Param>>4 == 0xC {
+		e.noteOff(ch, tick+min(c.Param&0xf, speed))
+	}
+}
+
+// velocity returns the velocity of the note of cell c: its volume
+// column or set volume effect if any, and the default volume of its
+// sample otherwise.
+func (e *midiExport) velocity(c PatternCell) byte {
+	vol := 64
+	if c.Volume >= 0 {
+		vol = min(c.Volume, 64)
+	} else if c.Effect == 0xC {
+		vol = min(c.Param, 64)
+	} else if c.Instrument >= 0 {
+		if smp := e.m.instrumentSamples(c.Instrument); len(smp) > 0 {
+			vol = int(unsafe.Slice(e.m.module.samples, e.m.module.numsmp)[smp[0]].volume)
+		}
+	}
+	return byte(max(vol*127/64, 1))
+}
+
+// noteOff ends the note sounding on channel ch at tick, if any.
+func (e *midiExport) noteOff(ch int, tick int) {
+	if e.playing[ch] < 0 {
+		return
+	}
+	e.add(ch, tick, 0x80|midiChannel(ch), byte(e.playing[ch]), 0)
+	e.playing[ch] = -1
+}
+
+// add adds an event to the track of channel ch.
+func (e *midiExport) add(ch int, tick int, data ...byte) {
+	e.tracks[ch] = append(e.tracks[ch], midiEvent{tick, data})
+}
+
+// setTempo adds a tempo change to the tempo map.
+func (e *midiExport) setTempo(tick int, tempo int) {
+	if tempo == 0 {
+		return
+	}
+	us := 60000000 / tempo
+	e.tempo = append(e.tempo, midiEvent{tick, []byte{0xff, 0x51, 3, byte(us >> 16), byte(us >> 8), byte(us)}})
+}
+
+// midiChannel returns the MIDI channel of module channel ch, leaving
+// out channel 10, which General MIDI reserves for percussion.
+func midiChannel(ch int) byte {
+	c := ch % 15
+	if c >= 9 {
+		c++
+	}
+	return byte(c)
+}
+
+// write writes the MIDI file, with tracks ending at tick end.
+func (e *midiExport) write(w io.Writer, end int) error {
+	title := []byte(e.m.Title())
+	name := append(appendVarInt([]byte{0xff, 0x03}, len(title)), title...)
+	tracks := [][]midiEvent{append([]midiEvent{{0, name}}, e.tempo...)}
+	for _, t := range e.tracks {
+		if len(t) > 0 {
+			tracks = append(tracks, t)
+		}
+	}
+	var b bytes.Buffer
+	b.WriteString("MThd")
+	binary.Write(&b, binary.BigEndian, uint32(6))
+	binary.Write(&b, binary.BigEndian, [3]uint16{1, uint16(len(tracks)), midiDivision})
+	for _, t := range tracks {
+		var data []byte
+		last := 0
+		slices.SortStableFunc(t, func(a, b midiEvent) int { return a.tick - b.tick })
+		for _, ev := range t {
+			data = appendVarInt(data, ev.tick-last)
+			data = append(data, ev.data...)
+			last = ev.tick
+		}
+		data = appendVarInt(data, max(end-last, 0))
+		data = append(data, 0xff, 0x2f, 0)
+		b.WriteString("MTrk")
+		binary.Write(&b, binary.BigEndian, uint32(len(data)))
+		b.Write(data)
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// appendVarInt appends v to b as a MIDI variable-length quantity.
+func appendVarInt(b []byte, v int) []byte {
+	var buf [5]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7f) | 0x80
+	}
+	return append(b, buf[i:]...)
+}
@@ -1,188 +1,1154 @@
 // Package mikmod lets you use MikMod from Go.
+//
+// # Other audio libraries
+//
+// Besides libmikmod's output drivers, modules can be played through Go
+// audio libraries, which read the PCM data of a Stream as they need it.
+// The otodriver, portaudiodriver, malgodriver and beepstream modules
+// adapt streams to oto, PortAudio, miniaudio and beep, and keep those
+// dependencies out of this package.  Initialize MikMod with InitNoSound
+// before using them: the stream then drives the mixer, and libmikmod
+// does not open an audio device competing with the library's.
 package mikmod
 
 /*
-#cgo LDFLAGS: -lmikmod
 #include <mikmod.h>
+
+// update calls MikMod_Update n times, stopping early if the player
+// becomes inactive, and returns whether it is still active.  Doing so
+// in a single call keeps the update loop's cgo calls to one per
+// wakeup.
+static int update(int n) {
+	int i;
+	for (i = 0; i < n; i++) {
+		MikMod_Update();
+		if (!Player_Active())
+			return 0;
+	}
+	return 1;
+}
 */
 import "C"
 
 import (
+	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 )
 
 // Return MikMod library version.
 func Version() (major int, minor int, rev int) {
-	v := C.MikMod_GetVersion()
+	v := call(func() C.long { return C.MikMod_GetVersion() })
 	rev = int(v & 0xFF)
 	minor = int((v >> 8) & 0xFF)
 	major = int((v >> 16) & 0xFF)
 	return
 }
 
-// Init initializes the MikMod library.  Make sure to call Uninit when
-// done.
+// Options controls how the MikMod library is initialized.
+type Options struct {
+	// DriverArgs is the command line passed to the selected output
+	// driver, e.g. "buffer=14,card=1".  The accepted parameters
+	// depend on the driver.
+	DriverArgs string
+
+	// Drivers lists the output drivers to register.  If empty, all
+	// the drivers compiled into libmikmod are registered, which may
+	// involve probing for sound hardware.
+	Drivers []*Driver
+
+	// Driver selects the output driver to use, registering it if
+	// necessary.  If nil, the driver is chosen according to
+	// DriverPreference.
+	Driver *Driver
+
+	// DriverPreference lists the aliases of registered drivers to
+	// try, in order, e.g. {"pulseaudio", "alsa", "oss"}.  Aliases
+	// of drivers that are not registered are skipped.  If empty,
+	// libmikmod picks the first registered driver that is available.
+	DriverPreference []string
+
+	// FallbackToNoSound makes Init fall back to DriverNoSound, with
+	// a warning logged, instead of failing when no output driver can
+	// be opened, e.g. on headless machines.  Capabilities then
+	// reports the nosound driver.
+	FallbackToNoSound bool
+
+	// Output is the output target of the selected driver, for
+	// drivers that write to a file (DriverWAV, DriverAIFF and
+	// DriverRaw) or to a command (DriverPipe).  It is ignored by
+	// other drivers.
+	Output string
+
+	// Recover makes the package reset the output driver when a
+	// critical error occurs during playback, e.g. because the audio
+	// device went away, and resume the module near where it
+	// stopped.
+	Recover bool
+
+	// RecoveryPolicy controls when and how often recovery is
+	// attempted if Recover is set.
+	RecoveryPolicy RecoveryPolicy
+
+	// DisableNoiseReduction turns off the software mixer's noise
+	// reduction, which is on by default.  See also
+	// SetNoiseReduction.
+	DisableNoiseReduction bool
+
+	// Surround turns on the software mixer's surround effect.  See
+	// also SetSurround.
+	Surround bool
+
+	// Interpolation turns on interpolated mixing.  See also
+	// SetInterpolation.
+	Interpolation bool
+
+	// HQMixer selects the high quality software mixer.  See also
+	// SetHQMixer.
+	HQMixer bool
+
+	// ReverseStereo swaps the left and right channels.  See also
+	// SetReverseStereo.
+	ReverseStereo bool
+
+	// SIMDMixer requests the vectorized software mixer, where
+	// libmikmod supports it.  See also SIMDMixer.
+	SIMDMixer bool
+
+	// EightBit selects 8-bit output instead of 16-bit output.
+	EightBit bool
+
+	// Mono selects mono output instead of stereo output.
+	Mono bool
+
+	// MixFrequency is the mixing frequency to request, in Hz.  If
+	// 0, libmikmod's default of 44100 Hz is used.  See also
+	// SetMixFrequency.
+	MixFrequency int
+
+	// Latency is the output latency to aim for.  If not 0, and
+	// DriverArgs has no "buffer" argument, one giving a buffer of
+	// about that duration is added, for drivers that accept it such
+	// as OSS.  See also OutputLatency.
+	Latency time.Duration
+
+	// UpdateBatch is the number of 10ms update periods serviced
+	// each time the update loop wakes up.  If 0 or 1, the loop wakes
+	// up every 10ms.  Larger values mean fewer wakeups and cgo
+	// calls, which reduces jitter under heavy garbage collection,
+	// but the driver's buffer must hold more than that much audio,
+	// and fades and clocks are updated less often.
+	UpdateBatch int
+
+	// NativeUpdates makes MikMod_Update run on a native thread
+	// created in C, isolating the output driver from Go scheduler
+	// and garbage collector pauses.  The Go update loop then only
+	// handles fades, clocks and starting the next module.  If the
+	// thread cannot be created, or with the mikmod_actor build tag,
+	// the Go update loop calls MikMod_Update as usual.
+	NativeUpdates bool
+
+	// NewTicker, if not nil, returns the ticker waking up the update
+	// loop every period, instead of a time.Ticker.  It is called each
+	// time playback starts, and the ticker is stopped when it stops.
+	// See ManualTicker.
+	NewTicker func(period time.Duration) Ticker
+
+	// WatchdogTimeout is how long an update may take before the
+	// update loop is considered stalled, e.g. because the driver
+	// blocks on a write, and ErrStalled is reported to the error
+	// handler.  If 0, stalls are not detected.
+	WatchdogTimeout time.Duration
+
+	// WatchdogRestart makes the package reset the output driver and
+	// resume the module after a stall, as with Recover, once the
+	// stalled update returns.
+	WatchdogRestart bool
+
+	// SFXVoices is the number of voices reserved for sound effects
+	// played with PlaySample, in addition to the voices of modules.
+	SFXVoices int
+}
+
+// initCount is the number of outstanding Init calls.  If it is
+// positive, MikMod is initialized and options holds the options it
+// was initialized with.  They are guarded by mu.
+var (
+	initCount int
+	options   Options
+)
+
+// Init initializes the MikMod library with default options.  Make
+// sure to call Uninit when done.
+//
+// Initialization is reference-counted: independent components of a
+// program may each call Init and Uninit, and the library is only
+// really initialized by the first call to Init and uninitialized by
+// the matching last call to Uninit.
 func Init() error {
+	return InitWithOptions(Options{})
+}
+
+// InitWithOptions initializes the MikMod library as specified by
+// opts.  Make sure to call Uninit when done.  If the library is
+// already initialized, opts is ignored and only the reference count
+// is incremented; see Init.
+func InitWithOptions(opts Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount > 0 {
+		initCount++
+		return nil
+	}
+	bufferGrowth = 0
+	var err error
+	do(func() { err = opts.init() })
+	if err != nil {
+		return err
+	}
+
+	options = opts
+	initCount = 1
+	recoveryAttempts = 0
+	failures.configure(opts.RecoveryPolicy)
+	ResetStats()
+	return nil
+}
+
+// init initializes the MikMod library as specified by opts.  It
+// expects mu to be locked.
+func (opts Options) init() error {
 	C.MikMod_InitThreads()
-	C.MikMod_RegisterAllDrivers()
+	registerErrorHandler()
+	registerDrivers(opts.Drivers)
 	C.MikMod_RegisterAllLoaders()
-	C.md_mode = C.DMODE_SOFT_MUSIC | C.DMODE_NOISEREDUCTION
-	initString := mikmodString("")
+	devices, err := opts.devices()
+	if err != nil {
+		return err
+	}
+	C.md_mode = opts.mode()
+	C.md_mixfreq = C.UWORD(opts.mixFrequency())
+	initString := mikmodString(opts.commandLine())
 	defer C.free(unsafe.Pointer(initString))
-	if err := int(C.MikMod_Init(initString)); err != 0 {
-		return mikmodError()
+	for _, device := range devices {
+		C.md_device = C.UWORD(device)
+		if err = mikmodInit(initString); err == nil {
+			break
+		}
+		err = fmt.Errorf("mikmod: initializing driver %s: %w", deviceAlias(device), err)
+		logger().Debug("mikmod: driver failed", "device", device, "err", err)
+	}
+	if err != nil && opts.FallbackToNoSound {
+		logger().Warn("mikmod: no audio output, falling back to nosound", "err", err)
+		C.md_device = C.UWORD(DriverNoSound.register())
+		err = mikmodInit(initString)
+	}
+	if err != nil {
+		logger().Info("mikmod: initialization failed", "err", err)
+		return err
+	}
+	if opts.SFXVoices > 0 {
+		C.MikMod_SetNumVoices(-1, C.int(opts.SFXVoices))
+	}
+	// The player and driver are hooked from the start so that Stats
+	// counts ticks and Voice handles go stale.
+	overridePlayer()
+	hookVoices()
+	name, alias := driverStrings(C.md_driver)
+	logger().Info("mikmod: initialized", "driver", name, "alias", alias,
+		"frequency", int(C.md_mixfreq))
+	return nil
+}
+
+// IsInitialized returns true if the MikMod library is initialized,
+// and false otherwise.
+func IsInitialized() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return initCount > 0
+}
+
+// checkInitialized returns ErrNotInitialized unless the MikMod library
+// is initialized.
+func checkInitialized() error {
+	if !IsInitialized() {
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// commandLine returns the command line to pass to the driver.  It
+// expects mu to be locked.
+func (opts Options) commandLine() string {
+	args := opts.DriverArgs
+	shift, ok := driverArgInt(args, "buffer")
+	if !ok && opts.Latency > 0 {
+		shift, ok = bufferShift(opts.Latency, opts.format()), true
+	}
+	if bufferGrowth > 0 {
+		if !ok {
+			shift = defaultBufferShift
+		}
+		shift, ok = min(shift+bufferGrowth, maxBufferShift), true
+	}
+	if ok {
+		args = setDriverArg(args, "buffer", strconv.Itoa(shift))
+	}
+	return opts.Driver.commandLine(opts.Output, args)
+}
+
+// format returns the format opts request.
+func (opts Options) format() Format {
+	f := Format{
+		SampleRate:    opts.mixFrequency(),
+		Channels:      2,
+		BitsPerSample: 16,
+	}
+	if opts.Mono {
+		f.Channels = 1
 	}
+	if opts.EightBit {
+		f.BitsPerSample = 8
+	}
+	return f
+}
+
+// mixFrequency returns the mixing frequency to request.
+func (opts Options) mixFrequency() int {
+	if opts.MixFrequency != 0 {
+		return opts.MixFrequency
+	}
+	return 44100
+}
 
+// updateBatch returns the number of update periods serviced per
+// wakeup of the update loop.
+func (opts Options) updateBatch() int {
+	return max(1, opts.UpdateBatch)
+}
+
+// mode returns the driver mode flags to initialize MikMod with.
+func (opts Options) mode() C.UWORD {
+	mode := C.UWORD(C.DMODE_SOFT_MUSIC | C.DMODE_SOFT_SNDFX | C.DMODE_16BITS | C.DMODE_STEREO)
+	if opts.EightBit {
+		mode &^= C.DMODE_16BITS
+	}
+	if opts.Mono {
+		mode &^= C.DMODE_STEREO
+	}
+	if !opts.DisableNoiseReduction {
+		mode |= C.DMODE_NOISEREDUCTION
+	}
+	if opts.Surround {
+		mode |= C.DMODE_SURROUND
+	}
+	if opts.Interpolation {
+		mode |= C.DMODE_INTERP
+	}
+	if opts.HQMixer {
+		mode |= C.DMODE_HQMIXER
+	}
+	if opts.ReverseStereo {
+		mode |= C.DMODE_REVERSE
+	}
+	if opts.SIMDMixer {
+		mode |= C.DMODE_SIMDMIXER
+	}
+	return mode
+}
+
+// devices returns the ordinal numbers of the drivers to try, in
+// order, where 0 stands for autodetection.
+func (opts Options) devices() ([]int, error) {
+	if opts.Driver != nil {
+		return []int{opts.Driver.register()}, nil
+	}
+	if len(opts.DriverPreference) == 0 {
+		return []int{0}, nil
+	}
+	var devices []int
+	for _, alias := range opts.DriverPreference {
+		if device := driverFromAlias(alias); device != 0 {
+			devices = append(devices, device)
+		}
+	}
+	if len(devices) == 0 {
+		return nil, errNoPreferredDriver
+	}
+	return devices, nil
+}
+
+var errNoPreferredDriver = errors.New("mikmod: none of the preferred drivers is registered")
+
+// mikmodInit calls MikMod_Init with the supplied command line.
+func mikmodInit(cmdline *C.CHAR) error {
+	if err := int(C.MikMod_Init(cmdline)); err != 0 {
+		return mikmodError()
+	}
 	return nil
 }
 
-// Uninit uninitializes the MikMod library.
+// InitNoSound initializes the MikMod library with the nosound driver,
+// so that modules can be loaded and played without an audio device.
+// This is useful for tests and servers.
+func InitNoSound() error {
+	return InitWithOptions(Options{
+		Drivers: []*Driver{DriverNoSound},
+		Driver:  DriverNoSound,
+	})
+}
+
+// Uninit uninitializes the MikMod library, once it has been called as
+// many times as Init; see Init.  It does nothing if the library is not
+// initialized.  It may be called from any goroutine, e.g. from an
+// error handler: it stops playback, waits for the update loop and any
+// load in progress, and closes the modules and samples still loaded,
+// after which the library may be initialized again.
 func Uninit() {
-	Stop()
-	C.MikMod_Exit()
+	libMu.Lock()
+	defer libMu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return
+	}
+	initCount--
+	if initCount > 0 {
+		return
+	}
+	uninit(context.Background())
+}
+
+// Shutdown uninitializes the library however many times Init was
+// called, as the process exits, e.g. on a signal or when an
+// errgroup-based server winds down: it stops playback, closes the
+// modules and samples still loaded and exits libmikmod, which shuts
+// down the driver, completing the file written by a disk writer such
+// as DriverWAV.  It returns once everything is released, or ctx's
+// error if ctx is done first, e.g. because the driver is stuck, in
+// which case the shutdown goes on in the background.  It does nothing
+// if the library is not initialized.
+func Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		libMu.Lock()
+		defer libMu.Unlock()
+		mu.Lock()
+		defer mu.Unlock()
+		if initCount == 0 {
+			return
+		}
+		initCount = 0
+		uninit(ctx)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// uninit releases everything and exits libmikmod, giving up waiting
+// for the update loop when ctx is done, as stopContext does.  It
+// expects libMu and mu to be locked, and initCount to have dropped to
+// 0.
+func uninit(ctx context.Context) {
+	suspended = nil
+	stopContext(ctx)
+	queued.Store(nil)
+	closeAll()
+	unapplyPitch()
+	pitch = 1
+	do(func() { C.MikMod_Exit() })
+	logger().Info("mikmod: uninitialized")
 }
 
 // Module represents a MikMod module.  Remember to Close it when done.
 type Module struct {
 	module *C.MODULE
+
+	// gain holds the bits of the module's float64 gain.
+	gain atomic.Uint64
+
+	// duration is the duration of the song measured by
+	// MeasureDuration, or 0 if it was not measured.
+	duration atomic.Int64
+
+	// filename is the file the module was loaded from, if any, and
+	// digest the SHA-256 digest of the data it was loaded from, if
+	// it was loaded from memory.
+	filename string
+	digest   []byte
+
+	// data is the data the module was parsed from, if it was loaded
+	// from memory, kept for Clone.
+	data []byte
+
+	// profile is the profile set by SetProfile, if any.
+	profile atomic.Pointer[PlaybackProfile]
+
+	// ptMode is the mode set by SetProTrackerMode, and ptSaved the
+	// module's own settings, saved when it was set.  They are guarded
+	// by protrackerMu.
+	ptMode  ProTrackerMode
+	ptSaved protrackerSettings
+
+	// seekTable is the table built by BuildSeekTable, if any.
+	seekTable atomic.Pointer[SeekTable]
+
+	// edits are the changes made by MuteInstrument and
+	// RemapInstrument, if any.
+	edits *instrumentEdits
+
+	// reppos is the restart position as loaded, as PlaySubsong
+	// changes it.
+	reppos C.UWORD
+
+	// title, tracker and comment are converted once, when the module
+	// is loaded, as they are polled frequently, e.g. by Status.
+	// Text is decoded from charset.
+	title   string
+	tracker string
+	comment string
+	charset Charset
+
+	// modType is the format of the data the module was loaded from.
+	modType ModType
+}
+
+// newModule returns a Module wrapping a loaded MikMod module.
+func newModule(module *C.MODULE) *Module {
+	m := &Module{
+		module:  module,
+		tracker: C.GoString((*C.char)(module.modtype)),
+		charset: CurrentCharset(),
+		reppos:  module.reppos,
+	}
+	m.title = m.text(module.songname)
+	m.comment = m.text(module.comment)
+	m.SetGain(1)
+	trackModule(m)
+	return m
+}
+
+// text decodes a string of the module to UTF-8.
+func (m *Module) text(s *C.CHAR) string {
+	return decodeText([]byte(C.GoString((*C.char)(s))), m.charset, m.tracker)
 }
 
 // LoadModuleFromFile attempts to load a MikMod module from the file
 // designated by filename.
 func LoadModuleFromFile(filename string) (*Module, error) {
+	m, err := loadModuleFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m.filename = filename
+	return m, nil
+}
+
+// loadModuleFile is like LoadModuleFromFile, but does not record the
+// filename.
+func loadModuleFile(filename string) (*Module, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	if hasTranslators() {
+		data, err := translateFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return loadModule(data, filename)
+	}
 	fn := mikmodString(filename)
 	defer C.free(unsafe.Pointer(fn))
-	module := C.Player_Load(fn, 128, C.BOOL(0))
-	if module == nil {
-		return nil, mikmodError()
+	var (
+		m   *Module
+		err error
+	)
+	started := time.Now()
+	lerr := withLibrary(func() {
+		do(func() {
+			if module := C.Player_Load(fn, 128, C.BOOL(0)); module != nil {
+				m = newModule(module)
+			} else {
+				err = mikmodError()
+			}
+		})
+	})
+	if lerr != nil {
+		return nil, lerr
 	}
-	return &Module{module}, nil
+	recordLoad(time.Since(started))
+	if err != nil {
+		logger().Info("mikmod: loading failed", "file", filename, "err", err)
+		return nil, loadError(filename, -1, err)
+	}
+	m.modType = detectFileModType(filename, m.tracker)
+	m.logLoaded()
+	return m, nil
 }
 
 // LoadModuleFromSlice attempts to load a MikMod module from the
-// supplied byte slice.
+// supplied byte slice.  The module keeps a reference to b for Clone,
+// so b must not be modified afterwards.  b is read in place rather
+// than copied, but libmikmod's loaders copy the samples into the
+// mixer, converting them to its format, so they take memory on top of
+// b, e.g. of a module embedded with go:embed.  To keep only that
+// copy, use LoadModuleFromSliceNoClone.
 func LoadModuleFromSlice(b []byte) (*Module, error) {
-	module := C.Player_LoadMem((*C.char)(unsafe.Pointer(&b[0])), C.int(len(b)), 128, C.BOOL(0))
-	if module == nil {
-		return nil, mikmodError()
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(b)
+	b, err := translate(b)
+	if err != nil {
+		return nil, loadError("", -1, err)
+	}
+	m, err := loadModule(b, "")
+	if err != nil {
+		return nil, err
 	}
-	return &Module{module}, nil
+	m.digest = digest[:]
+	return m, nil
 }
 
+// LoadModuleFromSliceNoClone is like LoadModuleFromSlice, but the
+// module does not keep a reference to b, so that b may be modified or
+// released once it returns, and only libmikmod's copy of the samples
+// remains in memory.  Such a module cannot be cloned, nor survive
+// SwitchDriver, which both parse the data again.
+func LoadModuleFromSliceNoClone(b []byte) (*Module, error) {
+	m, err := LoadModuleFromSlice(b)
+	if err != nil {
+		return nil, err
+	}
+	m.data = nil
+	return m, nil
+}
+
+// errNoData is returned when reloading a module loaded by
+// LoadModuleFromSliceNoClone.
+var errNoData = errors.New("mikmod: module data was not kept, see LoadModuleFromSliceNoClone")
+
+// loadModule loads a MikMod module from the supplied byte slice,
+// using libmikmod's loaders only.  Errors mention filename, the file
+// the data comes from, if any.
+func loadModule(b []byte, filename string) (*Module, error) {
+	if len(b) == 0 {
+		return nil, loadError(filename, -1, ErrNotAModule)
+	}
+	var (
+		m      *Module
+		offset int
+		err    error
+	)
+	started := time.Now()
+	lerr := withLibrary(func() {
+		do(func() {
+			var module *C.MODULE
+			if module, offset = loadMem(b); module != nil {
+				m = newModule(module)
+			} else {
+				err = mikmodError()
+			}
+		})
+	})
+	if lerr != nil {
+		return nil, lerr
+	}
+	recordLoad(time.Since(started))
+	if err != nil {
+		logger().Info("mikmod: loading failed", "file", filename, "size", len(b), "offset", offset, "err", err)
+		if e, ok := err.(Error); !ok || !e.IsLoadError() || e.Code == C.MMERR_NOT_A_MODULE {
+			offset = -1
+		}
+		return nil, loadError(filename, offset, err)
+	}
+	m.data = b
+	m.modType = detectModType(b, m.tracker)
+	m.logLoaded()
+	return m, nil
+}
+
+// logLoaded logs that the module was loaded.
+func (m *Module) logLoaded() {
+	logger().Info("mikmod: module loaded", "title", m.title, "type", m.modType,
+		"tracker", m.tracker, "channels", m.NumChannels())
+}
+
+// Filename returns the name of the file the module was loaded from,
+// or "" if it was loaded from memory.
+func (m *Module) Filename() string { return m.filename }
+
 // Title returns the module's song name.
-func (m *Module) Title() string { return C.GoString((*C.char)(m.module.songname)) }
+func (m *Module) Title() string { return m.title }
 
 // NumChannels returns the number of channels used by the module.
-func (m *Module) NumChannels() int { return int(m.module.numchn) }
+func (m *Module) NumChannels() int { return int(m.mod().numchn) }
 
 // NumVoices returns the number of voices reserved by the player for
 // real and virtual channels.
-func (m *Module) NumVoices() int { return int(m.module.numvoices) }
+func (m *Module) NumVoices() int { return int(m.mod().numvoices) }
 
 // NumPositions returns the number of song positions.
-func (m *Module) NumPositions() int { return int(m.module.numpos) }
+func (m *Module) NumPositions() int { return int(m.mod().numpos) }
 
 // NumPatterns returns the number of song patterns.
-func (m *Module) NumPatterns() int { return int(m.module.numpat) }
+func (m *Module) NumPatterns() int { return int(m.mod().numpat) }
 
 // NumInstruments returns the number of instruments in the module.
-func (m *Module) NumInstruments() int { return int(m.module.numins) }
+func (m *Module) NumInstruments() int { return int(m.mod().numins) }
 
 // NumSamples returns the number of samples in the module.
-func (m *Module) NumSamples() int { return int(m.module.numsmp) }
+func (m *Module) NumSamples() int { return int(m.mod().numsmp) }
 
 // Tracker returns the name of the tracker used to create the song.
-func (m *Module) Tracker() string { return C.GoString((*C.char)(m.module.modtype)) }
+func (m *Module) Tracker() string { return m.tracker }
+
+// Type returns the format of the file the module was loaded from, as
+// detected from its signature, e.g. to branch on the format without
+// matching Tracker's free-form string.
+func (m *Module) Type() ModType { return m.modType }
 
 // Comment returns the song comment.
-func (m *Module) Comment() string { return C.GoString((*C.char)(m.module.comment)) }
+func (m *Module) Comment() string { return m.comment }
 
 // Elapsed returns the time elapsed since the song started playing.
 func (m *Module) Elapsed() time.Duration {
-	return time.Duration(m.module.sngtime*1000/1024) * time.Millisecond
+	return time.Duration(m.mod().sngtime*1000/1024) * time.Millisecond
 }
 
 // Speed returns the song speed.
-func (m *Module) Speed() int { return int(m.module.sngspd) }
+func (m *Module) Speed() int { return int(m.mod().sngspd) }
 
 // Tempo returns the song tempo.
-func (m *Module) Tempo() int { return int(m.module.bpm) }
+func (m *Module) Tempo() int { return int(m.mod().bpm) }
 
 // SetLoop controls whether the module's playback should loop.
-func (m *Module) SetLoop(value bool) { m.module.loop = mikmodBool(value) }
+func (m *Module) SetLoop(value bool) { m.mod().loop = mikmodBool(value) }
 
 // Loop returns true if the module's playback should loop, and false
 // otherwise.
-func (m *Module) Loop() bool { return goBool(m.module.loop) }
+func (m *Module) Loop() bool { return goBool(m.mod().loop) }
 
 // SetFadeout controls whether the module's playback should fade out
 // on the last pattern.
-func (m *Module) SetFadeout(value bool) { m.module.fadeout = mikmodBool(value) }
+func (m *Module) SetFadeout(value bool) { m.mod().fadeout = mikmodBool(value) }
 
 // Fadeout returns true if the module's playback should fade out on
 // the last pattern, and false otherwise.
-func (m *Module) Fadeout() bool { return goBool(m.module.fadeout) }
+func (m *Module) Fadeout() bool { return goBool(m.mod().fadeout) }
 
-// Close frees the module, making it unusable.
+// Close frees the module, making it unusable.  Closing a module again,
+// or after Uninit closed it, does nothing.
 func (m *Module) Close() error {
-	C.Player_Free(m.module)
-	m.module = nil
+	if untrackModule(m) {
+		mu.Lock()
+		defer mu.Unlock()
+		m.free()
+	}
 	return nil
 }
 
+// mod returns the module's libmikmod module, panicking if m is nil or
+// closed.
+func (m *Module) mod() *C.MODULE {
+	m.mustBeUsable()
+	return m.module
+}
+
+// free frees the module's memory in libmikmod.  If m is playing, it is
+// stopped first, and the update loop carries on as if it had ended, so
+// that its hooks never see it closed.  It expects mu to be locked.
+func (m *Module) free() {
+	do(func() {
+		hooksMu.Lock()
+		defer hooksMu.Unlock()
+		if playing.CompareAndSwap(m, nil) {
+			C.Player_Stop()
+		}
+		unwatchJumps(m)
+		unwatchClock(m)
+		m.clearInstrumentEdits()
+		C.Player_Free(m.module)
+		m.module = nil
+	})
+}
+
 var (
+	// mu guards the player state below.
+	mu     sync.Mutex
 	finish chan struct{}
-	done   sync.WaitGroup
+	exited chan struct{}
+
+	// playing is the module being played by the update loop.  The
+	// update loop itself may change it, so it is not guarded by mu.
+	// Closing it clears it, on the actor; see playingModule.
+	playing atomic.Pointer[Module]
+
+	// hooksMu is held by the update loop while its hooks run, and
+	// while a module is freed, so that the hooks never use a module
+	// being closed; other users of the playing module hold mu.  It
+	// is locked inside do closures, after mu.
+	hooksMu sync.Mutex
+
+	// endHook is called by the update loop when the playing module
+	// ends, or early, ahead of a crossfade; see setEndCrossfade.  If
+	// it returns a module, that module is started right away.  It is
+	// guarded by endHookMu.
+	endHookMu sync.Mutex
+	endHook   func(early bool) *Module
+
+	// queued is the module to start when the playing module ends,
+	// before consulting endHook.
+	queued atomic.Pointer[Module]
 )
 
-// updateLoop calls MikMod's update routine every 10ms.  It terminates
-// when the finish channel is closed, calling Done on the done
-// waitgroup.
-func updateLoop() {
+// setEndHook sets the function called by the update loop when the
+// playing module ends.  The hook runs on the update loop's goroutine,
+// so it must not block, nor call Play or Stop.
+func setEndHook(fn func(early bool) *Module) {
+	endHookMu.Lock()
+	defer endHookMu.Unlock()
+	endHook = fn
+}
+
+// updateLoop calls MikMod's update routine batch times every batch
+// update periods, unless the native update thread or a PullDriver
+// drives the mixer.  session is the session started with the loop;
+// errors and events raised by an update carry the session it started
+// in.  It terminates when the finish channel is closed, closing the
+// exited channel.
+func updateLoop(finish, exited chan struct{}, ticker Ticker, batch int, native bool, session Session) {
+	defer close(exited)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-time.After(10 * time.Millisecond):
-			C.MikMod_Update()
+		case <-ticker.C():
+			var active, due bool
+			// Swap and advance start sessions of their own
+			// without restarting the loop.
+			if s := CurrentSession(); s > session {
+				session = s
+			}
+			started := time.Now()
+			updateSession.Store(uint64(session))
+			updateStarted.Store(started.UnixNano())
+			ok := doUntil(finish, func() {
+				hooksMu.Lock()
+				applyFade()
+				applyDucking()
+				applyLayers()
+				updateSFX()
+				hooksMu.Unlock()
+				if native || pulling.Load() {
+					active = C.Player_Active() != 0
+				} else {
+					active = C.update(C.int(batch)) != 0
+				}
+				select {
+				case <-finish:
+					// The loop was abandoned while the
+					// update was stuck; the hooks would
+					// act on whatever plays now.
+					active = true
+					return
+				default:
+				}
+				hooksMu.Lock()
+				defer hooksMu.Unlock()
+				if applyLoop() || applyPasses() {
+					active = true
+				}
+				applySilence()
+				applyJumpPolicy()
+				detectSongLoop()
+				updateChannelOverrides()
+				updateClocks()
+				updateScopes()
+				updateVoiceMetrics()
+				due = active && crossfadeDue()
+			})
+			updateStarted.Store(0)
+			if !ok {
+				continue
+			}
+			recordUpdate(time.Since(started))
+			if !active || due {
+				advance(finish, due)
+			}
 		case <-finish:
-			done.Done()
 			return
 		}
 	}
 }
 
 // Play starts playing a module.
-func Play(m *Module) {
+func Play(m *Module) error {
+	return PlayWithOptions(m, PlayOptions{})
+}
+
+// PlayOptions controls how Play starts a module.
+type PlayOptions struct {
+	// FadeIn, if not 0, ramps the music volume up from silence over
+	// that duration, e.g. to avoid a hard start when music resumes.
+	FadeIn time.Duration
+}
+
+// PlayWithOptions is like Play, but starts the module as specified by
+// opts.
+func PlayWithOptions(m *Module, opts PlayOptions) error {
+	_, err := PlaySession(m, opts)
+	return err
+}
+
+// PlaySession is like PlayWithOptions, but also returns the session
+// started, to tell the errors and events of this playback apart from
+// those of others; see Session.
+func PlaySession(m *Module, opts PlayOptions) (Session, error) {
+	if err := m.usable(); err != nil {
+		return 0, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return 0, ErrNotInitialized
+	}
+	suspended = nil
+	recoveryAttempts = 0
+	if opts.FadeIn > 0 {
+		cancelFade()
+		setMusicLevel(0)
+		startFade(1, opts.FadeIn)
+	}
+	return play(m), nil
+}
+
+// play is like Play, but expects mu to be locked.  It returns the
+// session started.
+func play(m *Module) Session {
 	if finish != nil {
-		Stop()
+		stop()
 	}
 
-	C.Player_Start(m.module)
+	s := start(m)
 
+	// The native thread of an abandoned loop, if still running,
+	// carries on updating for this one rather than being doubled.
+	useNative := !actorBuild && options.NativeUpdates && (native || startNative() == nil)
 	finish = make(chan struct{})
-	done.Add(1)
-	go updateLoop()
+	exited = make(chan struct{})
+	if useNative {
+		nativeLoop = exited
+	}
+	batch := options.updateBatch()
+	ticker := options.newTicker(time.Duration(batch) * updatePeriod)
+	go updateLoop(finish, exited, ticker, batch, useNative, s)
+	if options.WatchdogTimeout > 0 {
+		go watchdog(finish, s, options.WatchdogTimeout, options.WatchdogRestart)
+	}
+	return s
+}
+
+// Swap replaces the playing module with m without stopping the update
+// loop, so that m starts within one update period.  If no module is
+// playing, it is equivalent to Play.
+func Swap(m *Module) error { return swap(m, 0) }
+
+// swap is like Swap, but crossfades from the playing module into m
+// over crossfade, if not 0, with Go output drivers; see startCrossfade.
+func swap(m *Module, crossfade time.Duration) error {
+	if err := m.usable(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return ErrNotInitialized
+	}
+	suspended = nil
+	recoveryAttempts = 0
+	if finish == nil {
+		play(m)
+		return nil
+	}
+	if crossfade > 0 {
+		do(func() { startCrossfade(crossfade) })
+	}
+	start(m)
+	return nil
 }
 
 // Stop stops playing a module.
 func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	suspended = nil
+	stop()
+}
+
+// StopContext is like Stop, but gives up waiting for the update loop
+// to terminate when ctx is done, e.g. because it is stuck in a driver
+// write, and returns ctx's error.  The update loop is then abandoned:
+// Play may be called again, but libmikmod may remain blocked until the
+// driver returns.  The abandoned loop runs no further updates, and the
+// rest of the stop is carried out once it exits.
+func StopContext(ctx context.Context) error {
+	mu.Lock()
+	defer mu.Unlock()
+	suspended = nil
+	return stopContext(ctx)
+}
+
+// stop is like Stop, but expects mu to be locked.
+func stop() { stopContext(context.Background()) }
+
+// stopContext is like StopContext, but expects mu to be locked.
+func stopContext(ctx context.Context) error {
 	if finish == nil {
-		return
+		return nil
 	}
 
-	C.Player_Stop()
-
 	close(finish)
-	done.Wait()
+	var err error
+	select {
+	case <-exited:
+		do(func() {
+			C.Player_Stop()
+			cancelCrossfade()
+		})
+		stopNative()
+	case <-ctx.Done():
+		err = ctx.Err()
+		go finishStop(exited)
+	}
 	finish = nil
+	exited = nil
+	playing.Store(nil)
+	if err != nil {
+		logger().Info("mikmod: abandoned the update loop", "err", err)
+	} else {
+		logger().Info("mikmod: stopped")
+	}
+	return err
+}
+
+// finishStop completes the stop of an update loop abandoned by
+// stopContext once it exits: it stops the player, unless another
+// module started meanwhile, and the native update thread, if the loop
+// started it.
+func finishStop(exited chan struct{}) {
+	<-exited
+	mu.Lock()
+	defer mu.Unlock()
+	if finish == nil {
+		do(func() {
+			C.Player_Stop()
+			cancelCrossfade()
+		})
+	}
+	if nativeLoop == exited {
+		stopNative()
+	}
+	logger().Info("mikmod: the abandoned update loop exited")
+}
+
+// QueueNext queues a module to be started as soon as the playing one
+// ends, replacing any module queued previously.  Passing nil clears
+// the queue.  The queued module takes precedence over a playlist's
+// next entry.  It panics if m is closed.
+func QueueNext(m *Module) {
+	if m != nil {
+		m.mustBeUsable()
+	}
+	queued.Store(m)
+}
+
+// Queued returns the module queued by QueueNext that has not started
+// yet, or nil if there is none.
+func Queued() *Module {
+	return queued.Load()
+}
+
+// advance starts the queued module or the one returned by the end hook,
+// if any, unless finish is closed first.  It is called by the update
+// loop when the playing module has ended, or early, ahead of the
+// crossfade into the end hook's module, in which case a queued module
+// waits for the end.
+func advance(finish <-chan struct{}, early bool) {
+	var (
+		m *Module
+		d time.Duration
+	)
+	if !early {
+		m = queued.Swap(nil)
+	}
+	if m == nil {
+		endHookMu.Lock()
+		fn := endHook
+		endHookMu.Unlock()
+		if fn == nil || early && queued.Load() != nil {
+			return
+		}
+		m, d = fn(early), crossfadeLength()
+	}
+	if m != nil {
+		doUntil(finish, func() {
+			if d > 0 {
+				startCrossfade(d)
+			}
+			start(m)
+		})
+	}
+}
+
+// start makes m the module played by libmikmod, in a new session,
+// which it returns.  A module that played to its end before is started
+// over from the beginning.
+func start(m *Module) Session {
+	var s Session
+	do(func() {
+		startModule(m)
+		s = newSession()
+		playing.Store(m)
+		applyGain()
+	})
+	logger().Info("mikmod: playing", "title", m.Title(), "session", s)
+	return s
+}
+
+// playingModule returns the module being played by the update loop,
+// or nil if there is none or it was closed meanwhile.  Callers using
+// the module's libmikmod module must hold mu or hooksMu, so that it is
+// not freed meanwhile.
+func playingModule() *Module {
+	m := playing.Load()
+	if m == nil || m.module == nil {
+		return nil
+	}
+	return m
 }
 
 // IsPlaying returns true if the player is active, and false
 // otherwise.
 func IsPlaying() bool {
-	return int(C.Player_Active()) != 0
+	return call(func() bool { return C.Player_Active() != 0 })
+}
+
+// call is like do, but returns the result of fn.
+func call[T any](fn func() T) T {
+	var v T
+	do(func() { v = fn() })
+	return v
 }
 
 // mikmodString converts a Go string to a MikMod string; make sure to
@@ -191,12 +1157,6 @@ func mikmodString(s string) *C.CHAR {
 	return (*C.CHAR)(C.CString(s))
 }
 
-// mikmodErrro returns a Go error corresponding to the current MikMod
-// error.
-func mikmodError() error {
-	return errors.New(C.GoString(C.MikMod_strerror(C.MikMod_errno)))
-}
-
 // mikmodBool converts a Go boolean to a MikMod boolean, which is
 // represented as an int.
 func mikmodBool(b bool) C.BOOL {
@@ -0,0 +1,99 @@
+//go:build cgo
+
+package mikmod_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/death/go-mikmod"
+)
+
+// playSeed initializes MikMod with the nosound driver and ticker, and
+// starts playing the fuzzing seed module in a loop.
+func playSeed(b *testing.B, ticker *mikmod.ManualTicker) *mikmod.Module {
+	b.Helper()
+	err := mikmod.InitWithOptions(mikmod.Options{
+		Drivers:   []*mikmod.Driver{mikmod.DriverNoSound},
+		Driver:    mikmod.DriverNoSound,
+		NewTicker: ticker.NewTicker,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(mikmod.Uninit)
+	m, err := mikmod.LoadModuleFromSlice(mikmod.FuzzSeeds()[0])
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { m.Close() })
+	m.SetLoop(true)
+	if err := mikmod.Play(m); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(mikmod.Stop)
+	return m
+}
+
+func BenchmarkElapsed(b *testing.B) {
+	m := playSeed(b, mikmod.NewManualTicker())
+	b.ReportAllocs()
+	for b.Loop() {
+		m.Elapsed()
+	}
+}
+
+func BenchmarkStatus(b *testing.B) {
+	playSeed(b, mikmod.NewManualTicker())
+	b.ReportAllocs()
+	for b.Loop() {
+		mikmod.Status()
+	}
+}
+
+func BenchmarkUpdateLoop(b *testing.B) {
+	ticker := mikmod.NewManualTicker()
+	playSeed(b, ticker)
+	b.ReportAllocs()
+	for b.Loop() {
+		ticker.Tick(1)
+	}
+	// The loop received the last tick; wait for its update too.
+	ticker.Tick(1)
+}
+
+// TestCloseWhilePlaying closes the playing module while the update
+// loop and queries use it; run it with -race.
+func TestCloseWhilePlaying(t *testing.T) {
+	err := mikmod.InitWithOptions(mikmod.Options{
+		Drivers: []*mikmod.Driver{mikmod.DriverNoSound},
+		Driver:  mikmod.DriverNoSound,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mikmod.Uninit()
+	for range 20 {
+		m, err := mikmod.LoadModuleFromSlice(mikmod.FuzzSeeds()[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.SetLoop(true)
+		if err := mikmod.Play(m); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 100 {
+				mikmod.Status()
+				mikmod.Progress()
+				mikmod.RowDuration()
+			}
+		}()
+		time.Sleep(5 * time.Millisecond)
+		m.Close()
+		<-done
+		mikmod.Stop()
+	}
+}
@@ -0,0 +1,146 @@
+//go:build cgo
+
+// Package mikmodtest helps write tests of programs using the mikmod
+// package, by rendering modules deterministically and comparing the
+// output with golden files.
+//
+// A typical test:
+//
+//	func TestSong(t *testing.T) {
+//		mikmodtest.Init(t)
+//		m := mikmodtest.Load(t, "testdata/song.xm")
+//		pcm := mikmodtest.RenderTicks(t, m, 500)
+//		mikmodtest.Golden(t, "song", mikmodtest.Hash(pcm))
+//	}
+//
+// Run the tests with -mikmodtest.update to write the golden files.
+package mikmodtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/death/go-mikmod"
+)
+
+// MixFrequency is the mixing frequency modules are rendered at.
+const MixFrequency = 44100
+
+var update = flag.Bool("mikmodtest.update", false, "write golden files instead of comparing with them")
+
+// Init initializes MikMod with the nosound driver and fixed mixing
+// settings, so that rendering gives the same output on every run, and
+// uninitializes it when the test ends.
+func Init(t testing.TB) {
+	t.Helper()
+	err := mikmod.InitWithOptions(mikmod.Options{
+		Drivers:      []*mikmod.Driver{mikmod.DriverNoSound},
+		Driver:       mikmod.DriverNoSound,
+		MixFrequency: MixFrequency,
+	})
+	if err != nil {
+		t.Fatalf("mikmodtest: %v", err)
+	}
+	t.Cleanup(mikmod.Uninit)
+}
+
+// Load loads a module from filename, and closes it when the test ends.
+func Load(t testing.TB, filename string) *mikmod.Module {
+	t.Helper()
+	m, err := mikmod.LoadModuleFromFile(filename)
+	if err != nil {
+		t.Fatalf("mikmodtest: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// RenderTicks renders the first ticks player ticks of module m, or
+// less if the song ends first, and returns the PCM data.
+func RenderTicks(t testing.TB, m *mikmod.Module, ticks int) []byte {
+	t.Helper()
+	s := newStream(t, m)
+	defer s.Close()
+	f := s.Format()
+	var out bytes.Buffer
+	for range ticks {
+		// A tick lasts 2.5/tempo seconds.
+		frames := MixFrequency * 5 / (2 * max(m.Tempo(), 1))
+		if !read(t, s, &out, frames*f.FrameSize()) {
+			break
+		}
+	}
+	return out.Bytes()
+}
+
+// RenderFrames renders the first frames sample frames of module m, or
+// less if the song ends first, and returns the PCM data.
+func RenderFrames(t testing.TB, m *mikmod.Module, frames int) []byte {
+	t.Helper()
+	s := newStream(t, m)
+	defer s.Close()
+	var out bytes.Buffer
+	read(t, s, &out, frames*s.Format().FrameSize())
+	return out.Bytes()
+}
+
+// newStream starts playing m as a stream, without looping.
+func newStream(t testing.TB, m *mikmod.Module) *mikmod.Stream {
+	t.Helper()
+	s, err := mikmod.NewStream(m, mikmod.StreamOptions{})
+	if err != nil {
+		t.Fatalf("mikmodtest: %v", err)
+	}
+	return s
+}
+
+// read reads n bytes from s into out, and returns false if the song
+// ended first.
+func read(t testing.TB, s *mikmod.Stream, out *bytes.Buffer, n int) bool {
+	t.Helper()
+	k, err := io.CopyN(out, s, int64(n))
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		t.Fatalf("mikmodtest: %v", err)
+	}
+	return k == int64(n)
+}
+
+// Hash returns the hexadecimal SHA-256 digest of PCM data b, which
+// makes for small golden files.
+func Hash(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return []byte(hex.EncodeToString(sum[:]) + "\n")
+}
+
+// Golden compares got with the golden file testdata/name.golden,
+// failing the test if they differ.  With the -mikmodtest.update flag,
+// it writes got to the golden file instead.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	filename := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("mikmodtest: %v", err)
+		}
+		if err := os.WriteFile(filename, got, 0o644); err != nil {
+			t.Fatalf("mikmodtest: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("mikmodtest: %v (run with -mikmodtest.update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("mikmodtest: output differs from %s (%d bytes, want %d)", filename, len(got), len(want))
+	}
+}
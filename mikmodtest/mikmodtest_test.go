@@ -0,0 +1,26 @@
+//go:build cgo
+
+package mikmodtest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/death/go-mikmod"
+	"github.com/death/go-mikmod/mikmodtest"
+)
+
+// TestFuzzSeed renders the fuzzing seed module, whose only pattern is
+// empty, so that its golden file holds the hash of 100 ticks of
+// silence.
+func TestFuzzSeed(t *testing.T) {
+	mikmodtest.Init(t)
+	filename := filepath.Join(t.TempDir(), "seed.mod")
+	if err := os.WriteFile(filename, mikmod.FuzzSeeds()[0], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := mikmodtest.Load(t, filename)
+	pcm := mikmodtest.RenderTicks(t, m, 100)
+	mikmodtest.Golden(t, "fuzzseed", mikmodtest.Hash(pcm))
+}
@@ -0,0 +1,32 @@
+package mikmod
+
+import "errors"
+
+var (
+	// ErrNilModule is returned when passing a nil module.
+	ErrNilModule = errors.New("mikmod: nil module")
+
+	// ErrClosed is returned when using a module or sample after
+	// closing it, or after Uninit closed it.
+	ErrClosed = errors.New("mikmod: use of closed module or sample")
+)
+
+// usable returns an error if m is nil or closed, and nil otherwise.
+func (m *Module) usable() error {
+	if m == nil {
+		return ErrNilModule
+	}
+	if m.module == nil {
+		return ErrClosed
+	}
+	return nil
+}
+
+// mustBeUsable panics if m is nil or closed.  Methods that cannot
+// return an error call it, so that misuse panics with a clear message
+// rather than crashing inside libmikmod.
+func (m *Module) mustBeUsable() {
+	if err := m.usable(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,164 @@
+package mikmod
+
+import (
+	"io"
+	"slices"
+	"sync"
+)
+
+// Mixer mixes several sources of PCM data into a single stream, in the
+// format MikMod was initialized with: Players, and any io.Reader
+// yielding PCM data in that format, such as a Stream playing a module
+// through MikMod or a bytes.Reader over sound effects.  Each input has
+// its own gain and pan.  A Mixer is an io.Reader, e.g. for an
+// OutputDriver or an external audio library.
+type Mixer struct {
+	format Format
+
+	mu     sync.Mutex
+	inputs []*MixerInput
+	acc    []float64
+	tmp    []float64
+	buf    []byte
+}
+
+// MixerInput is an input of a Mixer.
+type MixerInput struct {
+	mixer  *Mixer
+	player *Player
+	reader io.Reader
+	gain   float64
+	pan    float64
+}
+
+// NewMixer returns a new Mixer without any inputs.
+func NewMixer() (*Mixer, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	return &Mixer{format: currentFormat()}, nil
+}
+
+// Format returns the format of the Mixer's output, which is also the
+// format expected from readers.
+func (x *Mixer) Format() Format { return x.format }
+
+// Add adds a Player to the mix, with unit gain and centered.
+func (x *Mixer) Add(p *Player) *MixerInput {
+	return x.add(&MixerInput{mixer: x, player: p, gain: 1})
+}
+
+// AddReader adds a reader of PCM data to the mix, with unit gain and
+// centered.  The input is removed when the reader returns an error,
+// such as io.EOF.
+func (x *Mixer) AddReader(r io.Reader) *MixerInput {
+	return x.add(&MixerInput{mixer: x, reader: r, gain: 1})
+}
+
+// add adds input in to the mix.
+func (x *Mixer) add(in *MixerInput) *MixerInput {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.inputs = append(x.inputs, in)
+	return in
+}
+
+// Remove removes a Player from the mix.
+func (x *Mixer) Remove(p *Player) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.inputs = slices.DeleteFunc(x.inputs, func(in *MixerInput) bool { return in.player == p })
+}
+
+// Remove removes the input from its Mixer.
+func (in *MixerInput) Remove() {
+	x := in.mixer
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(in)
+}
+
+// remove removes input in.  It expects x.mu to be locked.
+func (x *Mixer) remove(in *MixerInput) {
+	x.inputs = slices.DeleteFunc(x.inputs, func(other *MixerInput) bool { return other == in })
+}
+
+// SetGain sets the linear gain applied to the input.
+func (in *MixerInput) SetGain(g float64) {
+	in.mixer.mu.Lock()
+	defer in.mixer.mu.Unlock()
+	in.gain = g
+}
+
+// SetPan sets the input's position in the stereo field, from -1 for
+// left to 1 for right.  It has no effect on mono output.
+func (in *MixerInput) SetPan(pan float64) {
+	in.mixer.mu.Lock()
+	defer in.mixer.mu.Unlock()
+	in.pan = clamp(pan, -1, 1)
+}
+
+// Read fills p with the mix of the inputs, always a whole number of
+// sample frames.  The stream does not end: it is silent while no input
+// yields any data.
+func (x *Mixer) Read(p []byte) (int, error) {
+	n := len(p) - len(p)%x.format.FrameSize()
+	if n == 0 {
+		return 0, io.ErrShortBuffer
+	}
+	samples := n / (x.format.BitsPerSample / 8)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if cap(x.acc) < samples {
+		x.acc = make([]float64, samples)
+		x.tmp = make([]float64, samples)
+	}
+	acc, tmp := x.acc[:samples], x.tmp[:samples]
+	clear(acc)
+	for _, in := range slices.Clone(x.inputs) {
+		got := x.fill(in, tmp, n)
+		for i := 0; i < got; i++ {
+			acc[i] += tmp[i] * in.channelGain(i%x.format.Channels, x.format.Channels)
+		}
+	}
+	for i, v := range acc {
+		x.format.setSampleAt(p, i, v)
+	}
+	return n, nil
+}
+
+// fill decodes up to len(buf) samples, or n bytes, from input in into
+// buf and returns the number of samples decoded.  Readers that fail
+// are removed.  It expects x.mu to be locked.
+func (x *Mixer) fill(in *MixerInput, buf []float64, n int) int {
+	if in.player != nil {
+		return in.player.fill(buf)
+	}
+	if cap(x.buf) < n {
+		x.buf = make([]byte, n)
+	}
+	b := x.buf[:n]
+	got, err := io.ReadFull(in.reader, b)
+	got -= got % x.format.FrameSize()
+	if err != nil {
+		x.remove(in)
+	}
+	samples := x.format.numSamples(b[:got])
+	for i := 0; i < samples; i++ {
+		buf[i] = x.format.sampleAt(b, i)
+	}
+	return samples
+}
+
+// channelGain returns the gain applied to channel ch of the input, out
+// of channels, according to its gain and pan.
+func (in *MixerInput) channelGain(ch int, channels int) float64 {
+	if channels != 2 {
+		return in.gain
+	}
+	if ch == 0 {
+		return in.gain * min(1, 1-in.pan)
+	}
+	return in.gain * min(1, 1+in.pan)
+}
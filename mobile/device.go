@@ -0,0 +1,21 @@
+//go:build cgo
+
+package mobile
+
+import "github.com/death/go-mikmod"
+
+// Device is a Pausable for libmikmod's own output, for platforms where
+// one of its drivers is used.  Pausing it suspends the device with
+// mikmod.Suspend, releasing it while the app is in the background.
+var Device Pausable = device{}
+
+type device struct{}
+
+func (device) Play() { mikmod.ResumeDevice() }
+
+func (device) Pause() { mikmod.Suspend() }
+
+func (device) IsPlaying() bool {
+	s := mikmod.Status()
+	return s.Playing && !s.Paused && !mikmod.IsSuspended()
+}
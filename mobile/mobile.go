@@ -0,0 +1,117 @@
+// Package mobile helps play module music in Android and iOS apps, e.g.
+// gomobile, Ebiten or Fyne apps.
+//
+// libmikmod's own output drivers do not cover mobile platforms, so
+// initialize MikMod with mikmod.InitNoSound and play modules through
+// the otodriver package, which outputs through AAudio or OpenSL ES on
+// Android and CoreAudio on iOS.  Alternatively, hand a mikmod.Stream to
+// the game engine's audio player.
+//
+// Mobile apps must also stop their audio when they go to the
+// background.  A Lifecycle pauses its players when the app leaves the
+// foreground, and resumes the ones that were playing when it comes
+// back.  With Fyne, for instance:
+//
+//	lc := app.Lifecycle()
+//	lc.SetOnExitedForeground(music.EnterBackground)
+//	lc.SetOnEnteredForeground(music.EnterForeground)
+//
+// and with golang.org/x/mobile/app:
+//
+//	switch e.Crosses(lifecycle.StageFocused) {
+//	case lifecycle.CrossOff:
+//		music.EnterBackground()
+//	case lifecycle.CrossOn:
+//		music.EnterForeground()
+//	}
+package mobile
+
+import "sync"
+
+// Pausable is something that plays audio and can be paused, such as
+// an *otodriver.Player or a *mikmod.Player.
+type Pausable interface {
+	Play()
+	Pause()
+	IsPlaying() bool
+}
+
+// Lifecycle pauses and resumes players as the app goes to the
+// background and back.  The zero value is ready to use.
+type Lifecycle struct {
+	mu         sync.Mutex
+	players    []Pausable
+	paused     []Pausable
+	background bool
+}
+
+// Add makes the lifecycle manage p.  If the app is in the background,
+// p is paused right away, and played when the app comes back if it was
+// playing.
+func (l *Lifecycle) Add(p Pausable) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.players = append(l.players, p)
+	if l.background && p.IsPlaying() {
+		p.Pause()
+		l.paused = append(l.paused, p)
+	}
+}
+
+// Remove stops managing p, e.g. before closing it.
+func (l *Lifecycle) Remove(p Pausable) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.players = remove(l.players, p)
+	l.paused = remove(l.paused, p)
+}
+
+// EnterBackground pauses the players that are playing.  Call it when
+// the app leaves the foreground.
+func (l *Lifecycle) EnterBackground() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.background {
+		return
+	}
+	l.background = true
+	for _, p := range l.players {
+		if p.IsPlaying() {
+			p.Pause()
+			l.paused = append(l.paused, p)
+		}
+	}
+}
+
+// EnterForeground resumes the players paused by EnterBackground.  Call
+// it when the app returns to the foreground.
+func (l *Lifecycle) EnterForeground() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.background {
+		return
+	}
+	l.background = false
+	for _, p := range l.paused {
+		p.Play()
+	}
+	l.paused = nil
+}
+
+// InBackground returns true if the app is in the background, as far as
+// the lifecycle knows.
+func (l *Lifecycle) InBackground() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.background
+}
+
+// remove returns ps without p.
+func remove(ps []Pausable, p Pausable) []Pausable {
+	for i, q := range ps {
+		if q == p {
+			return append(ps[:i], ps[i+1:]...)
+		}
+	}
+	return ps
+}
@@ -0,0 +1,129 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "errors"
+
+// SetNoiseReduction enables or disables the software mixer's noise
+// reduction, which takes effect immediately.
+func SetNoiseReduction(on bool) { setModeFlag(C.DMODE_NOISEREDUCTION, on) }
+
+// NoiseReduction returns true if noise reduction is enabled, and false
+// otherwise.
+func NoiseReduction() bool { return modeFlag(C.DMODE_NOISEREDUCTION) }
+
+// SetSurround enables or disables the software mixer's surround
+// effect, which takes effect immediately.
+func SetSurround(on bool) { setModeFlag(C.DMODE_SURROUND, on) }
+
+// Surround returns true if surround is enabled, and false otherwise.
+func Surround() bool { return modeFlag(C.DMODE_SURROUND) }
+
+// SetInterpolation enables or disables interpolated mixing, which
+// sounds better but uses more CPU.  It takes effect immediately.
+func SetInterpolation(on bool) { setModeFlag(C.DMODE_INTERP, on) }
+
+// Interpolation returns true if interpolated mixing is enabled, and
+// false otherwise.
+func Interpolation() bool { return modeFlag(C.DMODE_INTERP) }
+
+// SetHQMixer selects between the high quality software mixer and the
+// regular one.  As the mixer is chosen when the driver starts, the
+// driver is reset if MikMod is initialized.
+func SetHQMixer(on bool) error { return setResetModeFlag(C.DMODE_HQMIXER, on) }
+
+// HQMixer returns true if the high quality mixer is selected, and
+// false otherwise.
+func HQMixer() bool { return modeFlag(C.DMODE_HQMIXER) }
+
+// SetSIMDMixer requests the vectorized software mixer.  As the mixer
+// is chosen when the driver starts, the driver is reset if MikMod is
+// initialized.  Use SIMDMixer to find out whether the request took
+// effect.
+func SetSIMDMixer(on bool) error { return setResetModeFlag(C.DMODE_SIMDMIXER, on) }
+
+// SIMDMixer returns true if the vectorized software mixer is in use,
+// and false otherwise.  libmikmod drops the request when it was built
+// without SIMD support or the CPU lacks it.
+func SIMDMixer() bool { return modeFlag(C.DMODE_SIMDMIXER) }
+
+// SetReverseStereo swaps the left and right channels, for setups
+// where the speakers are wired the other way around.  It takes effect
+// immediately.
+func SetReverseStereo(on bool) { setModeFlag(C.DMODE_REVERSE, on) }
+
+// ReverseStereo returns true if the left and right channels are
+// swapped, and false otherwise.
+func ReverseStereo() bool { return modeFlag(C.DMODE_REVERSE) }
+
+// ModeFlags returns libmikmod's driver mode flags, the DMODE_ values
+// of mikmod.h, for settings the package has no function for.
+func ModeFlags() uint16 { return uint16(C.md_mode) }
+
+// SetModeFlags sets the driver mode flags selected by mask to those of
+// value.  Flags such as DMODE_SURROUND take effect immediately, while
+// those selecting the mixer or the sample format reset the driver if
+// MikMod is initialized, as SetHQMixer does.  Flags set before Init
+// are overridden by Options.  The flags selecting software mixing
+// cannot be changed, as the package relies on them.
+func SetModeFlags(mask, value uint16) error {
+	if mask&fixedModeFlags != 0 {
+		return errFixedModeFlags
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var changed C.UWORD
+	do(func() {
+		C.MikMod_Lock()
+		defer C.MikMod_Unlock()
+		mode := C.md_mode&^C.UWORD(mask) | C.UWORD(value&mask)
+		changed = mode ^ C.md_mode
+		C.md_mode = mode
+	})
+	if changed&resetModeFlags == 0 || initCount == 0 {
+		return nil
+	}
+	return reset()
+}
+
+// fixedModeFlags are the mode flags SetModeFlags refuses to change, and
+// resetModeFlags those that only take effect when the driver is reset.
+const (
+	fixedModeFlags = C.DMODE_SOFT_MUSIC | C.DMODE_SOFT_SNDFX
+	resetModeFlags = C.DMODE_16BITS | C.DMODE_STEREO | C.DMODE_FLOAT | C.DMODE_HQMIXER | C.DMODE_SIMDMIXER
+)
+
+var errFixedModeFlags = errors.New("mikmod: software mixing mode flags cannot be changed")
+
+// setResetModeFlag sets or clears a driver mode flag that only takes
+// effect when the driver is reset, resetting it if MikMod is
+// initialized.
+func setResetModeFlag(flag C.UWORD, on bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+	setModeFlag(flag, on)
+	if initCount == 0 {
+		return nil
+	}
+	return reset()
+}
+
+// setModeFlag sets or clears a driver mode flag.
+func setModeFlag(flag C.UWORD, on bool) {
+	do(func() {
+		C.MikMod_Lock()
+		defer C.MikMod_Unlock()
+		if on {
+			C.md_mode |= flag
+		} else {
+			C.md_mode &^= flag
+		}
+	})
+}
+
+// modeFlag returns true if a driver mode flag is set, and false
+// otherwise.
+func modeFlag(flag C.UWORD) bool { return C.md_mode&flag != 0 }
@@ -0,0 +1,134 @@
+package mikmod
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/death/go-mikmod/protracker"
+)
+
+// ModType is a module file format.
+type ModType int
+
+// Module file formats.
+const (
+	ModTypeUnknown ModType = iota
+	ModTypeMOD             // ProTracker and compatible
+	ModTypeM15             // 15-sample Soundtracker
+	ModTypeS3M             // Scream Tracker 3
+	ModTypeIT              // Impulse Tracker
+	ModTypeXM              // FastTracker 2
+	ModTypeMTM             // MultiTracker
+	ModType669             // Composer 669
+	ModTypeFAR             // Farandole Composer
+	ModTypeULT             // UltraTracker
+	ModTypeSTM             // Scream Tracker 2
+	ModTypeSTX             // STMIK
+	ModTypeMED             // MED and OctaMED
+	ModTypeOKT             // Oktalyzer
+	ModTypeDSM             // DSIK
+	ModTypeAMF             // DSMI
+	ModTypeGDM             // General DigiMusic
+	ModTypeIMF             // Imago Orpheus
+	ModTypeUMX             // Unreal music package
+	ModTypeUNI             // MikMod UniMod
+)
+
+var modTypeNames = [...]string{
+	ModTypeUnknown: "unknown",
+	ModTypeMOD:     "MOD",
+	ModTypeM15:     "M15",
+	ModTypeS3M:     "S3M",
+	ModTypeIT:      "IT",
+	ModTypeXM:      "XM",
+	ModTypeMTM:     "MTM",
+	ModType669:     "669",
+	ModTypeFAR:     "FAR",
+	ModTypeULT:     "ULT",
+	ModTypeSTM:     "STM",
+	ModTypeSTX:     "STX",
+	ModTypeMED:     "MED",
+	ModTypeOKT:     "OKT",
+	ModTypeDSM:     "DSM",
+	ModTypeAMF:     "AMF",
+	ModTypeGDM:     "GDM",
+	ModTypeIMF:     "IMF",
+	ModTypeUMX:     "UMX",
+	ModTypeUNI:     "UNI",
+}
+
+// String returns the usual file extension of the format, in upper
+// case, e.g. "XM".
+func (t ModType) String() string {
+	if t < 0 || int(t) >= len(modTypeNames) {
+		return modTypeNames[ModTypeUnknown]
+	}
+	return modTypeNames[t]
+}
+
+// modTypeHeaderSize is the number of bytes needed to detect any
+// format.
+const modTypeHeaderSize = 1084
+
+// magic is a signature identifying a format at a given offset.
+type magic struct {
+	offset int
+	sig    string
+	t      ModType
+}
+
+var magics = []magic{
+	{0, "Extended Module: ", ModTypeXM},
+	{0, "IMPM", ModTypeIT},
+	{44, "SCRM", ModTypeS3M},
+	{60, "SCRM", ModTypeSTX},
+	{0, "MTM", ModTypeMTM},
+	{0, "if", ModType669},
+	{0, "JN", ModType669},
+	{0, "FAR\xfe", ModTypeFAR},
+	{0, "MAS_UTrack_V00", ModTypeULT},
+	{20, "!Scream!", ModTypeSTM},
+	{20, "BMOD2STM", ModTypeSTM},
+	{20, "WUZAMOD!", ModTypeSTM},
+	{0, "MMD", ModTypeMED},
+	{0, "OKTASONG", ModTypeOKT},
+	{8, "DSMF", ModTypeDSM},
+	{0, "AMF", ModTypeAMF},
+	{0, "GDM\xfe", ModTypeGDM},
+	{60, "IM10", ModTypeIMF},
+	{0, "\xc1\x83\x2a\x9e", ModTypeUMX},
+	{0, "APUN", ModTypeUNI},
+	{0, "UN0", ModTypeUNI},
+}
+
+// detectModType returns the format of module data b, falling back on
+// the tracker name reported by the loader that parsed it.
+func detectModType(b []byte, tracker string) ModType {
+	for _, m := range magics {
+		if len(b) >= m.offset+len(m.sig) && string(b[m.offset:m.offset+len(m.sig)]) == m.sig {
+			return m.t
+		}
+	}
+	if protracker.Test(b) {
+		return ModTypeMOD
+	}
+	if strings.Contains(strings.ToLower(tracker), "soundtracker") {
+		return ModTypeM15
+	}
+	return ModTypeUnknown
+}
+
+// detectFileModType is like detectModType, but reads the data from the
+// file designated by filename.
+func detectFileModType(filename string, tracker string) ModType {
+	f, err := os.Open(filename)
+	if err != nil {
+		return detectModType(nil, tracker)
+	}
+	defer f.Close()
+	var b bytes.Buffer
+	io.CopyN(&b, f, modTypeHeaderSize)
+	return detectModType(b.Bytes(), tracker)
+}
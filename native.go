@@ -0,0 +1,82 @@
+package mikmod
+
+/*
+#cgo linux LDFLAGS: -lpthread
+#include <errno.h>
+#include <pthread.h>
+#include <time.h>
+#include <mikmod.h>
+
+static pthread_t native_thread;
+static int native_running;
+static long native_period;
+
+// native_loop calls MikMod_Update every native_period nanoseconds
+// until native_running is cleared.
+static void *native_loop(void *arg) {
+	struct timespec ts;
+	ts.tv_sec = native_period / 1000000000L;
+	ts.tv_nsec = native_period % 1000000000L;
+	while (__atomic_load_n(&native_running, __ATOMIC_ACQUIRE)) {
+		nanosleep(&ts, NULL);
+		MikMod_Update();
+	}
+	return NULL;
+}
+
+static int native_start(long period) {
+	if (__atomic_load_n(&native_running, __ATOMIC_ACQUIRE))
+		return EBUSY;
+	native_period = period;
+	__atomic_store_n(&native_running, 1, __ATOMIC_RELEASE);
+	return pthread_create(&native_thread, NULL, native_loop, NULL);
+}
+
+static void native_stop(void) {
+	__atomic_store_n(&native_running, 0, __ATOMIC_RELEASE);
+	pthread_join(native_thread, NULL);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// native is true while the native update thread runs, and nativeLoop
+// is the exited channel of the update loop it was started for.  They
+// are guarded by mu.
+var (
+	native     bool
+	nativeLoop chan struct{}
+)
+
+// startNative starts the native update thread, which calls
+// MikMod_Update every update period, independently of the Go
+// scheduler.  It fails if the thread of an abandoned update loop still
+// runs.  It expects mu to be locked.
+func startNative() error {
+	if native {
+		return errNativeBusy
+	}
+	if rc := C.native_start(C.long(updatePeriod)); rc != 0 {
+		return fmt.Errorf("mikmod: cannot start update thread: %w", syscall.Errno(rc))
+	}
+	native = true
+	return nil
+}
+
+// stopNative stops the native update thread, waiting for it to exit,
+// if it runs.  It expects mu to be locked.
+func stopNative() {
+	if !native {
+		return
+	}
+	C.native_stop()
+	native = false
+	nativeLoop = nil
+}
+
+var errNativeBusy = errors.New("mikmod: the update thread of an abandoned update loop still runs")
@@ -0,0 +1,448 @@
+//go:build !cgo
+
+package mikmod
+
+// Without cgo, libmikmod cannot be used, and the package falls back to
+// the pure Go player in the protracker package.  Only ProTracker
+// modules and their multichannel variants can be loaded, there is no
+// live playback through output drivers, and only the parts of the API
+// below are available: loading modules, inspecting them, and rendering
+// them offline or as a Stream, e.g. to play them through an external
+// audio library.  Players and Mixers work as with libmikmod.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/death/go-mikmod/protracker"
+)
+
+// renderBufferSize is the number of bytes mixed per iteration when
+// rendering.
+const renderBufferSize = 32768
+
+// defaultMixFrequency is the mixing frequency used unless Options
+// specifies another.
+const defaultMixFrequency = 44100
+
+// Options controls how the package is initialized.  Without cgo, only
+// the options affecting the output format are available.
+type Options struct {
+	// MixFrequency is the mixing frequency, in Hz.  If 0, 44100 Hz
+	// is used.
+	MixFrequency int
+
+	// Mono selects mono output instead of stereo output.
+	Mono bool
+}
+
+var (
+	mu        sync.Mutex
+	initCount int
+	format    Format
+)
+
+// ErrNotInitialized is returned when using the package before
+// initializing it.
+var ErrNotInitialized = errors.New("mikmod: not initialized")
+
+// ErrNotAModule is returned when loading data that is not a
+// ProTracker module, the only format supported without cgo.
+var ErrNotAModule = protracker.ErrNotAModule
+
+// Version returns the version of libmikmod, which is 0.0.0 without
+// cgo.
+func Version() (major int, minor int, rev int) { return 0, 0, 0 }
+
+// Init initializes the package with default options.  Make sure to
+// call Uninit when done.  Initialization is reference-counted.
+func Init() error { return InitWithOptions(Options{}) }
+
+// InitNoSound is the same as Init without cgo, as there is no live
+// output anyway.
+func InitNoSound() error { return Init() }
+
+// InitWithOptions initializes the package as specified by opts.  If
+// the package is already initialized, opts is ignored and only the
+// reference count is incremented.
+func InitWithOptions(opts Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount > 0 {
+		initCount++
+		return nil
+	}
+	format = Format{SampleRate: opts.MixFrequency, Channels: 2, BitsPerSample: 16}
+	if format.SampleRate == 0 {
+		format.SampleRate = defaultMixFrequency
+	}
+	if opts.Mono {
+		format.Channels = 1
+	}
+	initCount = 1
+	return nil
+}
+
+// Uninit undoes a call to Init.
+func Uninit() {
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount > 0 {
+		initCount--
+	}
+}
+
+// Shutdown uninitializes the package however many times Init was
+// called.  Without cgo, there is nothing to wait for, so it always
+// returns nil.
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	defer mu.Unlock()
+	initCount = 0
+	return nil
+}
+
+// IsInitialized returns true if the package is initialized, and false
+// otherwise.
+func IsInitialized() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return initCount > 0
+}
+
+// checkInitialized returns ErrNotInitialized unless the package is
+// initialized.
+func checkInitialized() error {
+	if !IsInitialized() {
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// currentFormat returns the format modules are rendered in.
+func currentFormat() Format {
+	mu.Lock()
+	defer mu.Unlock()
+	return format
+}
+
+// OutputFormat returns the format modules are rendered in.
+func OutputFormat() Format { return currentFormat() }
+
+// MixFrequency returns the mixing frequency, in Hz.
+func MixFrequency() int { return currentFormat().SampleRate }
+
+// Module is a loaded module.
+type Module struct {
+	module   *protracker.Module
+	filename string
+	loop     bool
+}
+
+// LoadModuleFromFile attempts to load a module from the file
+// designated by filename.
+func LoadModuleFromFile(filename string) (*Module, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	data, err := translateFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadModule(data, filename)
+	if err != nil {
+		return nil, err
+	}
+	m.filename = filename
+	return m, nil
+}
+
+// LoadModuleFromFileMapped is like LoadModuleFromFile, but maps the
+// file into memory while it loads, where the system supports it.
+func LoadModuleFromFileMapped(filename string) (*Module, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	b, unmap, err := mapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+	if b, err = translate(b); err != nil {
+		return nil, loadError(filename, -1, err)
+	}
+	m, err := loadModule(b, filename)
+	if err != nil {
+		return nil, err
+	}
+	m.filename = filename
+	return m, nil
+}
+
+// LoadModuleFromSlice attempts to load a module from the supplied byte
+// slice.
+func LoadModuleFromSlice(b []byte) (*Module, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	b, err := translate(b)
+	if err != nil {
+		return nil, loadError("", -1, err)
+	}
+	return loadModule(b, "")
+}
+
+// LoadModuleFromSliceNoClone is like LoadModuleFromSlice.  Without
+// cgo, modules never keep a reference to the data they are loaded
+// from.
+func LoadModuleFromSliceNoClone(b []byte) (*Module, error) { return LoadModuleFromSlice(b) }
+
+// loadModule loads a module from the supplied byte slice.  Errors
+// mention filename, the file the data comes from, if any.
+func loadModule(b []byte, filename string) (*Module, error) {
+	module, err := protracker.Load(b)
+	if err != nil {
+		return nil, loadError(filename, -1, err)
+	}
+	return &Module{module: module}, nil
+}
+
+// Filename returns the name of the file the module was loaded from,
+// or "" if it was loaded from memory.
+func (m *Module) Filename() string { return m.filename }
+
+// Title returns the module's song name.
+func (m *Module) Title() string {
+	return decodeText([]byte(m.module.Title), CurrentCharset(), m.Tracker())
+}
+
+// NumChannels returns the number of channels used by the module.
+func (m *Module) NumChannels() int { return m.module.Channels }
+
+// NumPositions returns the number of song positions.
+func (m *Module) NumPositions() int { return len(m.module.Orders) }
+
+// NumPatterns returns the number of song patterns.
+func (m *Module) NumPatterns() int { return len(m.module.Patterns) }
+
+// NumInstruments returns the number of instruments in the module,
+// which for ProTracker modules are its samples.
+func (m *Module) NumInstruments() int { return m.NumSamples() }
+
+// NumSamples returns the number of samples in the module.
+func (m *Module) NumSamples() int { return len(m.module.Samples) }
+
+// Tracker returns the name of the tracker used to create the song.
+func (m *Module) Tracker() string { return "Protracker (" + m.module.Signature + ")" }
+
+// Type returns the format of the file the module was loaded from,
+// which is always ModTypeMOD without cgo.
+func (m *Module) Type() ModType { return ModTypeMOD }
+
+// Comment returns the song comment, which ProTracker modules do not
+// have.
+func (m *Module) Comment() string { return "" }
+
+// SetLoop controls whether the module's playback should loop.
+func (m *Module) SetLoop(value bool) { m.loop = value }
+
+// Loop returns true if the module's playback should loop, and false
+// otherwise.
+func (m *Module) Loop() bool { return m.loop }
+
+// Close frees the module, making it unusable.
+func (m *Module) Close() error {
+	if m != nil {
+		m.module = nil
+	}
+	return nil
+}
+
+// newPlayer returns a pure Go player for m in format f.
+func (m *Module) newPlayer(f Format, loop bool) *protracker.Player {
+	p := protracker.NewPlayer(m.module, f.SampleRate, f.Channels)
+	p.SetLoop(loop && m.loop)
+	return p
+}
+
+// render plays the module from the start to its end, calling fn with
+// each mixed buffer.
+func render(m *Module, fn func(b []byte) error) error {
+	if err := checkInitialized(); err != nil {
+		return err
+	}
+	if err := m.usable(); err != nil {
+		return err
+	}
+	f := currentFormat()
+	p := m.newPlayer(f, false)
+	buf := make([]byte, renderBufferSize)
+	var limiter limiterState
+	for {
+		n, err := p.Read(buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		limiter.apply(buf[:n], f)
+		if err := fn(buf[:n]); err != nil {
+			return err
+		}
+	}
+}
+
+// Render renders the module, from start to end.  It returns the PCM
+// data along with its format.
+func Render(m *Module) ([]byte, Format, error) {
+	var b bytes.Buffer
+	format, err := RenderTo(m, &b)
+	if err != nil {
+		return nil, format, err
+	}
+	return b.Bytes(), format, nil
+}
+
+// RenderTo is like Render, but writes the PCM data to w as it is
+// mixed.
+func RenderTo(m *Module, w io.Writer) (Format, error) {
+	format := currentFormat()
+	err := render(m, func(b []byte) error {
+		_, err := w.Write(b)
+		return err
+	})
+	return format, err
+}
+
+// RenderToWAV renders the module, from start to end, into a WAV file
+// designated by filename.
+func RenderToWAV(m *Module, filename string) error {
+	if err := checkInitialized(); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	format := currentFormat()
+	if err := writeWAVHeader(f, format, 0); err != nil {
+		f.Close()
+		return err
+	}
+	var size uint32
+	err = render(m, func(b []byte) error {
+		size += uint32(len(b))
+		_, err := f.Write(b)
+		return err
+	})
+	if err == nil {
+		err = finishWAV(f, format, size, wavInfoChunk(wavTags(m)))
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MeasureDuration renders the module offline, without looping, and
+// returns the duration of the song.
+func MeasureDuration(m *Module) (time.Duration, error) {
+	format := currentFormat()
+	var size int64
+	err := render(m, func(b []byte) error {
+		size += int64(len(b))
+		return nil
+	})
+	frames := size / int64(format.FrameSize())
+	return time.Duration(frames) * time.Second / time.Duration(format.SampleRate), err
+}
+
+// StreamOptions controls the behavior of a Stream.
+type StreamOptions struct {
+	// Loop makes the stream honor the module's loop setting, in
+	// which case it may never end.
+	Loop bool
+
+	// Filters are applied, in order, to the PCM data before it is
+	// returned by Read.
+	Filters []Filter
+
+	// Speed, if not 0, changes the playback speed without changing
+	// the pitch, e.g. 0.8 to practice along with a module at a
+	// slower tempo.  It requires 16-bit output.
+	Speed float64
+
+	// SampleRate, if not 0, is the sample rate to convert the PCM
+	// data to, in Hz, e.g. to feed a device running at 48000 Hz, and
+	// Resample the quality of the conversion.  It requires 16-bit
+	// output.
+	SampleRate int
+	Resample   ResampleQuality
+}
+
+// Stream is an io.Reader yielding the PCM data of a module, mixed on
+// demand as it is read.  Without cgo, several streams may play at the
+// same time.
+type Stream struct {
+	format  Format
+	player  *protracker.Player
+	limiter limiterState
+	filters filterChain
+
+	// read reads the PCM data, changed as StreamOptions requested.
+	read func(p []byte) (int, error)
+}
+
+// NewStream starts playing a module as a stream.
+func NewStream(m *Module, opts StreamOptions) (*Stream, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	if err := m.usable(); err != nil {
+		return nil, err
+	}
+	format := currentFormat()
+	s := &Stream{
+		player:  m.newPlayer(format, opts.Loop),
+		filters: filterChain{filters: opts.Filters},
+	}
+	var err error
+	s.read, s.format, err = streamReader(s.player.Read, format, opts.Speed, opts.SampleRate, opts.Resample)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Format returns the format of the PCM data yielded by the stream.
+func (s *Stream) Format() Format { return s.format }
+
+// Read reads up to len(p) bytes of PCM data, always a whole number of
+// sample frames.  It returns io.EOF when the song has ended.
+func (s *Stream) Read(p []byte) (int, error) {
+	if s.player == nil {
+		return 0, errStreamClosed
+	}
+	n, err := s.read(p)
+	s.limiter.apply(p[:n], s.format)
+	s.filters.apply(p[:n], s.format)
+	return n, err
+}
+
+// Close stops the stream.
+func (s *Stream) Close() error {
+	if s.player == nil {
+		return errStreamClosed
+	}
+	s.player = nil
+	return nil
+}
+
+var errStreamClosed = errors.New("mikmod: stream is closed")
@@ -0,0 +1,56 @@
+//go:build !cgo
+
+package mikmod_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/death/go-mikmod"
+)
+
+// TestProTrackerFallback loads and plays the fuzzing seed module with
+// the pure Go player used without cgo.
+func TestProTrackerFallback(t *testing.T) {
+	if _, err := mikmod.LoadModuleFromSlice(mikmod.FuzzSeeds()[0]); !errors.Is(err, mikmod.ErrNotInitialized) {
+		t.Errorf("got %v loading before Init, want %v", err, mikmod.ErrNotInitialized)
+	}
+	if err := mikmod.InitNoSound(); err != nil {
+		t.Fatal(err)
+	}
+	defer mikmod.Uninit()
+	if _, err := mikmod.LoadModuleFromSlice([]byte("not a module")); err == nil {
+		t.Error("loaded a module from garbage")
+	}
+	m, err := mikmod.LoadModuleFromSlice(mikmod.FuzzSeeds()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if m.NumChannels() != 4 || m.NumPositions() != 1 || m.Type() != mikmod.ModTypeMOD {
+		t.Errorf("got %d channels, %d positions, type %v", m.NumChannels(), m.NumPositions(), m.Type())
+	}
+	// One pattern of 64 rows at speed 6 and tempo 125.
+	const want = 7680 * time.Millisecond
+	if d, err := mikmod.MeasureDuration(m); err != nil || d != want {
+		t.Errorf("got duration %v, %v, want %v", d, err, want)
+	}
+	b, f, err := mikmod.Render(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Duration(len(b)/f.FrameSize()) * time.Second / time.Duration(f.SampleRate); got != want {
+		t.Errorf("rendered %v, want %v", got, want)
+	}
+	s, err := mikmod.NewStream(m, mikmod.StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	n, err := io.Copy(io.Discard, s)
+	if err != nil || n != int64(len(b)) {
+		t.Errorf("streamed %d bytes, %v, want %d", n, err, len(b))
+	}
+}
@@ -0,0 +1,55 @@
+//go:build cgo
+
+package mikmod
+
+import "time"
+
+// NowPlayingInfo summarizes the playing module for status bars and
+// on-screen displays.
+type NowPlayingInfo struct {
+	// Playing is true if a module is playing, even if paused.  The
+	// other fields are zero otherwise.
+	Playing bool
+
+	Title   string
+	Tracker string
+
+	// Elapsed is the playing time so far, and Total the duration of
+	// the song if it was measured with MeasureDuration, or 0.
+	Elapsed time.Duration
+	Total   time.Duration
+
+	// Position is the index of the current song position, and Row
+	// the current row in the pattern it plays.
+	Position int
+	Row      int
+
+	// Speed is the number of ticks per row, and BPM the tempo.
+	Speed int
+	BPM   int
+
+	// Channels is the number of channels of the module.
+	Channels int
+}
+
+// NowPlaying returns a summary of the playing module, taken from
+// Status.
+func NowPlaying() NowPlayingInfo {
+	s := Status()
+	if !s.Playing {
+		return NowPlayingInfo{}
+	}
+	m := s.Module
+	return NowPlayingInfo{
+		Playing:  true,
+		Title:    s.Title,
+		Tracker:  m.Tracker(),
+		Elapsed:  s.Elapsed,
+		Total:    time.Duration(m.duration.Load()),
+		Position: s.Position,
+		Row:      s.Row,
+		Speed:    s.Speed,
+		BPM:      s.Tempo,
+		Channels: m.NumChannels(),
+	}
+}
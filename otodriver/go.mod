@@ -0,0 +1,15 @@
+module github.com/death/go-mikmod/otodriver
+
+go 1.24
+
+require (
+	github.com/death/go-mikmod v0.0.0
+	github.com/ebitengine/oto/v3 v3.3.2
+)
+
+require (
+	github.com/ebitengine/purego v0.8.0 // indirect
+	golang.org/x/sys v0.31.0 // indirect
+)
+
+replace github.com/death/go-mikmod => ../
@@ -0,0 +1,112 @@
+// Package otodriver plays MikMod modules through oto, so that module
+// music can be heard on every platform oto supports without relying
+// on libmikmod's native output drivers.
+//
+// All players share a single oto context, created in the output format
+// of the first one.  See the mikmod package documentation on using
+// other audio libraries.
+package otodriver
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/death/go-mikmod"
+	"github.com/ebitengine/oto/v3"
+)
+
+var (
+	contextMu     sync.Mutex
+	context       *oto.Context
+	contextFormat mikmod.Format
+)
+
+// getContext returns the process's oto context, creating it for format
+// f if necessary.  oto allows only one context per process, so all
+// players must share the same format.
+func getContext(f mikmod.Format) (*oto.Context, error) {
+	contextMu.Lock()
+	defer contextMu.Unlock()
+
+	if context != nil {
+		if f != contextFormat {
+			return nil, fmt.Errorf("otodriver: format %+v differs from context format %+v", f, contextFormat)
+		}
+		return context, nil
+	}
+
+	format, err := otoFormat(f)
+	if err != nil {
+		return nil, err
+	}
+	ctx, ready, err := oto.NewContext(&oto.NewContextOptions{
+		SampleRate:   f.SampleRate,
+		ChannelCount: f.Channels,
+		Format:       format,
+	})
+	if err != nil {
+		return nil, err
+	}
+	<-ready
+	context, contextFormat = ctx, f
+	return context, nil
+}
+
+// Player plays a module through oto.  Remember to Close it when done.
+type Player struct {
+	stream *mikmod.Stream
+	player *oto.Player
+}
+
+// NewPlayer prepares to play a module through oto, creating the oto
+// context on first use.  Any module currently playing is stopped.
+func NewPlayer(m *mikmod.Module) (*Player, error) {
+	stream, err := mikmod.NewStream(m, mikmod.StreamOptions{Loop: true})
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := getContext(stream.Format())
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return &Player{
+		stream: stream,
+		player: ctx.NewPlayer(stream),
+	}, nil
+}
+
+// Play starts or resumes playback.
+func (p *Player) Play() { p.player.Play() }
+
+// Pause pauses playback.
+func (p *Player) Pause() { p.player.Pause() }
+
+// IsPlaying returns true if the player is playing, and false
+// otherwise.
+func (p *Player) IsPlaying() bool { return p.player.IsPlaying() }
+
+// SetVolume sets the player's volume, from 0 to 1.
+func (p *Player) SetVolume(v float64) { p.player.SetVolume(v) }
+
+// Close stops playback and frees the player.
+func (p *Player) Close() error {
+	err := p.player.Close()
+	if serr := p.stream.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// otoFormat returns the oto sample format corresponding to f.
+func otoFormat(f mikmod.Format) (oto.Format, error) {
+	switch f.BitsPerSample {
+	case 8:
+		return oto.FormatUnsignedInt8, nil
+	case 16:
+		return oto.FormatSignedInt16LE, nil
+	case 32:
+		return oto.FormatFloat32LE, nil
+	}
+	return 0, fmt.Errorf("otodriver: unsupported sample size %d", f.BitsPerSample)
+}
@@ -0,0 +1,113 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PassPolicy makes modules play through a number of times before they
+// end, as jukeboxes usually do, whether or not they loop.  The zero
+// value disables it.
+type PassPolicy struct {
+	// Passes is the number of times the song plays through.  A pass
+	// ends when the song ends or jumps back to an earlier position,
+	// e.g. to its restart position, so loops within a position are
+	// honored.  If 0, the policy is disabled.
+	Passes int
+
+	// FadeOut is the duration of the fade applied after the last
+	// pass, while the song goes on, before it ends.  If 0, the song
+	// ends right after the last pass.
+	FadeOut time.Duration
+}
+
+// passPolicy is the policy set by SetPassPolicy.
+var passPolicy atomic.Pointer[PassPolicy]
+
+// SetPassPolicy sets the policy applied to the modules played from
+// now on, and to the playing one from its current pass.  Once the
+// module ends, the queued module or the next entry of the playlist
+// starts as usual.  Seeking back, e.g. with PrevPosition, ends a pass
+// too.  Passes are not counted while a section is looped with
+// LoopBetween.
+func SetPassPolicy(p PassPolicy) {
+	if p.Passes <= 0 {
+		passPolicy.Store(nil)
+		return
+	}
+	passPolicy.Store(&p)
+}
+
+// passState tracks the passes of the playing module.  It is only used
+// by the update loop.
+var passState struct {
+	policy   *PassPolicy
+	module   *Module
+	lastPos  int
+	passes   int
+	fadeDone <-chan struct{}
+	finished bool
+}
+
+// applyPasses counts the passes of the playing module according to the
+// pass policy, restarting it, fading it out or ending it as needed,
+// and returns true if it restarted a song that ended.  It is called by
+// the update loop.
+func applyPasses() bool {
+	p := passPolicy.Load()
+	m := playingModule()
+	s := &passState
+	if p == nil || m == nil {
+		s.policy, s.module = nil, nil
+		return false
+	}
+	pos, numPos := int(m.module.sngpos), int(m.module.numpos)
+	if s.policy != p || s.module != m {
+		s.policy, s.module, s.lastPos, s.passes, s.fadeDone, s.finished = p, m, pos, 0, nil, false
+	}
+	lastPos := s.lastPos
+	s.lastPos = pos
+	if s.finished || region.Load() != nil {
+		return false
+	}
+
+	if s.fadeDone != nil {
+		select {
+		case <-s.fadeDone:
+			s.fadeDone, s.finished = nil, true
+			C.Player_SetPosition(C.UWORD(numPos))
+			setMusicLevel(1)
+			return false
+		default:
+		}
+	}
+
+	ended := pos >= numPos
+	if !ended && pos >= lastPos {
+		return false
+	}
+	s.passes++
+	switch {
+	case s.fadeDone != nil, s.passes < p.Passes:
+		// Play on.
+	case p.FadeOut > 0:
+		s.fadeDone = startFade(0, p.FadeOut)
+	default:
+		s.finished = true
+		if !ended {
+			C.Player_SetPosition(C.UWORD(numPos))
+		}
+		return false
+	}
+	if !ended {
+		return false
+	}
+	C.Player_SetPosition(C.UWORD(m.module.reppos))
+	s.lastPos = int(m.module.reppos)
+	return true
+}
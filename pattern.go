@@ -0,0 +1,218 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// UniMod opcodes, as enumerated by libmikmod's internal headers.
+enum {
+	uniNote = 1,
+	uniInstrument = 2,
+	uniPTEffect0 = 3,
+	uniPTEffectC = 15,
+	uniPTEffectF = 18,
+	uniS3MEffectA = 19,
+	uniS3MEffectT = 26,
+	uniVolEffects = 30,
+	uniITEffectT = 50,
+	uniLast = 62,
+};
+
+// volVolume is the volume column effect setting the volume.
+enum { volVolume = 1 };
+
+// uniOperands holds the number of operand bytes of each opcode, as in
+// libmikmod's munitrk.c, which does not export it.
+static const UBYTE uniOperands[uniLast] = {
+	0, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // ProTracker
+	1, 1, 1, 1, 1, 1, 1, 1, 1, // Scream Tracker 3
+	0, 1, 2, // key off, key fade, volume column
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // FastTracker 2
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 1, // Impulse Tracker
+	2, 2, 0, 0, 0, 2, // UltraTracker, OctaMED, Oktalyzer
+};
+
+// findRow returns the data of row row of UniMod track t, starting with
+// the byte holding the number of times the row repeats in its high 3
+// bits and its length in its low 5 bits, or NULL if the track has no
+// such row.
+static const UBYTE *findRow(const UBYTE *t, int row) {
+	if (t == NULL)
+		return NULL;
+	while (*t & 0x1f) {
+		int repeat = (*t >> 5) + 1;
+		if (row < repeat)
+			return t;
+		row -= repeat;
+		t += *t & 0x1f;
+	}
+	return NULL;
+}
+
+// setVolumeColumn moves a set volume effect decoded into cell to its
+// volume, once another effect follows it: loaders write the volume
+// column before the effect, as ProTracker effect C for XM and S3M.
+static void setVolumeColumn(int *cell) {
+	if (cell[2] == 0xC && cell[4] < 0) {
+		cell[4] = cell[3];
+		cell[2] = cell[3] = -1;
+	}
+}
+
+// decodeCell decodes row row of track t into cell: the note, the
+// instrument, the first ProTracker effect and its parameter, the
+// volume set by the volume column, and the speed and tempo set by any
+// effect, each -1 if absent.  Decoding stops at an opcode unknown to
+// uniOperands.
+static void decodeCell(const UBYTE *t, int row, int *cell) {
+	const UBYTE *p, *end;
+	int i;
+	for (i = 0; i < 7; i++)
+		cell[i] = -1;
+	if ((p = findRow(t, row)) == NULL)
+		return;
+	end = p + (*p & 0x1f);
+	for (p++; p < end; p += 1 + uniOperands[*p]) {
+		int op = *p, param;
+		if (op == 0 || op >= uniLast || p + uniOperands[op] >= end)
+			break;
+		param = uniOperands[op] > 0 ? p[1] : 0;
+		if (op == uniNote) {
+			cell[0] = param;
+			continue;
+		}
+		if (op == uniInstrument) {
+			cell[1] = param;
+			continue;
+		}
+		setVolumeColumn(cell);
+		if (op >= uniPTEffect0 && op <= uniPTEffectF) {
+			if (cell[2] < 0) {
+				cell[2] = op - uniPTEffect0;
+				cell[3] = param;
+			}
+			if (op == uniPTEffectF && param > 0 && param < 0x20)
+				cell[5] = param;
+			else if (op == uniPTEffectF && param >= 0x20)
+				cell[6] = param;
+		} else if (op == uniVolEffects && param == volVolume)
+			cell[4] = p[2];
+		else if (op == uniS3MEffectA && param > 0)
+			cell[5] = param;
+		else if ((op == uniS3MEffectT || op == uniITEffectT) && param >= 0x20)
+			cell[6] = param;
+	}
+}
+*/
+import "C"
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// PatternCell is the content of a channel at a row of a pattern.
+type PatternCell struct {
+	// Note is the note played, counting semitones from C-0, or -1
+	// if none is.
+	Note int
+
+	// Instrument is the index of the instrument or sample played,
+	// or -1 if none is.
+	Instrument int
+
+	// Effect is the ProTracker effect, from 0x0 to 0xF, and Param
+	// its parameter, or -1 if there is none.  Effects specific to
+	// other formats are not decoded, except for Speed and Tempo.
+	Effect int
+	Param  int
+
+	// Volume is the volume set by the volume column, from 0 to 64,
+	// or -1 if none is.  A lone volume column of XM and S3M modules
+	// is indistinguishable from effect C, and decodes as one.
+	Volume int
+
+	// Speed and Tempo are the speed and tempo set by the effects of
+	// the cell, whatever the format, or -1 if none are.
+	Speed int
+	Tempo int
+}
+
+// noteNames are the names of the notes of an octave.
+var noteNames = [...]string{"C-", "C#", "D-", "D#", "E-", "F-", "F#", "G-", "G#", "A-", "A#", "B-"}
+
+// NoteName returns the name of the note as trackers show it, e.g.
+// "C#4", or "---" if there is no note.
+func (c PatternCell) NoteName() string {
+	if c.Note < 0 {
+		return "---"
+	}
+	return noteNames[c.Note%12] + strconv.Itoa(c.Note/12)
+}
+
+// PatternRow is a row of a pattern across all channels.
+type PatternRow struct {
+	// Row is the index of the row in its pattern.
+	Row int
+
+	// Cells holds the content of each channel.
+	Cells []PatternCell
+}
+
+// PatternRows returns the rows of pattern pat from first, up to n of
+// them, or fewer if the pattern ends first.
+func (m *Module) PatternRows(pat, first, n int) []PatternRow {
+	mod := m.mod()
+	if pat < 0 || pat >= int(mod.numpat) || mod.patterns == nil || mod.pattrows == nil {
+		return nil
+	}
+	numRows := int(unsafe.Slice(mod.pattrows, mod.numpat)[pat])
+	numChn := int(mod.numchn)
+	tracks := unsafe.Slice(mod.patterns, int(mod.numpat)*numChn)[pat*numChn:][:numChn]
+	first = max(first, 0)
+	var rows []PatternRow
+	for row := first; row < min(first+n, numRows); row++ {
+		r := PatternRow{Row: row, Cells: make([]PatternCell, numChn)}
+		for ch, track := range tracks {
+			var t *C.UBYTE
+			if mod.tracks != nil && int(track) < int(mod.numtrk) {
+				t = unsafe.Slice(mod.tracks, mod.numtrk)[track]
+			}
+			var cell [7]C.int
+			C.decodeCell(t, C.int(row), &cell[0])
+			r.Cells[ch] = PatternCell{
+				Note:       int(cell[0]),
+				Instrument: int(cell[1]),
+				Effect:     int(cell[2]),
+				Param:      int(cell[3]),
+				Volume:     int(cell[4]),
+				Speed:      int(cell[5]),
+				Tempo:      int(cell[6]),
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// PatternWindow returns the rows of the pattern being played, from
+// before rows before the current row to after rows after it, along
+// with the index of the current row in the returned slice, for a
+// scrolling pattern display.  It returns nil and -1 if no module is
+// playing.
+func PatternWindow(before, after int) ([]PatternRow, int) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return nil, -1
+	}
+	var pos, row int
+	do(func() { pos, row = int(m.module.sngpos), int(m.module.patpos) })
+	if pos >= m.NumPositions() {
+		return nil, -1
+	}
+	pat := m.Orders()[pos]
+	first := max(row-before, 0)
+	return m.PatternRows(pat, first, row-first+after+1), row - first
+}
@@ -0,0 +1,65 @@
+//go:build cgo
+
+package mikmod_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/death/go-mikmod"
+)
+
+// TestPatternRowsVolumeColumn decodes modules whose first row sets the
+// volume column along with the speed on the first channel and the
+// tempo on the second, and whose second row sets the volume column
+// along with a jump back to the start.
+func TestPatternRowsVolumeColumn(t *testing.T) {
+	if err := mikmod.InitNoSound(); err != nil {
+		t.Fatal(err)
+	}
+	defer mikmod.Uninit()
+	want := [2][2]mikmod.PatternCell{
+		{
+			{Note: -1, Instrument: -1, Effect: 0xF, Param: 0x03, Volume: 48, Speed: 3, Tempo: -1},
+			{Note: -1, Instrument: -1, Effect: 0xF, Param: 0x90, Volume: 32, Speed: -1, Tempo: 144},
+		},
+		{
+			{Note: -1, Instrument: -1, Effect: 0xB, Param: 0, Volume: 64, Speed: -1, Tempo: -1},
+			{Note: -1, Instrument: -1, Effect: -1, Param: -1, Volume: -1, Speed: -1, Tempo: -1},
+		},
+	}
+	// S3M speed and tempo are effects of their own.
+	wantS3M := want
+	wantS3M[0][0].Effect, wantS3M[0][0].Param = -1, -1
+	wantS3M[0][1].Effect, wantS3M[0][1].Param = -1, -1
+	for _, tt := range []struct {
+		file string
+		want [2][2]mikmod.PatternCell
+	}{
+		{"testdata/volcol.xm", want},
+		{"testdata/volcol.s3m", wantS3M},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			m, err := mikmod.LoadModuleFromFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+			rows := m.PatternRows(m.Orders()[0], 0, 2)
+			if len(rows) != 2 {
+				t.Fatalf("got %d rows, want 2", len(rows))
+			}
+			for i, r := range rows {
+				for ch, want := range tt.want[i] {
+					if got := r.Cells[ch]; got != want {
+						t.Errorf("row %d channel %d: got %+v, want %+v", i, ch, got, want)
+					}
+				}
+			}
+			// Both rows play at speed 3 and tempo 144 before the jump.
+			if got, want := m.BuildSeekTable().Duration(), 2*3*2500*time.Millisecond/144; got != want {
+				t.Errorf("got duration %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// String formats the cell as trackers show it, e.g. "C#4 01 A0F",
+// with the instrument numbered from 1 and dots for empty fields.
+func (c PatternCell) String() string {
+	ins := ".."
+	if c.Instrument >= 0 {
+		ins = fmt.Sprintf("%02X", c.Instrument+1)
+	}
+	effect := "..."
+	if c.Effect >= 0 {
+		effect = fmt.Sprintf("%X%02X", c.Effect, c.Param)
+	}
+	return c.NoteName() + " " + ins + " " + effect
+}
+
+// String formats the row as trackers show it: its index in hex, then
+// each channel's cell, separated by bars.
+func (r PatternRow) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%02X", r.Row)
+	for _, c := range r.Cells {
+		b.WriteString(" | ")
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
+
+// WritePatternText writes the module's patterns to w as tracker-style
+// text, one line per row, e.g. for diffing revisions of a module.
+// Each pattern is preceded by a header line giving its index and
+// number of rows, and followed by an empty line.
+func (m *Module) WritePatternText(w io.Writer) error {
+	if err := m.usable(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for pat := range m.NumPatterns() {
+		rows := m.PatternRows(pat, 0, maxPatternRows)
+		fmt.Fprintf(bw, "Pattern %02X (%d rows)\n", pat, len(rows))
+		for _, r := range rows {
+			fmt.Fprintln(bw, r.String())
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// maxPatternRows is larger than the number of rows of any pattern.
+const maxPatternRows = 1 << 16
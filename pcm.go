@@ -0,0 +1,65 @@
+package mikmod
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Format describes the PCM data produced by MikMod's software mixer.
+// Samples are interleaved; 8-bit samples are unsigned, 16-bit samples
+// are signed little-endian and 32-bit samples are IEEE floats.
+type Format struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+// FrameSize returns the size in bytes of one sample frame, i.e. one
+// sample for each channel.
+func (f Format) FrameSize() int { return f.Channels * f.BitsPerSample / 8 }
+
+// IsFloat returns true if samples are IEEE floats, and false if they
+// are integers.
+func (f Format) IsFloat() bool { return f.BitsPerSample == 32 }
+
+// sampleAt decodes the i-th sample of PCM data b in format f, as a
+// float between -1 and 1.
+func (f Format) sampleAt(b []byte, i int) float64 {
+	switch f.BitsPerSample {
+	case 8:
+		return (float64(b[i]) - 128) / 128
+	case 16:
+		return float64(int16(binary.LittleEndian.Uint16(b[i*2:]))) / 32768
+	default:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:])))
+	}
+}
+
+// numSamples returns the number of samples, counting each channel
+// separately, in PCM data b in format f.
+func (f Format) numSamples(b []byte) int { return len(b) / (f.BitsPerSample / 8) }
+
+// setSampleAt encodes v, a float between -1 and 1, as the i-th sample
+// of PCM data b in format f.
+func (f Format) setSampleAt(b []byte, i int, v float64) {
+	v = clamp(v, -1, 1)
+	switch f.BitsPerSample {
+	case 8:
+		b[i] = byte(clamp(v*128+128, 0, 255))
+	case 16:
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(int16(clamp(v*32768, -32768, 32767))))
+	default:
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(float32(v)))
+	}
+}
+
+// clamp returns v limited to the range [lo, hi].
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,119 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+)
+
+// pitch is the ratio set by SetPitch.  It is guarded by mu.
+var pitch = 1.0
+
+// outputFreq is the actual output rate while a pitch other than 1 is
+// applied, in which case md_mixfreq holds the scaled rate the mixer
+// computes with, and 0 otherwise.
+var outputFreq atomic.Int32
+
+var (
+	errInvalidPitch = errors.New("mikmod: pitch must be positive")
+	errPitchRange   = errors.New("mikmod: pitch out of the mixer's range at this mixing frequency")
+)
+
+// minPitchFreq is the lowest rate the mixer computes with under a
+// pitch: the mixer mixes md_mixfreq*125/(bpm*50) frames a tick, which
+// would be none below that rate at the highest tempo, 255, stalling
+// it.
+const minPitchFreq = (255*50 + 124) / 125
+
+// SetPitch changes the playback pitch and speed by ratio, e.g. 1.1 to
+// play 10% faster and higher, and 1 for normal playback.  This works
+// by making the software mixer believe that the output rate is lower
+// or higher than it really is, so it applies to all output, including
+// Streams.  The range of ratios depends on the mixing frequency: the
+// rate the mixer computes with, the mixing frequency divided by the
+// ratio, must lie between 102 Hz and the mixer's maximum of 65535 Hz,
+// e.g. ratios from about 0.67 to 432 at 44100 Hz.
+func SetPitch(ratio float64) error {
+	if !(ratio > 0) || math.IsInf(ratio, 0) {
+		return errInvalidPitch
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return ErrNotInitialized
+	}
+	if _, err := pitchFreq(mixFrequency(), ratio); err != nil {
+		return err
+	}
+	unapplyPitch()
+	pitch = ratio
+	applyPitch()
+	return nil
+}
+
+// pitchFreq returns the rate the mixer computes with at output rate
+// freq under pitch ratio, or errPitchRange if the mixer cannot.
+func pitchFreq(freq int, ratio float64) (int, error) {
+	f := math.Round(float64(freq) / ratio)
+	if f < minPitchFreq || f > math.MaxUint16 {
+		return 0, errPitchRange
+	}
+	return int(f), nil
+}
+
+// Pitch returns the ratio set by SetPitch.
+func Pitch() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return pitch
+}
+
+// applyPitch scales the rate the mixer computes with according to the
+// pitch.  If the driver settled on an output rate the pitch is out of
+// range at, the pitch goes back to 1.  It expects mu to be locked and
+// md_mixfreq to hold the actual output rate.
+func applyPitch() {
+	if pitch == 1 {
+		return
+	}
+	freq := int(C.md_mixfreq)
+	scaled, err := pitchFreq(freq, pitch)
+	if err != nil {
+		logger().Warn("mikmod: resetting the pitch", "pitch", pitch, "frequency", freq, "err", err)
+		pitch = 1
+		return
+	}
+	outputFreq.Store(int32(freq))
+	do(func() {
+		C.MikMod_Lock()
+		C.md_mixfreq = C.UWORD(scaled)
+		C.MikMod_Unlock()
+	})
+}
+
+// unapplyPitch restores md_mixfreq to the actual output rate, e.g.
+// before resetting the driver.  It expects mu to be locked.
+func unapplyPitch() {
+	freq := outputFreq.Swap(0)
+	if freq == 0 {
+		return
+	}
+	do(func() {
+		C.MikMod_Lock()
+		C.md_mixfreq = C.UWORD(freq)
+		C.MikMod_Unlock()
+	})
+}
+
+// mixFrequency returns the actual output rate.
+func mixFrequency() int {
+	if freq := outputFreq.Load(); freq != 0 {
+		return int(freq)
+	}
+	return int(C.md_mixfreq)
+}
@@ -0,0 +1,34 @@
+//go:build cgo
+
+package mikmod_test
+
+import (
+	"testing"
+
+	"github.com/death/go-mikmod"
+)
+
+func TestSetPitchRange(t *testing.T) {
+	if err := mikmod.InitNoSound(); err != nil {
+		t.Fatal(err)
+	}
+	defer mikmod.Uninit()
+	freq := float64(mikmod.MixFrequency())
+	if err := mikmod.SetPitch(1.1); err != nil {
+		t.Fatal(err)
+	}
+	for _, ratio := range []float64{
+		freq / 70000, // above the mixer's maximum rate
+		freq / 50,    // mixing less than a frame a tick
+	} {
+		if err := mikmod.SetPitch(ratio); err == nil {
+			t.Errorf("SetPitch(%g) at %g Hz succeeded", ratio, freq)
+		}
+		if got := mikmod.Pitch(); got != 1.1 {
+			t.Errorf("after SetPitch(%g), got pitch %g, want 1.1", ratio, got)
+		}
+	}
+	if got := mikmod.MixFrequency(); float64(got) != freq {
+		t.Errorf("got mixing frequency %d, want %g", got, freq)
+	}
+}
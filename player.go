@@ -0,0 +1,103 @@
+package mikmod
+
+import "sync"
+
+// Player plays a module independently of MikMod's player, from a copy
+// of its output rendered offline.  libmikmod plays one module at a
+// time, so this is how several modules can be heard together, e.g. a
+// jingle layered over music: create a Player for each, add them to a
+// Mixer, and output the Mixer's stream.
+//
+// Rendering happens when the Player is created, which stops any module
+// playing, so create Players before starting MikMod's player or a
+// Stream.
+type Player struct {
+	format Format
+
+	mu      sync.Mutex
+	pcm     []byte
+	pos     int
+	playing bool
+	loop    bool
+	gain    float64
+}
+
+// NewPlayer renders m offline and returns a stopped Player for it.  If
+// loop is true, the Player starts over when it reaches the end.
+func NewPlayer(m *Module, loop bool) (*Player, error) {
+	pcm, format, err := Render(m)
+	if err != nil {
+		return nil, err
+	}
+	return &Player{format: format, pcm: pcm, loop: loop, gain: 1}, nil
+}
+
+// Play starts or resumes playing.
+func (p *Player) Play() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pos >= len(p.pcm) {
+		p.pos = 0
+	}
+	p.playing = true
+}
+
+// Pause pauses playing.
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.playing = false
+}
+
+// Stop stops playing and rewinds to the start.
+func (p *Player) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.playing, p.pos = false, 0
+}
+
+// IsPlaying returns true if the Player is playing, and false
+// otherwise.
+func (p *Player) IsPlaying() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.playing
+}
+
+// SetGain sets the linear gain applied to the Player's output.
+func (p *Player) SetGain(g float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gain = g
+}
+
+// SetLoop controls whether the Player starts over when it reaches the
+// end.
+func (p *Player) SetLoop(loop bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loop = loop
+}
+
+// fill decodes the Player's next samples into buf, advancing it
+// accordingly, and returns the number of samples decoded.
+func (p *Player) fill(buf []float64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.playing || len(p.pcm) == 0 {
+		return 0
+	}
+	size := p.format.BitsPerSample / 8
+	for i := range buf {
+		if p.pos >= len(p.pcm) {
+			if !p.loop {
+				p.playing = false
+				return i
+			}
+			p.pos = 0
+		}
+		buf[i] = p.format.sampleAt(p.pcm, p.pos/size) * p.gain
+		p.pos += size
+	}
+	return len(buf)
+}
@@ -0,0 +1,487 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Playlist plays a list of modules one after the other.  While a
+// module plays, the next one is loaded ahead of time, and it is
+// started by the update loop as soon as the current one ends, without
+// an audible gap or any action from the caller.
+//
+// Modules added by filename are loaded and closed by the playlist;
+// modules added as such remain owned by the caller.
+type Playlist struct {
+	mu      sync.Mutex
+	entries []playlistEntry
+	index   int
+	current *Module
+	next    *preload
+	ended   bool
+	events  chan PlaylistEvent
+
+	crossfade time.Duration
+
+	repeat  RepeatMode
+	shuffle bool
+	order   []int
+	history []int
+}
+
+// RepeatMode controls what a playlist plays when an entry ends.
+type RepeatMode int
+
+const (
+	// RepeatOff stops at the end of the playlist.
+	RepeatOff RepeatMode = iota
+
+	// RepeatOne plays the current entry over and over.  Next and
+	// Previous still change entries.
+	RepeatOne
+
+	// RepeatAll starts over at the end of the playlist.
+	RepeatAll
+)
+
+// maxHistory is the number of entries remembered for Previous while
+// shuffling.
+const maxHistory = 1000
+
+// playlistEntry is either a module owned by the caller or the
+// filename of a module to be loaded.
+type playlistEntry struct {
+	module   *Module
+	filename string
+}
+
+// preload is an entry being loaded ahead of time.
+type preload struct {
+	index  int
+	module *Module
+	err    error
+	ready  chan struct{}
+}
+
+// PlaylistEvent reports a track change.
+type PlaylistEvent struct {
+	// Index is the index of the entry that started playing, or -1
+	// if the end of the playlist was reached.
+	Index int
+
+	// Module is the module that started playing, if any.
+	Module *Module
+
+	// Err is set if the entry could not be loaded, in which case it
+	// is skipped.
+	Err error
+}
+
+var errNoEntry = errors.New("mikmod: no such playlist entry")
+
+// playlistEventBuffer is the number of events buffered by a playlist.
+// Events are dropped rather than stalling playback when the buffer is
+// full.
+const playlistEventBuffer = 16
+
+// NewPlaylist returns a new, empty playlist.
+func NewPlaylist() *Playlist {
+	return &Playlist{
+		index:  -1,
+		events: make(chan PlaylistEvent, playlistEventBuffer),
+	}
+}
+
+// Add appends a module to the playlist.  The caller remains
+// responsible for closing it.
+func (p *Playlist) Add(m *Module) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = append(p.entries, playlistEntry{module: m})
+}
+
+// AddFile appends a module file to the playlist.  It is loaded when
+// needed and closed when no longer playing.
+func (p *Playlist) AddFile(filename string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = append(p.entries, playlistEntry{filename: filename})
+}
+
+// Len returns the number of entries in the playlist.
+func (p *Playlist) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Index returns the index of the entry currently playing, or -1 if
+// none is.
+func (p *Playlist) Index() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.index
+}
+
+// Events returns the channel on which track changes are reported.
+func (p *Playlist) Events() <-chan PlaylistEvent { return p.events }
+
+// SetCrossfade sets the duration of the crossfades between tracks, or
+// disables them if d is 0.  During a crossfade, the outgoing module
+// fades out while the next one fades in: the next one starts d before
+// the outgoing one ends, or right away when skipping.
+//
+// libmikmod plays one module at a time, so the rest of the outgoing
+// module, up to d of it, is mixed ahead of time when the next one
+// starts, which takes a moment, and then mixed into the output.  Like
+// the limiter, crossfades only apply to Go output drivers; with native
+// drivers, tracks change without overlapping.  The next module starts
+// early only if the song of the outgoing one ends rather than looping
+// back; see SetLoop.
+func (p *Playlist) SetCrossfade(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.crossfade = d
+	if p.current == nil {
+		return
+	}
+	if d > 0 {
+		p.current.BuildSeekTable()
+	}
+	setEndCrossfade(d)
+}
+
+// SetRepeat sets the repeat mode.
+func (p *Playlist) SetRepeat(mode RepeatMode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.repeat = mode
+	p.restartPreload()
+}
+
+// Repeat returns the repeat mode.
+func (p *Playlist) Repeat() RepeatMode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.repeat
+}
+
+// SetShuffle turns shuffling on or off.  While shuffling, entries are
+// played in a random order, each once before any is repeated, and
+// Previous goes back through the entries actually played.
+func (p *Playlist) SetShuffle(on bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.shuffle = on
+	p.order, p.history = nil, nil
+	if p.index >= 0 {
+		p.history = append(p.history, p.index)
+	}
+	p.restartPreload()
+}
+
+// Shuffle returns true if shuffling is on, and false otherwise.
+func (p *Playlist) Shuffle() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.shuffle
+}
+
+// Play starts playing the playlist from its first entry, or from a
+// random entry if shuffling.
+func (p *Playlist) Play() error {
+	p.mu.Lock()
+	i := 0
+	if p.shuffle && len(p.entries) > 0 {
+		p.reshuffle(-1)
+		i = p.order[0]
+	}
+	p.mu.Unlock()
+	return p.PlayIndex(i)
+}
+
+// PlayIndex starts playing the playlist from entry i.  Entries that
+// cannot be loaded are skipped.
+func (p *Playlist) PlayIndex(i int) error {
+	setEndHook(nil)
+
+	p.mu.Lock()
+	p.discardPreload()
+	var (
+		m   *Module
+		err error
+	)
+	// Try each entry at most once, as following may wrap around.
+	for n := 0; n < len(p.entries) && i >= 0 && i < len(p.entries); n, i = n+1, p.following(i) {
+		m, err = p.entries[i].load()
+		if err == nil {
+			break
+		}
+		p.emit(PlaylistEvent{Index: i, Err: err})
+	}
+	if m == nil {
+		p.mu.Unlock()
+		if err == nil {
+			err = errNoEntry
+		}
+		return err
+	}
+	entry := p.entries[i]
+	old := p.current
+	var oldEntry playlistEntry
+	if old != nil {
+		oldEntry = p.entries[p.index]
+	}
+	crossfade := p.crossfade
+	if crossfade > 0 {
+		m.BuildSeekTable()
+	}
+	p.mu.Unlock()
+
+	if err := swap(m, crossfade); err != nil {
+		entry.release(m)
+		return err
+	}
+
+	p.mu.Lock()
+	if old != nil && old != m {
+		oldEntry.release(old)
+	}
+	p.current, p.index, p.ended = m, i, false
+	p.remember(i)
+	p.emit(PlaylistEvent{Index: i, Module: m})
+	p.startPreload(p.upcoming(i))
+	p.mu.Unlock()
+
+	setEndCrossfade(crossfade)
+	setEndHook(p.advance)
+	return nil
+}
+
+// Next skips to the entry following the current one.
+func (p *Playlist) Next() error {
+	p.mu.Lock()
+	i := p.following(p.index)
+	p.mu.Unlock()
+	return p.PlayIndex(i)
+}
+
+// Previous skips back to the entry preceding the current one, or to
+// the entry played before the current one if shuffling.
+func (p *Playlist) Previous() error {
+	p.mu.Lock()
+	i := p.preceding(p.index)
+	if p.shuffle && len(p.history) >= 2 {
+		// Forget the current entry and the one to go back to,
+		// which PlayIndex remembers again.
+		i = p.history[len(p.history)-2]
+		p.history = p.history[:len(p.history)-2]
+	}
+	p.mu.Unlock()
+	return p.PlayIndex(i)
+}
+
+// Stop stops playing the playlist, closing any modules it loaded.
+func (p *Playlist) Stop() {
+	setEndHook(nil)
+	setEndCrossfade(0)
+	Stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.discardPreload()
+	if p.current != nil {
+		p.entries[p.index].release(p.current)
+	}
+	p.current, p.index = nil, -1
+}
+
+// following returns the index of the entry to play after entry i, or
+// -1 if there is none.
+func (p *Playlist) following(i int) int {
+	n := len(p.entries)
+	if n == 0 {
+		return -1
+	}
+	if p.shuffle {
+		if len(p.order) != n {
+			p.reshuffle(i)
+		}
+		pos := slices.Index(p.order, i)
+		if pos+1 < n {
+			return p.order[pos+1]
+		}
+		if p.repeat != RepeatAll {
+			return -1
+		}
+		p.reshuffle(-1)
+		if p.order[0] == i && n > 1 {
+			// Avoid playing the same entry twice in a row.
+			p.order[0], p.order[n-1] = p.order[n-1], p.order[0]
+		}
+		return p.order[0]
+	}
+	switch {
+	case i+1 < n:
+		return i + 1
+	case p.repeat == RepeatAll:
+		return 0
+	}
+	return -1
+}
+
+// preceding returns the index of the entry to play before entry i, or
+// -1 if there is none.
+func (p *Playlist) preceding(i int) int {
+	switch {
+	case i > 0:
+		return i - 1
+	case p.repeat == RepeatAll && len(p.entries) > 0:
+		return len(p.entries) - 1
+	}
+	return -1
+}
+
+// upcoming returns the index of the entry to play when entry i ends,
+// or -1 if there is none.
+func (p *Playlist) upcoming(i int) int {
+	if p.repeat == RepeatOne && i >= 0 {
+		return i
+	}
+	return p.following(i)
+}
+
+// reshuffle picks a new random order for the entries, starting with
+// entry first if it is not -1.
+func (p *Playlist) reshuffle(first int) {
+	p.order = rand.Perm(len(p.entries))
+	if pos := slices.Index(p.order, first); pos > 0 {
+		p.order[0], p.order[pos] = p.order[pos], p.order[0]
+	}
+}
+
+// remember records that entry i started playing, for Previous.
+func (p *Playlist) remember(i int) {
+	if !p.shuffle {
+		return
+	}
+	if len(p.history) >= maxHistory {
+		p.history = slices.Delete(p.history, 0, 1)
+	}
+	p.history = append(p.history, i)
+}
+
+// advance is the end hook used while the playlist plays.  It returns
+// the preloaded next module, if it is ready.  The end of the playlist
+// is only reported once the last module has really ended, not early.
+func (p *Playlist) advance(early bool) *Module {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.next == nil {
+		if !early && !p.ended {
+			p.ended = true
+			p.emit(PlaylistEvent{Index: -1})
+		}
+		return nil
+	}
+	select {
+	case <-p.next.ready:
+	default:
+		// Still loading; try again on the next update.
+		return nil
+	}
+
+	next := p.next
+	p.next = nil
+	if next.err != nil {
+		p.emit(PlaylistEvent{Index: next.index, Err: next.err})
+		p.startPreload(p.following(next.index))
+		return nil
+	}
+
+	if p.current != nil {
+		old, entry := p.current, p.entries[p.index]
+		go entry.release(old)
+	}
+	p.current, p.index = next.module, next.index
+	p.remember(p.index)
+	p.emit(PlaylistEvent{Index: p.index, Module: p.current})
+	p.startPreload(p.upcoming(p.index))
+	return p.current
+}
+
+// startPreload starts loading entry i, if it is not -1, to play it
+// when the current one ends.  It expects p.mu to be locked.
+func (p *Playlist) startPreload(i int) {
+	if i < 0 {
+		return
+	}
+	next := &preload{index: i, ready: make(chan struct{})}
+	p.next = next
+	entry := p.entries[i]
+	crossfade := p.crossfade > 0
+	go func() {
+		next.module, next.err = entry.load()
+		if next.err == nil && crossfade {
+			// Tell crossfadeDue when the module ends.
+			next.module.BuildSeekTable()
+		}
+		close(next.ready)
+	}()
+}
+
+// restartPreload preloads the entry to play after the current one
+// again, after a change of mode.  It expects p.mu to be locked.
+func (p *Playlist) restartPreload() {
+	if p.current == nil {
+		return
+	}
+	p.discardPreload()
+	p.startPreload(p.upcoming(p.index))
+}
+
+// discardPreload discards the preloaded entry, if any, closing it once
+// it is loaded.  It expects p.mu to be locked.
+func (p *Playlist) discardPreload() {
+	next := p.next
+	if next == nil {
+		return
+	}
+	p.next = nil
+	entry := p.entries[next.index]
+	go func() {
+		<-next.ready
+		if next.err == nil {
+			entry.release(next.module)
+		}
+	}()
+}
+
+// emit sends an event without blocking.
+func (p *Playlist) emit(e PlaylistEvent) {
+	select {
+	case p.events <- e:
+	default:
+	}
+}
+
+// load returns the entry's module, loading it if necessary.
+func (e playlistEntry) load() (*Module, error) {
+	if e.module != nil {
+		return e.module, nil
+	}
+	return LoadModuleFromFile(e.filename)
+}
+
+// release closes the entry's module m if the playlist loaded it.
+func (e playlistEntry) release(m *Module) {
+	if e.module == nil {
+		m.Close()
+	}
+}
@@ -0,0 +1,114 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errPlaylistFormat = errors.New("mikmod: unsupported playlist format")
+
+// AddPlaylistFile appends the entries of an M3U (.m3u, .m3u8) or PLS
+// (.pls) playlist file to the playlist.  Relative paths are resolved
+// against the directory of the playlist file, and entries that are not
+// local files, such as HTTP URLs, are skipped.
+func (p *Playlist) AddPlaylistFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var paths []string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m3u", ".m3u8":
+		paths, err = parseM3U(f)
+	case ".pls":
+		paths, err = parsePLS(f)
+	default:
+		return fmt.Errorf("%w: %s", errPlaylistFormat, filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(filename)
+	for _, path := range paths {
+		if path, ok := resolvePlaylistPath(path, dir); ok {
+			p.AddFile(path)
+		}
+	}
+	return nil
+}
+
+// parseM3U returns the paths listed in an M3U playlist.
+func parseM3U(r io.Reader) ([]string, error) {
+	var paths []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(s.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, s.Err()
+}
+
+// parsePLS returns the paths listed in a PLS playlist, in the order of
+// their entry numbers.
+func parsePLS(r io.Reader) ([]string, error) {
+	type entry struct {
+		n    int
+		path string
+	}
+	var entries []entry
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(s.Text(), "\ufeff"))
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || len(key) <= 4 || !strings.EqualFold(key[:4], "File") {
+			continue
+		}
+		n, err := strconv.Atoi(key[4:])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{n, strings.TrimSpace(value)})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].n < entries[j].n })
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.path
+	}
+	return paths, nil
+}
+
+// resolvePlaylistPath returns the local filename of a playlist entry,
+// resolving relative paths against dir.  It returns false if the entry
+// is not a local file.
+func resolvePlaylistPath(path string, dir string) (string, bool) {
+	if u, err := url.Parse(path); err == nil && len(u.Scheme) > 1 {
+		if u.Scheme != "file" {
+			return "", false
+		}
+		path = filepath.FromSlash(u.Path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+	}
+	return path, true
+}
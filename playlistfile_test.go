@@ -0,0 +1,82 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseM3U(t *testing.T) {
+	for _, tt := range []struct {
+		name, in string
+		want     []string
+	}{
+		{"plain", "a.mod\nb.xm\n", []string{"a.mod", "b.xm"}},
+		{"comments", "# mixtape\n\na.mod\n  # indented\nb.xm", []string{"a.mod", "b.xm"}},
+		{"extended", "#EXTM3U\n#EXTINF:123,Artist - Title\nsongs/a.mod\n#EXTINF:-1,\n../b.it\n", []string{"songs/a.mod", "../b.it"}},
+		{"crlf", "#EXTM3U\r\na.mod\r\n\r\nb.xm\r\n", []string{"a.mod", "b.xm"}},
+		{"bom", "\ufeffa.mod\n", []string{"a.mod"}},
+		{"spaces", "  a b.mod  \n", []string{"a b.mod"}},
+		{"empty", "", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseM3U(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePLS(t *testing.T) {
+	for _, tt := range []struct {
+		name, in string
+		want     []string
+	}{
+		{"plain", "[playlist]\nFile1=a.mod\nTitle1=A\nFile2=b.xm\nNumberOfEntries=2\nVersion=2\n", []string{"a.mod", "b.xm"}},
+		{"numbering", "[playlist]\nFile3=c.s3m\nFile1=a.mod\nFile10=j.it\nFile2=b.xm\n", []string{"a.mod", "b.xm", "c.s3m", "j.it"}},
+		{"gaps", "[playlist]\nFile7=b.xm\nFile2=a.mod\nNumberOfEntries=9\n", []string{"a.mod", "b.xm"}},
+		{"case", "[playlist]\nfile1=a.mod\nFILE2=b.xm\n", []string{"a.mod", "b.xm"}},
+		{"malformed", "[playlist]\nFile=x.mod\nFileX=y.mod\nFile1\nFile1=a.mod\n", []string{"a.mod"}},
+		{"crlf", "[playlist]\r\nFile1=a.mod\r\nFile2 = b.xm \r\n", []string{"a.mod", "b.xm"}},
+		{"bom", "\ufeff[playlist]\nFile1=a.mod\n", []string{"a.mod"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePLS(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePlaylistPath(t *testing.T) {
+	dir := filepath.FromSlash("/music/lists")
+	for _, tt := range []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"a.mod", "/music/lists/a.mod", true},
+		{"songs/a.mod", "/music/lists/songs/a.mod", true},
+		{"../a.mod", "/music/a.mod", true},
+		{"/tmp/a.mod", "/tmp/a.mod", true},
+		{"file:///tmp/a%20b.mod", "/tmp/a b.mod", true},
+		{"http://example.com/a.mod", "", false},
+		{"HTTPS://example.com/a.mod", "", false},
+	} {
+		got, ok := resolvePlaylistPath(tt.path, dir)
+		if want := filepath.FromSlash(tt.want); got != want || ok != tt.ok {
+			t.Errorf("resolvePlaylistPath(%q): got %q, %v, want %q, %v", tt.path, got, ok, want, tt.ok)
+		}
+	}
+}
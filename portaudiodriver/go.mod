@@ -0,0 +1,10 @@
+module github.com/death/go-mikmod/portaudiodriver
+
+go 1.24
+
+require (
+	github.com/death/go-mikmod v0.0.0
+	github.com/gordonklaus/portaudio v0.0.0-20230709114228-aafa478834f5
+)
+
+replace github.com/death/go-mikmod => ../
@@ -0,0 +1,103 @@
+// Package portaudiodriver plays MikMod modules through PortAudio.  The
+// mixer is driven from the PortAudio stream callback, so exactly as
+// much audio is mixed as the device asks for.
+//
+// PortAudio must be initialized with portaudio.Initialize before
+// players are created.  See the mikmod package documentation on using
+// other audio libraries.
+package portaudiodriver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/death/go-mikmod"
+	"github.com/gordonklaus/portaudio"
+)
+
+// Player plays a module through a PortAudio output stream on the
+// default device.  Remember to Close it when done.
+type Player struct {
+	stream *mikmod.Stream
+	pa     *portaudio.Stream
+	buf    []byte
+}
+
+// NewPlayer opens a PortAudio stream for playing a module, using
+// framesPerBuffer frames per callback, or a value chosen by PortAudio
+// if it is 0.  Any module currently playing is stopped.  Call Start to
+// begin playback.
+func NewPlayer(m *mikmod.Module, framesPerBuffer int) (*Player, error) {
+	stream, err := mikmod.NewStream(m, mikmod.StreamOptions{Loop: true})
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{stream: stream}
+	f := stream.Format()
+	var callback interface{}
+	switch f.BitsPerSample {
+	case 16:
+		callback = p.process16
+	case 32:
+		callback = p.process32
+	default:
+		stream.Close()
+		return nil, fmt.Errorf("portaudiodriver: unsupported sample size %d", f.BitsPerSample)
+	}
+	if framesPerBuffer == 0 {
+		framesPerBuffer = portaudio.FramesPerBufferUnspecified
+	}
+	p.pa, err = portaudio.OpenDefaultStream(0, f.Channels, float64(f.SampleRate), framesPerBuffer, callback)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Start starts playback.
+func (p *Player) Start() error { return p.pa.Start() }
+
+// Stop stops playback, which can later be resumed with Start.
+func (p *Player) Stop() error { return p.pa.Stop() }
+
+// Close stops playback and frees the player.
+func (p *Player) Close() error {
+	err := p.pa.Close()
+	if serr := p.stream.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// fill reads n bytes of PCM data into the player's buffer, padding
+// with silence once the song has ended.
+func (p *Player) fill(n int) []byte {
+	if cap(p.buf) < n {
+		p.buf = make([]byte, n)
+	}
+	b := p.buf[:n]
+	k, err := io.ReadFull(p.stream, b)
+	if err != nil {
+		clear(b[k:])
+	}
+	return b
+}
+
+// process16 is the stream callback for 16-bit output.
+func (p *Player) process16(out []int16) {
+	b := p.fill(len(out) * 2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(b[i*2:]))
+	}
+}
+
+// process32 is the stream callback for floating point output.
+func (p *Player) process32(out []float32) {
+	b := p.fill(len(out) * 4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+}
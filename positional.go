@@ -0,0 +1,105 @@
+//go:build cgo
+
+package mikmod
+
+import "math"
+
+// Vec3 is a position or direction in 3D space.  Games in 2D leave Z at
+// 0.
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+// Sub returns v - w.
+func (v Vec3) Sub(w Vec3) Vec3 { return Vec3{v.X - w.X, v.Y - w.Y, v.Z - w.Z} }
+
+// Dot returns the dot product of v and w.
+func (v Vec3) Dot(w Vec3) float64 { return v.X*w.X + v.Y*w.Y + v.Z*w.Z }
+
+// Len returns the length of v.
+func (v Vec3) Len() float64 { return math.Sqrt(v.Dot(v)) }
+
+// Attenuation is a curve giving the gain of a sound effect by the
+// distance of its emitter to the listener.
+type Attenuation int
+
+const (
+	// AttenuationInverse divides the gain by the distance, relative
+	// to MinDistance, as sound does in open air.
+	AttenuationInverse Attenuation = iota
+
+	// AttenuationLinear decreases the gain linearly from 1 at
+	// MinDistance to 0 at MaxDistance.
+	AttenuationLinear
+
+	// AttenuationNone keeps the gain at 1 up to MaxDistance.
+	AttenuationNone
+)
+
+// Listener is the point of view from which positional sound effects
+// are heard.
+type Listener struct {
+	Position Vec3
+
+	// Right is the direction of the listener's right ear.  If zero,
+	// it is the X axis.
+	Right Vec3
+}
+
+// Emitter places the voice playing a sound effect in space.  Call
+// Update each frame, or whenever the emitter or the listener move.
+type Emitter struct {
+	// Voice is the voice returned by PlaySample.
+	Voice Voice
+
+	Position Vec3
+
+	// Volume is the volume of the sound effect at MinDistance or
+	// closer, between 0 and 1.
+	Volume float64
+
+	// MinDistance is the distance under which the sound effect is
+	// not attenuated, and MaxDistance the distance beyond which it
+	// is silent.  If MaxDistance is 0, it is never silent.
+	MinDistance float64
+	MaxDistance float64
+
+	Attenuation Attenuation
+}
+
+// Gain returns the gain and pan, from -1 for left to 1 for right, at
+// which the emitter is heard by listener l.
+func (e *Emitter) Gain(l Listener) (gain, pan float64) {
+	rel := e.Position.Sub(l.Position)
+	d := rel.Len()
+	if e.MaxDistance > 0 && d >= e.MaxDistance {
+		return 0, 0
+	}
+	gain = e.Volume
+	if excess := d - e.MinDistance; excess > 0 {
+		switch e.Attenuation {
+		case AttenuationInverse:
+			gain *= max(e.MinDistance, 1) / (max(e.MinDistance, 1) + excess)
+		case AttenuationLinear:
+			if e.MaxDistance > 0 {
+				gain *= 1 - excess/(e.MaxDistance-e.MinDistance)
+			}
+		}
+	}
+	right := l.Right
+	if right == (Vec3{}) {
+		right = Vec3{X: 1}
+	}
+	if d > 0 {
+		pan = clamp(rel.Dot(right)/(d*right.Len()), -1, 1)
+	}
+	return clamp(gain, 0, 1), pan
+}
+
+// Update sets the volume and panning of the emitter's voice as heard
+// by listener l.
+func (e *Emitter) Update(l Listener) {
+	gain, pan := e.Gain(l)
+	e.Voice.SetVolume(gain)
+	e.Voice.SetPanning(pan)
+}
@@ -0,0 +1,99 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"fmt"
+	"time"
+)
+
+// Preset is a named bundle of Options for a common use, for those who
+// would rather not learn libmikmod's knobs.  Start from its Options
+// to adjust them, e.g. to pick a driver.
+type Preset int
+
+const (
+	// PresetLowLatencyGame favors responsiveness, for games mixing
+	// sound effects over music: a short output buffer, updates on
+	// a native thread, voices for sound effects, and no sound
+	// rather than a failure if there is no audio device.
+	PresetLowLatencyGame Preset = iota + 1
+
+	// PresetHighQualityArchive favors fidelity over CPU and
+	// latency, e.g. for listening to or archiving a collection: the
+	// high quality mixer at 48000 Hz with interpolation, and a
+	// generous output buffer.
+	PresetHighQualityArchive
+
+	// PresetEmbeddedLowCPU favors a light load, for small boards
+	// and battery-powered devices: mixing at 22050 Hz without
+	// interpolation or noise reduction, and infrequent wakeups.
+	PresetEmbeddedLowCPU
+)
+
+// presetNames are the names of the presets, as accepted by
+// ParsePreset.
+var presetNames = map[Preset]string{
+	PresetLowLatencyGame:     "low-latency-game",
+	PresetHighQualityArchive: "high-quality-archive",
+	PresetEmbeddedLowCPU:     "embedded-low-cpu",
+}
+
+// String returns the name of the preset, e.g. "low-latency-game".
+func (p Preset) String() string {
+	if name, ok := presetNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Preset(%d)", int(p))
+}
+
+// ParsePreset returns the preset named name, as returned by String,
+// e.g. to select one from a command line flag.
+func ParsePreset(name string) (Preset, error) {
+	for p, n := range presetNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("mikmod: unknown preset %q", name)
+}
+
+// Options returns the options the preset stands for.  Those it does
+// not mention, and all of them for an unknown preset, are left at
+// their defaults.
+func (p Preset) Options() Options {
+	switch p {
+	case PresetLowLatencyGame:
+		return Options{
+			FallbackToNoSound: true,
+			Interpolation:     true,
+			MixFrequency:      48000,
+			Latency:           20 * time.Millisecond,
+			NativeUpdates:     true,
+			SFXVoices:         8,
+		}
+	case PresetHighQualityArchive:
+		return Options{
+			Interpolation: true,
+			HQMixer:       true,
+			MixFrequency:  48000,
+			Latency:       250 * time.Millisecond,
+			UpdateBatch:   4,
+		}
+	case PresetEmbeddedLowCPU:
+		return Options{
+			DisableNoiseReduction: true,
+			MixFrequency:          22050,
+			Latency:               100 * time.Millisecond,
+			UpdateBatch:           5,
+			SFXVoices:             2,
+		}
+	}
+	return Options{}
+}
+
+// InitWithPreset initializes the MikMod library with the options of
+// preset p.  See InitWithOptions.
+func InitWithPreset(p Preset) error {
+	return InitWithOptions(p.Options())
+}
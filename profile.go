@@ -0,0 +1,146 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "sync"
+
+// PlaybackProfile holds playback settings suited to a module, applied
+// whenever it starts, e.g. for a curated library to store per-track
+// tweaks.  The zero value stands for libmikmod's defaults.
+type PlaybackProfile struct {
+	// PanNarrowing brings voices toward the center, from 0 for full
+	// pan separation to 1 for mono.
+	PanNarrowing float64
+
+	// Reverb is the amount of reverb, from 0 for none to 1.
+	Reverb float64
+
+	// Interpolation, Surround and DisableNoiseReduction are as in
+	// Options.
+	Interpolation         bool
+	Surround              bool
+	DisableNoiseReduction bool
+}
+
+// profileSettings are the settings of the software mixer a profile
+// controls.
+type profileSettings struct {
+	pansep, reverb C.UBYTE
+	mode           C.UWORD
+}
+
+// profileModeFlags are the mode flags a profile controls.
+const profileModeFlags = C.DMODE_INTERP | C.DMODE_SURROUND | C.DMODE_NOISEREDUCTION
+
+// profileMu guards profileSaved, the settings in effect before a
+// profile was applied, which are restored when a module without a
+// profile starts, or nil if no profile is applied.
+var (
+	profileMu    sync.Mutex
+	profileSaved *profileSettings
+)
+
+// SetProfile sets the profile applied when m starts, or removes it if
+// p is nil.  It applies to the next start of m.  The settings in
+// effect before a profile is applied are restored once a module
+// without a profile starts.
+func (m *Module) SetProfile(p *PlaybackProfile) {
+	if p != nil {
+		c := *p
+		p = &c
+	}
+	m.profile.Store(p)
+}
+
+// Profile returns the profile applied when m starts, or nil if none.
+func (m *Module) Profile() *PlaybackProfile {
+	if p := m.profile.Load(); p != nil {
+		c := *p
+		return &c
+	}
+	return nil
+}
+
+// settings returns the mixer settings of a profile.
+func (p *PlaybackProfile) settings() profileSettings {
+	s := profileSettings{
+		pansep: C.UBYTE((1-clamp(p.PanNarrowing, 0, 1))*128 + 0.5),
+		reverb: C.UBYTE(clamp(p.Reverb, 0, 1)*15 + 0.5),
+	}
+	if p.Interpolation {
+		s.mode |= C.DMODE_INTERP
+	}
+	if p.Surround {
+		s.mode |= C.DMODE_SURROUND
+	}
+	if !p.DisableNoiseReduction {
+		s.mode |= C.DMODE_NOISEREDUCTION
+	}
+	return s
+}
+
+// currentProfileSettings returns the mixer settings in effect.
+func currentProfileSettings() profileSettings {
+	return profileSettings{C.md_pansep, C.md_reverb, C.md_mode & profileModeFlags}
+}
+
+// apply makes s the mixer settings in effect.
+func (s profileSettings) apply() {
+	C.MikMod_Lock()
+	defer C.MikMod_Unlock()
+	C.md_pansep, C.md_reverb = s.pansep, s.reverb
+	C.md_mode = C.md_mode&^profileModeFlags | s.mode
+}
+
+// applyProfile applies the profile of m, a module starting, or
+// restores the settings saved when a profile was applied if m has
+// none.  It expects to be called on the actor.
+func applyProfile(m *Module) {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	p := m.profile.Load()
+	switch {
+	case p != nil:
+		if profileSaved == nil {
+			s := currentProfileSettings()
+			profileSaved = &s
+		}
+		p.settings().apply()
+	case profileSaved != nil:
+		profileSaved.apply()
+		profileSaved = nil
+	}
+}
+
+// AmigaProfile returns a profile approximating the output of an Amiga,
+// for 4-channel MODs: voices hard-panned as set by the module with no
+// narrowing, no interpolation, so that samples keep the grit of
+// Paula's nearest-neighbor playback, and the mixer's low-pass noise
+// reduction standing in for the Amiga's output filter.
+func AmigaProfile() *PlaybackProfile {
+	return &PlaybackProfile{}
+}
+
+// ModernProfile returns a profile suited to multichannel modules made
+// with PC trackers, such as XM and IT: interpolated mixing without the
+// low-pass noise reduction, which would dull their high end.
+func ModernProfile() *PlaybackProfile {
+	return &PlaybackProfile{Interpolation: true, DisableNoiseReduction: true}
+}
+
+// UseEraProfile sets the profile of m to AmigaProfile or ModernProfile,
+// whichever suits its format and number of channels, and returns it.
+func (m *Module) UseEraProfile() *PlaybackProfile {
+	p := ModernProfile()
+	switch m.Type() {
+	case ModTypeMOD, ModTypeM15, ModTypeMED, ModTypeOKT:
+		if m.NumChannels() <= 4 {
+			p = AmigaProfile()
+		}
+	}
+	m.SetProfile(p)
+	return p
+}
@@ -0,0 +1,89 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"fmt"
+	"time"
+)
+
+// Progress returns how far the playing module is through its song: the
+// time elapsed in the current pass, the duration of a pass and the
+// fraction played, between 0 and 1, e.g. for a progress bar.  Unlike
+// Elapsed, it starts over when the song loops, and follows seeks and
+// position jumps, as the time is found from the row playing in the
+// module's seek table, built on first use.  It returns zeros if no
+// module is playing.
+func Progress() (elapsed, total time.Duration, fraction float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return 0, 0, 0
+	}
+	t := m.BuildSeekTable()
+	total = t.Duration()
+	if total <= 0 {
+		return 0, 0, 0
+	}
+	var pos, row, tick, tempo int
+	do(func() {
+		mod := m.module
+		pos, row, tick, tempo = int(mod.sngpos), int(mod.patpos), int(mod.vbtick), int(mod.bpm)
+	})
+	r, ok := t.near(pos, row, m.Elapsed()%total)
+	if !ok {
+		// The player went where the song does not go, e.g. with
+		// SetPosition.
+		elapsed = min(m.Elapsed(), total)
+		return elapsed, total, float64(elapsed) / float64(total)
+	}
+	elapsed = r.at
+	if tempo > 0 {
+		elapsed += time.Duration(tick) * 5 * time.Second / time.Duration(2*tempo)
+	}
+	elapsed = min(elapsed, total)
+	return elapsed, total, float64(elapsed) / float64(total)
+}
+
+// near returns the row of the song at song position pos and row row
+// whose time is closest to hint, as the song may play a row more than
+// once, or false if the song does not play it.
+func (t *SeekTable) near(pos, row int, hint time.Duration) (seekRow, bool) {
+	var best seekRow
+	found := false
+	for _, r := range t.rows {
+		if r.pos == pos && r.row == row && (!found || absDuration(r.at-hint) < absDuration(best.at-hint)) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// absDuration returns the absolute value of d.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// FormatTime formats d as players show playing times, e.g. "3:07", or
+// "1:02:03" from an hour on, truncated to the second.
+func FormatTime(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	s := int(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%s%d:%02d:%02d", sign, s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%s%d:%02d", sign, s/60, s%60)
+}
+
+// FormatProgress formats the elapsed and total times returned by
+// Progress, e.g. "1:23 / 3:07".
+func FormatProgress(elapsed, total time.Duration) string {
+	return FormatTime(elapsed) + " / " + FormatTime(total)
+}
@@ -0,0 +1,543 @@
+package protracker
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Amiga timing.
+const (
+	// paulaClock is the PAL Amiga's Paula clock rate divided by 2,
+	// which divided by a period gives the playback rate in Hz.
+	paulaClock = 3546894.6
+
+	minPeriod = 113
+	maxPeriod = 856
+
+	defaultSpeed = 6
+	defaultTempo = 125
+)
+
+// stereoSeparation is how much of each channel leaks into the opposite
+// side, softening the Amiga's hard panning.
+const stereoSeparation = 0.25
+
+// vibratoTable is ProTracker's sine table, used by vibrato and
+// tremolo.
+var vibratoTable = [32]int{
+	0, 24, 49, 74, 97, 120, 141, 161, 180, 197, 212, 224, 235, 244, 250, 253,
+	255, 253, 250, 244, 235, 224, 212, 197, 180, 161, 141, 120, 97, 74, 49, 24,
+}
+
+// Player renders a Module as 16-bit signed little-endian PCM data.
+type Player struct {
+	module   *Module
+	rate     int
+	channels int
+	loop     bool
+
+	voices []voice
+
+	order, row, tick int
+	speed, tempo     int
+	tickFrames       int
+	framesLeft       int
+	frames           int64
+	ended            bool
+	visited          map[int]bool
+
+	// jumpTo, breakTo and loopTo are the song position, row of the
+	// next position and row of the current position to go to after
+	// the current row, or -1.
+	jumpTo, breakTo, loopTo int
+	patternDelay            int
+	delayLeft               int
+}
+
+// voice is the state of a channel.
+type voice struct {
+	sample *Sample
+	pos    float64
+	active bool
+
+	period     int
+	basePeriod int
+	volume     int
+	pan        float64
+	note       Note
+
+	portaTarget int
+	portaSpeed  int
+	vibPos      int
+	vibSpeed    int
+	vibDepth    int
+	tremPos     int
+	tremSpeed   int
+	tremDepth   int
+	tremolo     int
+	offset      int
+
+	loopRow   int
+	loopCount int
+}
+
+// NewPlayer returns a player for m producing PCM data at rate Hz with
+// 1 or 2 channels.
+func NewPlayer(m *Module, rate int, channels int) *Player {
+	p := &Player{
+		module:   m,
+		rate:     rate,
+		channels: max(1, min(channels, 2)),
+		voices:   make([]voice, m.Channels),
+	}
+	for i := range p.voices {
+		// Amiga panning: LRRL.
+		if i%4 == 0 || i%4 == 3 {
+			p.voices[i].pan = -1
+		} else {
+			p.voices[i].pan = 1
+		}
+	}
+	p.Rewind()
+	return p
+}
+
+// Rewind starts the song over.
+func (p *Player) Rewind() {
+	p.order, p.row, p.tick = 0, 0, 0
+	p.speed, p.tempo = defaultSpeed, defaultTempo
+	p.frames, p.ended = 0, false
+	p.jumpTo, p.breakTo, p.loopTo = -1, -1, -1
+	p.patternDelay, p.delayLeft = 0, 0
+	p.visited = map[int]bool{0: true}
+	for i := range p.voices {
+		pan := p.voices[i].pan
+		p.voices[i] = voice{pan: pan}
+	}
+	p.setTickFrames()
+	p.framesLeft = 0
+}
+
+// SetLoop controls whether the song starts over from its restart
+// position when it ends, or when it jumps back to a position already
+// played.
+func (p *Player) SetLoop(loop bool) { p.loop = loop }
+
+// Ended returns true once the song has ended.
+func (p *Player) Ended() bool { return p.ended }
+
+// Position returns the song position being played.
+func (p *Player) Position() int { return p.order }
+
+// Row returns the row being played.
+func (p *Player) Row() int { return p.row }
+
+// Speed returns the number of ticks per row.
+func (p *Player) Speed() int { return p.speed }
+
+// Tempo returns the tempo, in beats per minute.
+func (p *Player) Tempo() int { return p.tempo }
+
+// Elapsed returns the duration of the audio produced so far.
+func (p *Player) Elapsed() time.Duration {
+	return time.Duration(p.frames) * time.Second / time.Duration(p.rate)
+}
+
+// SetPosition jumps to song position pos.
+func (p *Player) SetPosition(pos int) {
+	if pos < 0 || pos >= len(p.module.Orders) {
+		return
+	}
+	p.order, p.row, p.tick, p.framesLeft = pos, 0, 0, 0
+	p.jumpTo, p.breakTo, p.loopTo = -1, -1, -1
+	p.ended = false
+	p.visited = map[int]bool{pos: true}
+}
+
+// Read fills b with PCM data, always a whole number of frames, and
+// returns io.EOF once the song has ended.
+func (p *Player) Read(b []byte) (int, error) {
+	frameSize := 2 * p.channels
+	n := 0
+	for n+frameSize <= len(b) {
+		if p.framesLeft == 0 {
+			if p.ended {
+				break
+			}
+			p.processTick()
+			p.framesLeft = p.tickFrames
+		}
+		frames := min(p.framesLeft, (len(b)-n)/frameSize)
+		p.mix(b[n:n+frames*frameSize], frames)
+		p.framesLeft -= frames
+		p.frames += int64(frames)
+		n += frames * frameSize
+	}
+	if n == 0 && p.ended {
+		return 0, io.EOF
+	}
+	if n == 0 {
+		return 0, io.ErrShortBuffer
+	}
+	return n, nil
+}
+
+// setTickFrames computes the number of frames per tick from the tempo.
+func (p *Player) setTickFrames() {
+	p.tickFrames = max(1, p.rate*5/(2*p.tempo))
+}
+
+// mix renders frames frames into b.
+func (p *Player) mix(b []byte, frames int) {
+	gain := 1 / math.Max(2, float64(len(p.voices))/2)
+	for f := range frames {
+		var left, right float64
+		for i := range p.voices {
+			v := &p.voices[i]
+			s := v.next(p.rate)
+			if s == 0 {
+				continue
+			}
+			s *= float64(clampInt(v.volume+v.tremolo, 0, 64)) / 64
+			l := (1 - v.pan) / 2
+			r := (1 + v.pan) / 2
+			left += s * (l*(1-stereoSeparation) + r*stereoSeparation)
+			right += s * (r*(1-stereoSeparation) + l*stereoSeparation)
+		}
+		left, right = left*gain, right*gain
+		if p.channels == 1 {
+			putSample(b[f*2:], (left+right)/2)
+		} else {
+			putSample(b[f*4:], left)
+			putSample(b[f*4+2:], right)
+		}
+	}
+}
+
+// next returns the voice's next sample, between -1 and 1, advancing
+// it at output rate rate.
+func (v *voice) next(rate int) float64 {
+	if !v.active || v.sample == nil || v.period <= 0 {
+		return 0
+	}
+	data := v.sample.Data
+	end := len(data)
+	looping := v.sample.LoopLength > 2
+	if looping {
+		end = v.sample.LoopStart + v.sample.LoopLength
+	}
+	if int(v.pos) >= end {
+		if !looping {
+			v.active = false
+			return 0
+		}
+		v.pos -= float64(v.sample.LoopLength) * math.Floor((v.pos-float64(end))/float64(v.sample.LoopLength)+1)
+	}
+	i := int(v.pos)
+	frac := v.pos - float64(i)
+	a := float64(data[i])
+	j := i + 1
+	if j >= end {
+		j = i
+		if looping {
+			j = v.sample.LoopStart
+		}
+	}
+	s := a + (float64(data[j])-a)*frac
+	v.pos += paulaClock / float64(v.period) / float64(rate)
+	return s / 128
+}
+
+// processTick advances the song by one tick.
+func (p *Player) processTick() {
+	if p.tick == 0 && p.delayLeft == 0 {
+		p.processRow()
+	} else {
+		p.processEffects()
+	}
+	p.tick++
+	if p.tick < p.speed {
+		return
+	}
+	p.tick = 0
+	if p.delayLeft > 0 {
+		p.delayLeft--
+		if p.delayLeft > 0 {
+			return
+		}
+	} else if p.patternDelay > 0 {
+		p.delayLeft = p.patternDelay
+		p.patternDelay = 0
+		return
+	}
+	p.advanceRow()
+}
+
+// advanceRow moves to the next row, honoring jumps and breaks.
+func (p *Player) advanceRow() {
+	order, row := p.order, p.row+1
+	jumped := false
+	switch {
+	case p.loopTo >= 0:
+		row = p.loopTo
+	case p.jumpTo >= 0 || p.breakTo >= 0:
+		order, row = p.order+1, max(p.breakTo, 0)
+		if p.jumpTo >= 0 {
+			order, jumped = p.jumpTo, true
+		}
+	case row >= numRows:
+		order, row = order+1, 0
+	}
+	p.jumpTo, p.breakTo, p.loopTo = -1, -1, -1
+	if order >= len(p.module.Orders) {
+		order, jumped = p.module.Restart, true
+	}
+	if jumped && p.visited[order] {
+		// Going back to a position already played would loop
+		// forever.
+		if !p.loop {
+			p.ended = true
+			return
+		}
+		clear(p.visited)
+	}
+	p.visited[order] = true
+	p.order, p.row = order, row
+}
+
+// processRow reads the current row and applies its first tick.
+func (p *Player) processRow() {
+	pat := p.module.Orders[p.order]
+	for ch := range p.voices {
+		v := &p.voices[ch]
+		n := p.module.note(pat, p.row, ch)
+		v.note = n
+		v.period = v.basePeriod
+		v.tremolo = 0
+		if n.Sample > 0 && n.Sample <= len(p.module.Samples) {
+			v.sample = &p.module.Samples[n.Sample-1]
+			v.volume = v.sample.Volume
+		}
+		delayed := n.Effect == 0xE && n.Param>>4 == 0xD && n.Param&0xF != 0
+		if n.Period > 0 {
+			period := v.tunedPeriod(n.Period)
+			if n.Effect == 0x3 || n.Effect == 0x5 {
+				v.portaTarget = period
+			} else if !delayed {
+				v.trigger(period)
+			}
+		}
+		p.firstTickEffect(v, n)
+	}
+}
+
+// tunedPeriod returns period adjusted for the finetune of the voice's
+// sample.
+func (v *voice) tunedPeriod(period int) int {
+	if v.sample == nil || v.sample.Finetune == 0 {
+		return period
+	}
+	return int(math.Round(float64(period) * math.Pow(2, -float64(v.sample.Finetune)/96)))
+}
+
+// trigger starts playing the voice's sample at period.
+func (v *voice) trigger(period int) {
+	v.period, v.basePeriod = period, period
+	v.pos = 0
+	v.active = v.sample != nil
+	v.vibPos, v.tremPos = 0, 0
+}
+
+// firstTickEffect applies the effects taking place on the first tick
+// of a row.
+func (p *Player) firstTickEffect(v *voice, n Note) {
+	x, y := n.Param>>4, n.Param&0xF
+	switch n.Effect {
+	case 0x3:
+		if n.Param != 0 {
+			v.portaSpeed = n.Param
+		}
+	case 0x4:
+		if x != 0 {
+			v.vibSpeed = x
+		}
+		if y != 0 {
+			v.vibDepth = y
+		}
+	case 0x7:
+		if x != 0 {
+			v.tremSpeed = x
+		}
+		if y != 0 {
+			v.tremDepth = y
+		}
+	case 0x8:
+		v.pan = float64(n.Param)/127.5 - 1
+	case 0x9:
+		if n.Param != 0 {
+			v.offset = n.Param * 256
+		}
+		if n.Period > 0 {
+			v.pos = float64(v.offset)
+		}
+	case 0xB:
+		p.jumpTo = n.Param
+	case 0xC:
+		v.volume = min(n.Param, 64)
+	case 0xD:
+		p.breakTo = min(x*10+y, numRows-1)
+	case 0xE:
+		switch x {
+		case 0x1:
+			v.setPeriod(v.period - y)
+			v.basePeriod = v.period
+		case 0x2:
+			v.setPeriod(v.period + y)
+			v.basePeriod = v.period
+		case 0x6:
+			if y == 0 {
+				v.loopRow = p.row
+			} else {
+				if v.loopCount == 0 {
+					v.loopCount = y
+				} else {
+					v.loopCount--
+				}
+				if v.loopCount > 0 {
+					p.loopTo = v.loopRow
+				}
+			}
+		case 0xA:
+			v.volume = min(v.volume+y, 64)
+		case 0xB:
+			v.volume = max(v.volume-y, 0)
+		case 0xC:
+			if y == 0 {
+				v.volume = 0
+			}
+		case 0xE:
+			p.patternDelay = y
+		}
+	case 0xF:
+		switch {
+		case n.Param == 0:
+		case n.Param < 0x20:
+			p.speed = n.Param
+		default:
+			p.tempo = n.Param
+			p.setTickFrames()
+		}
+	}
+}
+
+// processEffects applies the effects taking place on the other ticks
+// of a row.
+func (p *Player) processEffects() {
+	for ch := range p.voices {
+		v := &p.voices[ch]
+		n := v.note
+		x, y := n.Param>>4, n.Param&0xF
+		switch n.Effect {
+		case 0x0:
+			if n.Param != 0 {
+				offsets := [3]int{0, x, y}
+				v.period = int(math.Round(float64(v.basePeriod) * math.Pow(2, -float64(offsets[p.tick%3])/12)))
+			}
+		case 0x1:
+			v.setPeriod(v.period - n.Param)
+			v.basePeriod = v.period
+		case 0x2:
+			v.setPeriod(v.period + n.Param)
+			v.basePeriod = v.period
+		case 0x3:
+			v.portamento()
+		case 0x4:
+			v.vibrato()
+		case 0x5:
+			v.portamento()
+			v.volumeSlide(n.Param)
+		case 0x6:
+			v.vibrato()
+			v.volumeSlide(n.Param)
+		case 0x7:
+			v.tremoloStep()
+		case 0xA:
+			v.volumeSlide(n.Param)
+		case 0xE:
+			switch x {
+			case 0x9:
+				if y != 0 && p.tick%y == 0 {
+					v.pos = 0
+					v.active = v.sample != nil
+				}
+			case 0xC:
+				if p.tick == y {
+					v.volume = 0
+				}
+			case 0xD:
+				if p.tick == y && n.Period > 0 {
+					v.trigger(v.tunedPeriod(n.Period))
+				}
+			}
+		}
+	}
+}
+
+// setPeriod sets the voice's period within the Amiga's limits.
+func (v *voice) setPeriod(period int) {
+	v.period = clampInt(period, minPeriod, maxPeriod)
+}
+
+// portamento slides the period towards the portamento target.
+func (v *voice) portamento() {
+	if v.portaTarget == 0 {
+		return
+	}
+	if v.period < v.portaTarget {
+		v.period = min(v.period+v.portaSpeed, v.portaTarget)
+	} else {
+		v.period = max(v.period-v.portaSpeed, v.portaTarget)
+	}
+	v.basePeriod = v.period
+}
+
+// vibrato modulates the period.
+func (v *voice) vibrato() {
+	delta := vibratoTable[v.vibPos&31] * v.vibDepth / 128
+	if v.vibPos&32 != 0 {
+		delta = -delta
+	}
+	v.period = v.basePeriod + delta
+	v.vibPos = (v.vibPos + v.vibSpeed) & 63
+}
+
+// tremoloStep modulates the volume.
+func (v *voice) tremoloStep() {
+	delta := vibratoTable[v.tremPos&31] * v.tremDepth / 64
+	if v.tremPos&32 != 0 {
+		delta = -delta
+	}
+	v.tremolo = delta
+	v.tremPos = (v.tremPos + v.tremSpeed) & 63
+}
+
+// volumeSlide slides the volume up by the upper nibble of param, or
+// down by its lower nibble.
+func (v *voice) volumeSlide(param int) {
+	if x := param >> 4; x != 0 {
+		v.volume = min(v.volume+x, 64)
+	} else {
+		v.volume = max(v.volume-param&0xF, 0)
+	}
+}
+
+// putSample encodes v, between -1 and 1, as a 16-bit sample.
+func putSample(b []byte, v float64) {
+	s := int(math.Round(v * 32767))
+	binary.LittleEndian.PutUint16(b, uint16(int16(clampInt(s, -32768, 32767))))
+}
+
+// clampInt returns v limited to the range from lo to hi.
+func clampInt(v, lo, hi int) int { return max(lo, min(v, hi)) }
@@ -0,0 +1,213 @@
+// Package protracker is a pure Go player for ProTracker modules and
+// their multichannel variants, such as FastTracker's 6CHN and 8CHN.
+//
+// It implements a small subset of what libmikmod does, without cgo,
+// and backs the mikmod package when it is built without cgo.
+package protracker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Layout of a ProTracker module.
+const (
+	titleSize      = 20
+	numSamples     = 31
+	sampleInfoSize = 30
+	numOrders      = 128
+	signatureAt    = titleSize + numSamples*sampleInfoSize + 2 + numOrders
+	headerSize     = signatureAt + 4
+	numRows        = 64
+	noteSize       = 4
+)
+
+// ErrNotAModule is returned when loading data that is not a
+// ProTracker module.
+var ErrNotAModule = errors.New("protracker: not a ProTracker module")
+
+// ErrTruncated is returned when loading a module whose data ends
+// before its patterns do.
+var ErrTruncated = errors.New("protracker: module is truncated")
+
+// Module is a loaded ProTracker module.
+type Module struct {
+	// Title is the song name.
+	Title string
+
+	// Channels is the number of channels, usually 4.
+	Channels int
+
+	// Samples holds the module's 31 sample slots, some of which may
+	// be empty.
+	Samples []Sample
+
+	// Orders lists the pattern played at each song position, and
+	// Restart is the position to start over from when looping.
+	Orders  []int
+	Restart int
+
+	// Patterns holds the notes of each pattern, row by row and
+	// channel by channel.
+	Patterns [][]Note
+
+	// Signature is the format signature, e.g. "M.K.".
+	Signature string
+}
+
+// Sample is an instrument sample of a module.
+type Sample struct {
+	Name string
+
+	// Data holds the signed 8-bit sample data.
+	Data []int8
+
+	// Finetune is the sample's finetune, from -8 to 7 eighths of a
+	// semitone.
+	Finetune int
+
+	// Volume is the default volume, from 0 to 64.
+	Volume int
+
+	// LoopStart and LoopLength delimit the loop, in samples.  The
+	// sample does not loop if LoopLength is 2 or less.
+	LoopStart  int
+	LoopLength int
+}
+
+// Note is a cell of a pattern.
+type Note struct {
+	// Sample is the sample number, from 1 to 31, or 0 for none.
+	Sample int
+
+	// Period is the Amiga period of the note, or 0 for none.
+	Period int
+
+	// Effect and Param are the effect command and its parameter.
+	Effect int
+	Param  int
+}
+
+// Test returns true if data looks like a ProTracker module.
+func Test(data []byte) bool {
+	if len(data) < headerSize {
+		return false
+	}
+	return channelsFor(string(data[signatureAt:headerSize])) > 0
+}
+
+// channelsFor returns the number of channels denoted by a format
+// signature, or 0 if the signature is unknown.
+func channelsFor(sig string) int {
+	switch sig {
+	case "M.K.", "M!K!", "M&K!", "FLT4", "4CHN", "N.T.":
+		return 4
+	case "FLT8", "CD81", "OKTA", "OCTA":
+		return 8
+	}
+	if n, ok := strings.CutSuffix(sig, "CHN"); ok {
+		if c, err := strconv.Atoi(n); err == nil && c > 0 {
+			return c
+		}
+	}
+	if n, ok := strings.CutSuffix(sig, "CH"); ok {
+		if c, err := strconv.Atoi(n); err == nil && c > 0 && c <= 32 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Load parses a ProTracker module.
+func Load(data []byte) (*Module, error) {
+	if !Test(data) {
+		return nil, ErrNotAModule
+	}
+	m := &Module{
+		Title:     cString(data[:titleSize]),
+		Signature: string(data[signatureAt:headerSize]),
+	}
+	m.Channels = channelsFor(m.Signature)
+
+	lengths := make([]int, numSamples)
+	for i := range numSamples {
+		b := data[titleSize+i*sampleInfoSize:]
+		s := Sample{
+			Name:       cString(b[:22]),
+			Finetune:   int(int8(b[24]<<4) >> 4),
+			Volume:     min(int(b[25]), 64),
+			LoopStart:  int(binary.BigEndian.Uint16(b[26:])) * 2,
+			LoopLength: int(binary.BigEndian.Uint16(b[28:])) * 2,
+		}
+		lengths[i] = int(binary.BigEndian.Uint16(b[22:])) * 2
+		m.Samples = append(m.Samples, s)
+	}
+
+	songLength := int(data[signatureAt-numOrders-2])
+	songLength = max(1, min(songLength, numOrders))
+	m.Restart = int(data[signatureAt-numOrders-1])
+	if m.Restart >= songLength {
+		m.Restart = 0
+	}
+	numPatterns := 0
+	for i := range numOrders {
+		pat := int(data[signatureAt-numOrders+i])
+		if i < songLength {
+			m.Orders = append(m.Orders, pat)
+		}
+		numPatterns = max(numPatterns, pat+1)
+	}
+
+	patternSize := numRows * m.Channels * noteSize
+	b := data[headerSize:]
+	if len(b) < numPatterns*patternSize {
+		return nil, ErrTruncated
+	}
+	for range numPatterns {
+		notes := make([]Note, numRows*m.Channels)
+		for i := range notes {
+			n := b[i*noteSize:]
+			notes[i] = Note{
+				Sample: int(n[0]&0xF0 | n[2]>>4),
+				Period: int(n[0]&0x0F)<<8 | int(n[1]),
+				Effect: int(n[2] & 0x0F),
+				Param:  int(n[3]),
+			}
+		}
+		m.Patterns = append(m.Patterns, notes)
+		b = b[patternSize:]
+	}
+
+	// Sample data follows the patterns; tolerate modules whose last
+	// sample is cut short.
+	for i := range m.Samples {
+		n := min(lengths[i], len(b))
+		s := &m.Samples[i]
+		s.Data = make([]int8, n)
+		for j := range n {
+			s.Data[j] = int8(b[j])
+		}
+		b = b[n:]
+		if s.LoopStart+s.LoopLength > n {
+			s.LoopLength = max(0, n-s.LoopStart)
+		}
+	}
+	return m, nil
+}
+
+// note returns the note played by channel ch at row row of pattern
+// pat.
+func (m *Module) note(pat int, row int, ch int) Note {
+	return m.Patterns[pat][row*m.Channels+ch]
+}
+
+// cString returns the string held in a NUL-padded field.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimRight(string(b), " ")
+}
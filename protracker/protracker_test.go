@@ -0,0 +1,217 @@
+package protracker
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testModule describes a module with signature sig, playing
+// numPatterns empty patterns in order, with sample data in slot 1.
+type testModule struct {
+	sig         string
+	numPatterns int
+	sample      []byte
+}
+
+// bytes returns the module data.
+func (t testModule) bytes() []byte {
+	channels := channelsFor(t.sig)
+	patternSize := numRows * channels * noteSize
+	b := make([]byte, headerSize+t.numPatterns*patternSize, headerSize+t.numPatterns*patternSize+len(t.sample))
+	copy(b, "test module")
+	s := b[titleSize:]
+	copy(s, "sample")
+	binary.BigEndian.PutUint16(s[22:], uint16(len(t.sample)/2))
+	s[25] = 64
+	binary.BigEndian.PutUint16(s[28:], 1)
+	b[signatureAt-numOrders-2] = byte(t.numPatterns)
+	for i := range t.numPatterns {
+		b[signatureAt-numOrders+i] = byte(i)
+	}
+	copy(b[signatureAt:], t.sig)
+	return append(b, t.sample...)
+}
+
+// setNote sets note n on channel ch of row row of pattern pat in data
+// b of a module with channels channels.
+func setNote(b []byte, channels, pat, row, ch int, n Note) {
+	at := headerSize + ((pat*numRows+row)*channels+ch)*noteSize
+	b[at] = byte(n.Sample&0xF0 | n.Period>>8)
+	b[at+1] = byte(n.Period)
+	b[at+2] = byte(n.Sample<<4 | n.Effect)
+	b[at+3] = byte(n.Param)
+}
+
+func TestChannelsFor(t *testing.T) {
+	for _, tt := range []struct {
+		sig  string
+		want int
+	}{
+		{"M.K.", 4},
+		{"M!K!", 4},
+		{"FLT4", 4},
+		{"FLT8", 8},
+		{"OCTA", 8},
+		{"6CHN", 6},
+		{"8CHN", 8},
+		{"12CH", 12},
+		{"32CH", 32},
+		{"33CH", 0},
+		{"0CHN", 0},
+		{"XCHN", 0},
+		{"\x00\x00\x00\x00", 0},
+	} {
+		if got := channelsFor(tt.sig); got != tt.want {
+			t.Errorf("channelsFor(%q): got %d, want %d", tt.sig, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	b := testModule{sig: "6CHN", numPatterns: 2, sample: make([]byte, 100)}.bytes()
+	b[signatureAt-numOrders-1] = 1
+	b[titleSize+24] = 0xF
+	setNote(b, 6, 1, 63, 5, Note{Sample: 0x12, Period: 428, Effect: 0xC, Param: 0x20})
+	m, err := Load(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "test module" || m.Signature != "6CHN" || m.Channels != 6 || m.Restart != 1 {
+		t.Errorf("got title %q, signature %q, %d channels, restart %d", m.Title, m.Signature, m.Channels, m.Restart)
+	}
+	if !slices.Equal(m.Orders, []int{0, 1}) || len(m.Patterns) != 2 {
+		t.Errorf("got orders %v and %d patterns", m.Orders, len(m.Patterns))
+	}
+	want := Note{Sample: 0x12, Period: 428, Effect: 0xC, Param: 0x20}
+	if got := m.Patterns[1][63*6+5]; got != want {
+		t.Errorf("got note %+v, want %+v", got, want)
+	}
+	s := m.Samples[0]
+	if s.Name != "sample" || len(s.Data) != 100 || s.Finetune != -1 || s.Volume != 64 || s.LoopLength != 2 {
+		t.Errorf("got sample %q of %d bytes, finetune %d, volume %d, loop length %d", s.Name, len(s.Data), s.Finetune, s.Volume, s.LoopLength)
+	}
+	if !Test(b) {
+		t.Error("Test returned false")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	b := testModule{sig: "M.K.", numPatterns: 1}.bytes()
+	unknown := slices.Clone(b)
+	copy(unknown[signatureAt:], "ABCD")
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrNotAModule},
+		{"header", b[:headerSize-1], ErrNotAModule},
+		{"signature", unknown, ErrNotAModule},
+		{"patterns", b[:len(b)-1], ErrTruncated},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayerDuration(t *testing.T) {
+	const rate = 8000
+	for _, tt := range []struct {
+		name     string
+		patterns int
+		notes    map[int]Note
+		want     int
+	}{
+		// Each tick lasts rate*5/(2*tempo) frames.
+		{"default", 1, nil, 64 * 6 * 160},
+		{"speed", 1, map[int]Note{0: {Effect: 0xF, Param: 3}}, 64 * 3 * 160},
+		{"tempo", 1, map[int]Note{0: {Effect: 0xF, Param: 150}}, 64 * 6 * 133},
+		{"jump", 1, map[int]Note{1: {Effect: 0xB, Param: 0}}, 2 * 6 * 160},
+		{"break", 2, map[int]Note{0: {Effect: 0xD, Param: 0x60}}, 6*160 + 4*6*160},
+		{"delay", 1, map[int]Note{0: {Effect: 0xE, Param: 0xE2}}, 66 * 6 * 160},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := testModule{sig: "M.K.", numPatterns: tt.patterns}.bytes()
+			for row, n := range tt.notes {
+				setNote(b, 4, 0, row, 0, n)
+			}
+			m, err := Load(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := NewPlayer(m, rate, 1)
+			out, err := io.ReadAll(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(out) / 2; got != tt.want {
+				t.Errorf("got %d frames, want %d", got, tt.want)
+			}
+			if !p.Ended() {
+				t.Error("the song has not ended")
+			}
+			if got, want := p.Elapsed(), time.Duration(tt.want)*time.Second/rate; got != want {
+				t.Errorf("got elapsed %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPlayerSample(t *testing.T) {
+	sample := make([]byte, 64)
+	for i := range sample {
+		sample[i] = byte(int8(i%16*8 - 64))
+	}
+	b := testModule{sig: "M.K.", numPatterns: 1, sample: sample}.bytes()
+	setNote(b, 4, 0, 0, 0, Note{Sample: 1, Period: 428})
+	m, err := Load(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlayer(m, 44100, 2)
+	buf := make([]byte, 4096)
+	n, err := p.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n%4 != 0 {
+		t.Errorf("read %d bytes, not a whole number of frames", n)
+	}
+	var left, right int
+	for i := 0; i < n; i += 4 {
+		left = max(left, abs(int(int16(binary.LittleEndian.Uint16(buf[i:])))))
+		right = max(right, abs(int(int16(binary.LittleEndian.Uint16(buf[i+2:])))))
+	}
+	// The first channel is panned left, with some stereo separation.
+	if left == 0 || right == 0 || right >= left {
+		t.Errorf("got peaks %d on the left and %d on the right", left, right)
+	}
+}
+
+func TestPlayerLoop(t *testing.T) {
+	b := testModule{sig: "M.K.", numPatterns: 1}.bytes()
+	m, err := Load(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlayer(m, 8000, 1)
+	p.SetLoop(true)
+	buf := make([]byte, 2*64*6*160)
+	for range 3 {
+		if _, err := io.ReadFull(p, buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.Ended() || p.Position() != 0 || p.Row() != 0 {
+		t.Errorf("got ended %v at position %d row %d", p.Ended(), p.Position(), p.Row())
+	}
+}
+
+func abs(v int) int { return max(v, -v) }
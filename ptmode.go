@@ -0,0 +1,116 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+)
+
+// ProTrackerMode selects how closely a ProTracker module plays like it
+// did in ProTracker on an Amiga, as set by SetProTrackerMode.
+type ProTrackerMode int
+
+const (
+	// ProTrackerDefault plays the module with libmikmod's defaults,
+	// which honor the panning effects and extensions of PC trackers.
+	ProTrackerDefault ProTrackerMode = iota
+
+	// ProTrackerCIA plays the module as ProTracker 2 and 3 do, with
+	// CIA timing: Fxx sets the tempo from 0x20 on, limited to 32 to
+	// 255 BPM, panning effects 8xx and E8x are ignored, and channels
+	// are hard-panned left, right, right and left, as wired to the
+	// Amiga's outputs.
+	ProTrackerCIA
+
+	// ProTrackerVBlank is like ProTrackerCIA, but with the vertical
+	// blank timing of Soundtracker and early ProTracker versions: Fxx
+	// always sets the speed, and the tempo stays at 125 BPM.
+	ProTrackerVBlank
+)
+
+// protrackerSettings are the settings of a module a ProTracker mode
+// controls.
+type protrackerSettings struct {
+	extspd, panflag C.BOOL
+	bpmlimit        C.UWORD
+	flags           C.UWORD
+	panning         [C.UF_MAXCHAN]C.UWORD
+}
+
+// protrackerMu guards the ProTracker modes of the modules, and the
+// settings they had before one was set.
+var protrackerMu sync.Mutex
+
+var errNotProTracker = errors.New("mikmod: not a ProTracker module")
+
+// SetProTrackerMode makes m, a ProTracker module, play as in mode, e.g.
+// so that purists hear 4-channel MODs as the Amiga played them.  It
+// takes effect on the next tick, except for channel panning, which
+// applies the next time m starts.  Period limits and other effect
+// quirks are built into libmikmod's player and cannot be changed.
+// Setting ProTrackerDefault restores the module's own settings.
+func (m *Module) SetProTrackerMode(mode ProTrackerMode) error {
+	if err := m.usable(); err != nil {
+		return err
+	}
+	if t := m.Type(); mode != ProTrackerDefault && t != ModTypeMOD && t != ModTypeM15 {
+		return errNotProTracker
+	}
+	protrackerMu.Lock()
+	defer protrackerMu.Unlock()
+	if m.ptMode == ProTrackerDefault {
+		do(func() { m.ptSaved = m.protrackerSettings() })
+	}
+	m.ptMode = mode
+	do(func() { m.applyProTrackerMode() })
+	return nil
+}
+
+// ProTrackerMode returns the mode set by SetProTrackerMode.
+func (m *Module) ProTrackerMode() ProTrackerMode {
+	protrackerMu.Lock()
+	defer protrackerMu.Unlock()
+	return m.ptMode
+}
+
+// protrackerSettings returns the current settings of m a ProTracker
+// mode controls.  It expects to be called on the actor.
+func (m *Module) protrackerSettings() protrackerSettings {
+	mod := m.module
+	return protrackerSettings{
+		extspd:   mod.extspd,
+		panflag:  mod.panflag,
+		bpmlimit: mod.bpmlimit,
+		flags:    mod.flags & C.UF_HIGHBPM,
+		panning:  mod.panning,
+	}
+}
+
+// applyProTrackerMode applies the ProTracker mode of m, or restores its
+// saved settings if it has none.  It expects protrackerMu to be locked
+// and to be called on the actor.
+func (m *Module) applyProTrackerMode() {
+	s := m.ptSaved
+	if m.ptMode != ProTrackerDefault {
+		s.extspd = mikmodBool(m.ptMode == ProTrackerCIA)
+		s.panflag = 0
+		s.bpmlimit = 32
+		s.flags = 0
+		for ch := range s.panning {
+			s.panning[ch] = C.PAN_LEFT
+			if ch%4 == 1 || ch%4 == 2 {
+				s.panning[ch] = C.PAN_RIGHT
+			}
+		}
+	}
+	C.MikMod_Lock()
+	defer C.MikMod_Unlock()
+	mod := m.module
+	mod.extspd, mod.panflag, mod.bpmlimit = s.extspd, s.panflag, s.bpmlimit
+	mod.flags = mod.flags&^C.UF_HIGHBPM | s.flags
+	mod.panning = s.panning
+}
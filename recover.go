@@ -0,0 +1,184 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// RecoveryPolicy controls the automatic recovery enabled by the
+// Recover option.  The zero value recovers from every critical error,
+// immediately and without limit.
+type RecoveryPolicy struct {
+	// Threshold is the number of errors within Window that trigger
+	// recovery even if none of them is critical, e.g. repeated
+	// write errors after an underrun.  If 0, only critical errors
+	// trigger recovery.
+	Threshold int
+
+	// Window is the period over which errors are counted.  If 0, it
+	// defaults to one second.
+	Window time.Duration
+
+	// MaxAttempts is the number of consecutive recovery attempts
+	// after which the package gives up until the next call to Play
+	// or Swap.  If 0, there is no limit.
+	MaxAttempts int
+
+	// Backoff is the delay before the first attempt, doubled for
+	// each following attempt.
+	Backoff time.Duration
+
+	// GrowBuffer doubles the driver's buffer on each attempt, up to
+	// the largest size the "buffer" driver argument allows.
+	GrowBuffer bool
+}
+
+// maxBackoffShift bounds the doubling of the backoff delay.
+const maxBackoffShift = 10
+
+// recoveryAttempts is the number of consecutive recovery attempts, and
+// bufferGrowth the number of times the driver's buffer was doubled
+// since initialization.  They are guarded by mu.
+var (
+	recoveryAttempts int
+	bufferGrowth     int
+)
+
+// recovering is set while recovery is in progress.
+var recovering atomic.Bool
+
+// failures counts recent errors for RecoveryPolicy.Threshold.
+var failures errorCounter
+
+// errorCounter counts errors over a sliding window.  It has its own
+// lock since errors are reported by the update loop, which must not
+// lock mu.
+type errorCounter struct {
+	sync.Mutex
+	times     []time.Time
+	threshold int
+	window    time.Duration
+}
+
+// configure resets the error count according to policy.
+func (f *errorCounter) configure(policy RecoveryPolicy) {
+	f.Lock()
+	defer f.Unlock()
+	f.times = nil
+	f.threshold = policy.Threshold
+	f.window = policy.Window
+	if f.window == 0 {
+		f.window = time.Second
+	}
+}
+
+// add records an error and returns true if the threshold is reached.
+func (f *errorCounter) add() bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.threshold == 0 {
+		return false
+	}
+	now := time.Now()
+	times := f.times[:0]
+	for _, t := range f.times {
+		if now.Sub(t) < f.window {
+			times = append(times, t)
+		}
+	}
+	f.times = append(times, now)
+	if len(f.times) < f.threshold {
+		return false
+	}
+	f.times = nil
+	return true
+}
+
+// maybeRecover starts recovering playback if err is critical, or if
+// errors are frequent enough according to the recovery policy.
+// session is the session err was reported in.
+func maybeRecover(err error, session Session) {
+	var e Error
+	if !errors.As(err, &e) {
+		return
+	}
+	if !failures.add() && !e.Critical {
+		return
+	}
+	if recovering.CompareAndSwap(false, true) {
+		go recoverPlayback(false, session)
+	}
+}
+
+// recoverPlayback resets the output driver and resumes the module
+// that was playing, at the position where it stopped, if MikMod was
+// initialized with the Recover option, or with the WatchdogRestart
+// option after a stall, and the recovery policy allows another
+// attempt.  session is the session that failed; if another has started
+// since, it is not resumed.
+func recoverPlayback(stall bool, session Session) {
+	defer recovering.Store(false)
+
+	mu.Lock()
+	policy, attempt := options.RecoveryPolicy, recoveryAttempts
+	enabled := options.Recover || stall && options.WatchdogRestart
+	ok := enabled && playingModule() != nil &&
+		(policy.MaxAttempts == 0 || attempt < policy.MaxAttempts)
+	if ok {
+		recoveryAttempts++
+	}
+	mu.Unlock()
+	if !ok {
+		return
+	}
+	if policy.Backoff > 0 {
+		time.Sleep(policy.Backoff << min(attempt, maxBackoffShift))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Another module may have started since the failure, in which
+	// case the one that failed is not resumed.
+	m := playingModule()
+	if m == nil || CurrentSession() != session {
+		return
+	}
+	pos := Position()
+	stop()
+	if policy.GrowBuffer {
+		bufferGrowth++
+	}
+	if err := reset(); err != nil {
+		reportSessionError(session, err)
+		return
+	}
+	play(m)
+	SetPosition(pos)
+}
+
+// reset resets the output driver using the current options, applying
+// any changes to MikMod's settings.  It expects mu to be locked.
+func reset() error {
+	unapplyPitch()
+	defer applyPitch()
+	cmdline := mikmodString(options.commandLine())
+	defer C.free(unsafe.Pointer(cmdline))
+	var err error
+	do(func() {
+		if C.MikMod_Reset(cmdline) != 0 {
+			_, alias := driverStrings(C.md_driver)
+			err = fmt.Errorf("mikmod: resetting driver %s: %w", alias, mikmodError())
+		}
+	})
+	return err
+}
@@ -0,0 +1,389 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+	"unsafe"
+)
+
+// renderBufferSize is the number of bytes mixed per iteration when
+// rendering.
+const renderBufferSize = 32768
+
+// RenderOptions controls offline rendering.
+type RenderOptions struct {
+	// Loops is the number of times the song is played.  If 0 or 1,
+	// it is played once.  A pass ends when the song goes back to an
+	// earlier row other than by a pattern loop.  If a wrap goes
+	// unnoticed, rendering still stops, one pass's duration after
+	// the passes requested, timed by walking through the song.
+	Loops int
+
+	// Fade, if not 0, makes rendering continue past the end of the
+	// last pass, into the song's repeat, fading out over that
+	// duration.
+	Fade time.Duration
+
+	// StartPosition is the song position rendering starts from, and
+	// EndPosition, if not 0, the position at which it stops, which
+	// is not rendered, e.g. to export a section as a stinger.
+	// Rendering also stops if the song jumps back before
+	// StartPosition.  The boundaries are accurate to the sample.
+	StartPosition int
+	EndPosition   int
+
+	// Start is the amount of audio skipped before rendering, after
+	// StartPosition, and Duration, if not 0, the longest duration
+	// rendered.
+	Start    time.Duration
+	Duration time.Duration
+
+	// MaxSpeed, if not 0, limits rendering to that many times real
+	// time, so that background conversions leave CPU time to live
+	// playback and other services.
+	MaxSpeed float64
+}
+
+// isRange returns true if opts render a section of the song rather
+// than passes of the whole song.
+func (opts RenderOptions) isRange() bool {
+	return opts.StartPosition > 0 || opts.EndPosition > 0 || opts.Start > 0 || opts.Duration > 0
+}
+
+// loopCheckSize is the number of bytes mixed between checks for the
+// song wrapping around when rendering several passes.
+const loopCheckSize = 4096
+
+// render plays the module from the start to its end as fast as
+// possible, calling fn with each mixed buffer.  Any module currently
+// playing is stopped.  Looping is disabled for the duration of the
+// render, except for the passes requested by opts, so that it is
+// guaranteed to end.  If opts select a section of the song, passes
+// and fades are ignored.
+func render(m *Module, opts RenderOptions, fn func(b []byte) error) error {
+	if err := checkInitialized(); err != nil {
+		return err
+	}
+	if err := m.usable(); err != nil {
+		return err
+	}
+	if opts.MaxSpeed > 0 {
+		fn = throttled(fn, currentFormat(), opts.MaxSpeed)
+	}
+	if opts.isRange() {
+		return renderRange(m, opts, fn)
+	}
+	defer startPull(m, false)()
+
+	format := currentFormat()
+	frameSize := format.FrameSize()
+	passes := max(opts.Loops, 1)
+	if passes == 1 && opts.Fade == 0 {
+		buf := make([]byte, renderBufferSize)
+		for IsPlaying() {
+			if err := fn(buf[:mix(buf)]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	m.module.wrap = 1
+	buf := make([]byte, loopCheckSize-loopCheckSize%frameSize)
+	fadeFrames := int(opts.Fade.Seconds() * float64(format.SampleRate))
+	limit := renderLimit(m, passes, opts.Fade, format.SampleRate)
+	fading, faded, frames := false, 0, 0
+	lastPos, lastRow := int(m.module.sngpos), int(m.module.patpos)
+	for IsPlaying() && frames < limit {
+		b := buf[:min(len(buf), (limit-frames)*frameSize)]
+		frames += len(b) / frameSize
+		end, fadeFrom, ended := len(b)/frameSize, 0, false
+		for _, r := range mixRows(m, b, frameSize) {
+			// Songs with a single position, or restarting at
+			// their last one, wrap within a position.
+			wrapped := r.pos < lastPos ||
+				r.pos == lastPos && r.row < lastRow && !m.loopsBack(lastPos, lastRow)
+			lastPos, lastRow = r.pos, r.row
+			if !wrapped || fading {
+				continue
+			}
+			if passes--; passes > 0 {
+				continue
+			}
+			if fadeFrames == 0 {
+				end, ended = r.frame, true
+				break
+			}
+			fading, fadeFrom = true, r.frame
+		}
+		b = b[:end*frameSize]
+		if fading {
+			faded = fadeOut(format, b[fadeFrom*frameSize:], faded, fadeFrames)
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+		if ended || fading && faded >= fadeFrames {
+			return nil
+		}
+	}
+	return nil
+}
+
+// renderLimit returns the number of frames at rate Hz after which a
+// render of passes passes of the song of m, faded out over fade, is
+// cut short, in case the song wraps in ways render cannot detect.  It
+// allows for the passes through the song as walked, and a spare one
+// for effects the walk does not follow.
+func renderLimit(m *Module, passes int, fade time.Duration, rate int) int {
+	t := m.BuildSeekTable()
+	song := t.Duration()
+	if song <= 0 {
+		return int(maxLoopRenderDuration.Seconds()) * rate
+	}
+	loop := song
+	if at, ok := t.Time(int(m.module.reppos), 0); ok {
+		loop -= at
+	}
+	d := song + time.Duration(passes-1)*loop + fade + song
+	return int(d.Seconds() * float64(rate))
+}
+
+// throttled returns a function calling fn, but sleeping as needed to
+// keep the PCM data in format f passed to it to at most speed times
+// real time.
+func throttled(fn func(b []byte) error, f Format, speed float64) func(b []byte) error {
+	start := time.Now()
+	rate := float64(f.SampleRate*f.FrameSize()) * speed
+	var size int64
+	return func(b []byte) error {
+		if err := fn(b); err != nil {
+			return err
+		}
+		size += int64(len(b))
+		due := time.Duration(float64(size) / rate * float64(time.Second))
+		if ahead := due - time.Since(start); ahead > 0 {
+			time.Sleep(ahead)
+		}
+		return nil
+	}
+}
+
+// renderRange is like render, but renders the section of the song
+// selected by opts.
+func renderRange(m *Module, opts RenderOptions, fn func(b []byte) error) error {
+	defer startPull(m, false)()
+	if opts.StartPosition > 0 {
+		do(func() { C.Player_SetPosition(C.UWORD(opts.StartPosition)) })
+	}
+
+	format := currentFormat()
+	frameSize := format.FrameSize()
+	skip := int(opts.Start.Seconds() * float64(format.SampleRate))
+	limit := -1
+	if opts.Duration > 0 {
+		limit = int(opts.Duration.Seconds() * float64(format.SampleRate))
+	}
+	buf := make([]byte, renderBufferSize-renderBufferSize%frameSize)
+	for IsPlaying() {
+		frames, ended := len(buf)/frameSize, false
+		if opts.EndPosition > 0 || opts.StartPosition > 0 {
+			for _, r := range mixRows(m, buf, frameSize) {
+				if opts.EndPosition > 0 && r.pos >= opts.EndPosition || r.pos < opts.StartPosition {
+					frames, ended = r.frame, true
+					break
+				}
+			}
+		} else {
+			frames = mix(buf) / frameSize
+		}
+		b := buf[:frames*frameSize]
+		if skip > 0 {
+			n := min(skip, frames)
+			b, skip = b[n*frameSize:], skip-n
+		}
+		if limit >= 0 && len(b)/frameSize >= limit {
+			b, ended = b[:limit*frameSize], true
+		}
+		limit -= len(b) / frameSize
+		if len(b) > 0 {
+			if err := fn(b); err != nil {
+				return err
+			}
+		}
+		if ended {
+			return nil
+		}
+	}
+	return nil
+}
+
+// fadeOut applies a linear fade out of total frames to PCM data b in
+// format f, of which done frames were already faded, and returns the
+// number of frames faded after b.
+func fadeOut(f Format, b []byte, done int, total int) int {
+	frames := len(b) / f.FrameSize()
+	for i := 0; i < frames; i++ {
+		gain := max(0, 1-float64(done+i)/float64(total))
+		for c := 0; c < f.Channels; c++ {
+			j := i*f.Channels + c
+			f.setSampleAt(b, j, f.sampleAt(b, j)*gain)
+		}
+	}
+	return done + frames
+}
+
+// startPull starts playing a module without the update loop, so that
+// PCM data can be pulled from the mixer using mix.  Any module
+// currently playing is stopped.  Unless loop is true, looping is
+// disabled.  It returns a function that stops the player and restores
+// the module's settings.
+func startPull(m *Module, loop bool) func() {
+	Stop()
+
+	oldLoop, oldWrap := m.module.loop, m.module.wrap
+	if !loop {
+		m.module.loop, m.module.wrap = 0, 0
+	}
+	do(func() {
+		startModule(m)
+	})
+
+	return func() {
+		do(func() { C.Player_Stop() })
+		m.module.loop, m.module.wrap = oldLoop, oldWrap
+	}
+}
+
+// mix fills b with PCM data from MikMod's software mixer, advancing
+// the player accordingly, and returns the number of bytes written.
+func mix(b []byte) int {
+	return call(func() int {
+		C.MikMod_Lock()
+		n := writeBytes(b)
+		C.MikMod_Unlock()
+		applyJumpPolicy()
+		return n
+	})
+}
+
+// outputLimiter is the limiter's state for the output of MikMod's
+// software mixer.  It is only used with MikMod locked.
+var outputLimiter limiterState
+
+// writeBytes is like mix, but expects MikMod to be locked already, as
+// it is when driver callbacks are called.
+func writeBytes(b []byte) int {
+	if len(b) == 0 {
+		return 0
+	}
+	n := int(C.VC_WriteBytes((*C.SBYTE)(unsafe.Pointer(&b[0])), C.ULONG(len(b))))
+	mixCrossfade(b[:n], currentFormat())
+	outputLimiter.apply(b[:n], currentFormat())
+	metrics.bytesMixed.Add(uint64(n))
+	return n
+}
+
+// Render renders the module, from start to end, as fast as possible
+// rather than in real time.  It returns the PCM data along with its
+// format, which is the one MikMod was initialized with.  Any module
+// currently playing is stopped.
+func Render(m *Module) ([]byte, Format, error) {
+	var b bytes.Buffer
+	format, err := RenderTo(m, &b)
+	if err != nil {
+		return nil, format, err
+	}
+	return b.Bytes(), format, nil
+}
+
+// RenderTo is like Render, but writes the PCM data to w as it is
+// mixed.
+func RenderTo(m *Module, w io.Writer) (Format, error) {
+	return RenderToWithOptions(m, w, RenderOptions{})
+}
+
+// RenderToWithOptions is like RenderTo, but renders as specified by
+// opts.
+func RenderToWithOptions(m *Module, w io.Writer, opts RenderOptions) (Format, error) {
+	format := currentFormat()
+	err := render(m, opts, func(b []byte) error {
+		_, err := w.Write(b)
+		return err
+	})
+	return format, err
+}
+
+// RenderToWAV renders the module, from start to end, into a WAV file
+// designated by filename.  Rendering happens as fast as possible
+// rather than in real time, using the format MikMod was initialized
+// with.  The module's title, tracker and comment are recorded in a
+// LIST/INFO chunk.  Any module currently playing is stopped.
+//
+// To write a WAV file in real time instead, initialize MikMod with
+// DriverWAV and DriverArgs set to "file=" followed by the filename.
+func RenderToWAV(m *Module, filename string) error {
+	return RenderToWAVWithOptions(m, filename, RenderOptions{})
+}
+
+// RenderToWAVWithOptions is like RenderToWAV, but renders as
+// specified by opts.
+func RenderToWAVWithOptions(m *Module, filename string, opts RenderOptions) error {
+	if err := checkInitialized(); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := renderWAV(m, f, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// renderWAV renders the module as a WAV file into w, along with a
+// LIST/INFO chunk holding its title, tracker and comment.
+func renderWAV(m *Module, w io.WriteSeeker, opts RenderOptions) error {
+	format := currentFormat()
+	if err := writeWAVHeader(w, format, 0); err != nil {
+		return err
+	}
+	var size uint32
+	err := render(m, opts, func(b []byte) error {
+		size += uint32(len(b))
+		_, err := w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return finishWAV(w, format, size, wavInfoChunk(wavTags(m)))
+}
+
+// MeasureDuration renders the module offline, without looping, and
+// returns the duration of the song, which NowPlaying reports from then
+// on.  Any module currently playing is stopped.
+func MeasureDuration(m *Module) (time.Duration, error) {
+	format := currentFormat()
+	var size int64
+	err := render(m, RenderOptions{}, func(b []byte) error {
+		size += int64(len(b))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	frames := size / int64(format.FrameSize())
+	d := time.Duration(frames) * time.Second / time.Duration(format.SampleRate)
+	m.duration.Store(int64(d))
+	return d, nil
+}
@@ -0,0 +1,42 @@
+//go:build cgo
+
+package mikmod_test
+
+import (
+	"testing"
+
+	"github.com/death/go-mikmod"
+)
+
+// TestRenderLoopsSinglePosition renders two passes of the fuzzing seed
+// module, whose song has a single position, so that it wraps without
+// going back to an earlier position.
+func TestRenderLoopsSinglePosition(t *testing.T) {
+	if err := mikmod.InitNoSound(); err != nil {
+		t.Fatal(err)
+	}
+	defer mikmod.Uninit()
+	m, err := mikmod.LoadModuleFromSlice(mikmod.FuzzSeeds()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	var n countingWriter
+	f, err := mikmod.RenderToWithOptions(m, &n, mikmod.RenderOptions{Loops: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 64 rows at speed 6 and tempo 125 last 7.68 seconds.
+	got := float64(n) / float64(f.FrameSize()*f.SampleRate)
+	if got < 2*7.68-0.1 || got > 2*7.68+0.1 {
+		t.Errorf("rendered %.2f s, want %.2f s", got, 2*7.68)
+	}
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	*w += countingWriter(len(b))
+	return len(b), nil
+}
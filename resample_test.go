@@ -0,0 +1,50 @@
+package mikmod
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// sineSource returns a function reading an endless 440 Hz tone in
+// 16-bit stereo format f.
+func sineSource(f Format) func(b []byte) (int, error) {
+	period := make([]byte, f.SampleRate*f.FrameSize())
+	for i := range f.SampleRate {
+		v := int16(16384 * math.Sin(2*math.Pi*440*float64(i)/float64(f.SampleRate)))
+		for c := range f.Channels {
+			binary.LittleEndian.PutUint16(period[(i*f.Channels+c)*2:], uint16(v))
+		}
+	}
+	pos := 0
+	return func(b []byte) (int, error) {
+		n := copy(b, period[pos:])
+		pos = (pos + n) % len(period)
+		return n, nil
+	}
+}
+
+// benchmarkResample measures converting a second of 44100 Hz stereo
+// to 48000 Hz with quality q.
+func benchmarkResample(b *testing.B, q ResampleQuality) {
+	f := Format{SampleRate: 44100, Channels: 2, BitsPerSample: 16}
+	r, err := newResampler(sineSource(f), f, 48000, q)
+	if err != nil {
+		b.Fatal(err)
+	}
+	out := make([]byte, 48000*f.FrameSize())
+	b.SetBytes(int64(f.SampleRate * f.FrameSize()))
+	b.ReportAllocs()
+	for b.Loop() {
+		for n := 0; n < len(out); {
+			k, err := r.Read(out[n:])
+			if err != nil {
+				b.Fatal(err)
+			}
+			n += k
+		}
+	}
+}
+
+func BenchmarkResampleLinear(b *testing.B) { benchmarkResample(b, ResampleLinear) }
+func BenchmarkResampleSinc(b *testing.B)   { benchmarkResample(b, ResampleSinc) }
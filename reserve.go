@@ -0,0 +1,79 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// They are defined in channeloverrides.go.
+extern void setChannelReservation(int ch, int r);
+extern int channelReservationOf(int ch);
+*/
+import "C"
+
+// Reservation is how much a channel is protected from being silenced
+// at runtime, as set by ReserveChannel, e.g. so that the melody of
+// dynamic music always survives while its percussion is muted and
+// ducked.
+type Reservation int
+
+const (
+	// ChannelNormal is a channel without reservation.
+	ChannelNormal Reservation = iota
+
+	// ChannelReserved is a channel never muted or stolen: muting the
+	// channel or its instruments, stopping its voice, ducking and
+	// layers have no effect on it.
+	ChannelReserved
+
+	// ChannelDuckable is a channel ducked while important sound
+	// effects play.  Once a channel is duck-able, ducking only applies
+	// to the duck-able channels.
+	ChannelDuckable
+)
+
+// ReserveChannel sets the reservation of channel ch.  As with
+// SetChannelVolume, the setting is not part of the module: it applies
+// to channel ch of whichever module plays, until it is set back to
+// ChannelNormal.  Reserving a muted channel unmutes it.
+//
+// While any channel is reserved or duck-able, ducking scales the
+// volume of the channels it applies to rather than the music volume.
+// libmikmod itself may still cut a reserved channel's note off to play
+// a new note with a new note action once all the module's voices are
+// busy, as its voice allocation cannot be steered.
+func ReserveChannel(ch int, r Reservation) {
+	if ch < 0 || ch >= C.UF_MAXCHAN || r < ChannelNormal || r > ChannelDuckable {
+		return
+	}
+	channelsOverridden.Store(true)
+	do(func() { C.setChannelReservation(C.int(ch), C.int(r)) })
+	if r == ChannelReserved {
+		UnmuteChannel(ch)
+	}
+	applyGain()
+}
+
+// ChannelReservation returns the reservation of channel ch set by
+// ReserveChannel.
+func ChannelReservation(ch int) Reservation {
+	if ch < 0 || ch >= C.UF_MAXCHAN {
+		return ChannelNormal
+	}
+	return call(func() Reservation { return Reservation(C.channelReservationOf(C.int(ch))) })
+}
+
+// channelReserved returns true if channel ch is reserved.  It expects
+// to be called on the actor.
+func channelReserved(ch int) bool {
+	return ch >= 0 && ch < C.UF_MAXCHAN && C.channelReservationOf(C.int(ch)) == C.int(ChannelReserved)
+}
+
+// voiceReserved returns true if voice v plays a reserved channel of the
+// playing module.  It expects to be called on the actor.
+func voiceReserved(v int) bool {
+	for ch := range C.UF_MAXCHAN {
+		if channelReserved(ch) && int(C.Player_GetChannelVoice(C.UBYTE(ch))) == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// stopAllVoices stops the voices of the module and of the sound
+// effects.
+static void stopAllVoices(void) {
+	int v;
+	for (v = 0; v < md_numchn; v++)
+		Voice_Stop(v);
+}
+*/
+import "C"
+
+import "sync/atomic"
+
+// rewindNext is set by ResetPlayerState so that the next module started
+// plays from its beginning.
+var rewindNext atomic.Bool
+
+// ResetPlayerState stops playback and clears the state lingering from
+// earlier playback or renders, so that rendering the same module twice
+// yields identical output: sound effects are stopped, fades and
+// ducking are cancelled, the software mixer is restarted, and the next
+// module played or rendered starts from its beginning with its initial
+// speed, tempo, global volume and channel state.  Settings such as
+// SetVolume, SetPitch and channel overrides are left alone.
+func ResetPlayerState() error {
+	if err := checkInitialized(); err != nil {
+		return err
+	}
+	Stop()
+	cancelFade()
+	resetDucking()
+	setMusicLevel(1)
+	do(func() {
+		sfxMu.Lock()
+		defer sfxMu.Unlock()
+		C.stopAllVoices()
+		endAllSFX()
+		// Restarting the output resets the mixer's state, such as
+		// the part of a tick left to mix and the reverb.
+		C.MikMod_DisableOutput()
+	})
+	rewindNext.Store(true)
+	return nil
+}
+
+// startModule makes m the module played by libmikmod, starting it over
+// if it played to its end before or ResetPlayerState was called, with
+// its profile applied and its restart position as loaded, undoing
+// PlaySubsong.  It expects to be called on the actor.
+func startModule(m *Module) {
+	applyProfile(m)
+	C.MikMod_Lock()
+	m.module.reppos = m.reppos
+	C.MikMod_Unlock()
+	C.Player_Start(m.module)
+	watchJumps(m)
+	watchClock(m)
+	if rewindNext.Swap(false) || m.module.sngpos >= C.SWORD(m.module.numpos) {
+		// Besides jumping, setting the position to 0 resets the
+		// player's state for the module.
+		C.Player_SetPosition(0)
+	}
+}
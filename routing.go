@@ -0,0 +1,144 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Routing sends channels of a module to output channels of a
+// multichannel device, e.g. to spread a module's parts across the
+// speakers of an exhibit.
+type Routing struct {
+	// Outputs is the number of output channels, e.g. 4 for a 4.0
+	// device or 6 for 5.1, in the order the device expects them.
+	Outputs int
+
+	// Channels maps module channels to the output channel each
+	// plays on, from 0.  Module channels not listed are silent.
+	Channels map[int]int
+}
+
+var errRoutingFormat = errors.New("mikmod: routing needs 16-bit output")
+
+// NewRoutedStream is like NewStream, but its PCM data has r.Outputs
+// channels, each carrying the module channels routed to it, mixed to
+// mono.
+//
+// libmikmod only mixes to stereo, so the song is rendered up front, as
+// fast as possible, once per output channel in use, with the other
+// channels silenced; this takes a while, and the whole song is held in
+// memory, about 5 MB per output channel and minute at 44100 Hz.  With
+// opts.Loop, if the module loops, the stream starts over from the
+// rendered song's start when it ends, rather than at the module's
+// restart position.  Any module currently playing is stopped.  Channel
+// volumes set by SetChannelVolume still apply.
+func NewRoutedStream(m *Module, r Routing, opts StreamOptions) (*Stream, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	if err := m.usable(); err != nil {
+		return nil, err
+	}
+	mixed := currentFormat()
+	if mixed.BitsPerSample != 16 {
+		return nil, errRoutingFormat
+	}
+	if r.Outputs <= 0 {
+		return nil, fmt.Errorf("mikmod: invalid number of outputs %d", r.Outputs)
+	}
+	numChannels := m.NumChannels()
+	for ch, out := range r.Channels {
+		if ch < 0 || ch >= numChannels {
+			return nil, fmt.Errorf("mikmod: routing channel %d of %d", ch, numChannels)
+		}
+		if out < 0 || out >= r.Outputs {
+			return nil, fmt.Errorf("mikmod: routing channel %d to output %d of %d", ch, out, r.Outputs)
+		}
+	}
+
+	volumes := make([]float64, numChannels)
+	for ch := range volumes {
+		volumes[ch] = ChannelVolume(ch)
+	}
+	defer func() {
+		for ch, v := range volumes {
+			SetChannelVolume(ch, v)
+		}
+	}()
+	outputs := make([][]int16, r.Outputs)
+	frames := 0
+	for out := range outputs {
+		used := false
+		for ch := range volumes {
+			if o, ok := r.Channels[ch]; ok && o == out {
+				SetChannelVolume(ch, volumes[ch])
+				used = true
+			} else {
+				SetChannelVolume(ch, 0)
+			}
+		}
+		if !used {
+			continue
+		}
+		var samples []int16
+		err := render(m, RenderOptions{}, func(b []byte) error {
+			samples = appendMono(samples, b, mixed.Channels)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		outputs[out] = samples
+		frames = max(frames, len(samples))
+	}
+
+	// The passes play the same song, so they line up; pad any that
+	// ended early, should random effects differ between them.
+	f := Format{SampleRate: mixed.SampleRate, Channels: r.Outputs, BitsPerSample: 16}
+	pcm := make([]byte, frames*f.FrameSize())
+	for out, samples := range outputs {
+		for i, v := range samples {
+			binary.LittleEndian.PutUint16(pcm[(i*r.Outputs+out)*2:], uint16(v))
+		}
+	}
+
+	rd := bytes.NewReader(pcm)
+	read := rd.Read
+	if opts.Loop && m.Loop() && len(pcm) > 0 {
+		read = func(b []byte) (int, error) {
+			n, err := rd.Read(b)
+			if err == io.EOF {
+				rd.Seek(0, io.SeekStart)
+				return rd.Read(b)
+			}
+			return n, err
+		}
+	}
+	s := &Stream{filters: filterChain{filters: opts.Filters}, stop: func() {}}
+	var err error
+	s.read, s.format, err = streamReader(read, f, opts.Speed, opts.SampleRate, opts.Resample)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// appendMono appends 16-bit PCM data b, with channels channels, to s,
+// mixed to mono.  Stereo frames are summed rather than averaged, as
+// libmikmod splits a voice's volume between the two sides.
+func appendMono(s []int16, b []byte, channels int) []int16 {
+	frameSize := 2 * channels
+	for i := 0; i+frameSize <= len(b); i += frameSize {
+		var v float64
+		for c := range channels {
+			v += float64(int16(binary.LittleEndian.Uint16(b[i+2*c:])))
+		}
+		s = append(s, clampInt16(v))
+	}
+	return s
+}
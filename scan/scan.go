@@ -0,0 +1,329 @@
+// Package scan extracts the metadata of many modules concurrently,
+// e.g. to index a library, hiding the fact that libmikmod's loader
+// state is global and so only one module can load at a time per
+// process.
+//
+// By default, files are read concurrently but loaded one at a time in
+// the calling process.  To use every core, and to survive loaders that
+// crash or hang on broken files, scan in worker processes instead.
+// These run the program's own executable, which must call ServeWorker
+// first thing in main:
+//
+//	func main() {
+//		scan.ServeWorker()
+//		...
+//		results, err := scan.Scan(ctx, filenames, scan.Options{Processes: true})
+//		...
+//	}
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/death/go-mikmod"
+)
+
+// workerEnv is the environment variable marking worker processes.
+const workerEnv = "GO_MIKMOD_SCAN_WORKER"
+
+// defaultTimeout bounds the scan of each file in a worker process,
+// unless Options.Timeout says otherwise.
+const defaultTimeout = 30 * time.Second
+
+// Metadata describes a module, as extracted by Scan.
+type Metadata struct {
+	File        string        `json:"file"`
+	Title       string        `json:"title"`
+	Format      string        `json:"format"`
+	Tracker     string        `json:"tracker"`
+	Channels    int           `json:"channels"`
+	Positions   int           `json:"positions"`
+	Patterns    int           `json:"patterns"`
+	Instruments int           `json:"instruments"`
+	Samples     int           `json:"samples"`
+	Comment     string        `json:"comment,omitempty"`
+	Duration    time.Duration `json:"duration,omitempty"`
+
+	// Error, if not "", is why the file could not be scanned, in
+	// which case the other fields but File are zero.
+	Error string `json:"error,omitempty"`
+}
+
+// Options controls how Scan proceeds.
+type Options struct {
+	// Workers is the number of files scanned at the same time.  If
+	// 0, it is the number of CPUs.
+	Workers int
+
+	// Processes makes each worker scan files in its own process,
+	// running the program's executable, which must call
+	// ServeWorker.  A worker whose loader crashes or hangs is
+	// replaced, and only the file it was scanning fails.
+	Processes bool
+
+	// Timeout, if not 0, replaces the 30 second limit on scanning
+	// each file in a worker process.  It does not apply without
+	// Processes, as a load in progress cannot be interrupted.
+	Timeout time.Duration
+
+	// Duration makes Scan measure the duration of each song, by
+	// rendering it, which takes much longer than reading the rest.
+	// Without Processes, this stops any module playing.
+	Duration bool
+}
+
+var errNotServing = errors.New("scan: worker processes must call ServeWorker")
+
+// Scan extracts the metadata of the modules in filenames, returning it
+// in the same order.  Files that cannot be scanned have their Error
+// set.  Scan only fails as a whole if ctx is done or worker processes
+// cannot start; the scans completed until then are still returned.
+func Scan(ctx context.Context, filenames []string, opts Options) ([]Metadata, error) {
+	if opts.Processes && os.Getenv(workerEnv) != "" {
+		// The worker did not call ServeWorker, and would spawn
+		// workers of its own.
+		return nil, errNotServing
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, len(filenames))
+	if !opts.Processes {
+		if err := mikmod.InitNoSound(); err != nil {
+			return nil, err
+		}
+		defer mikmod.Uninit()
+	}
+
+	results := make([]Metadata, len(filenames))
+	jobs := make(chan int)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var p *process
+			defer func() {
+				if p != nil {
+					p.close()
+				}
+			}()
+			for i := range jobs {
+				if !opts.Processes {
+					results[i] = scanFile(filenames[i], opts.Duration)
+					continue
+				}
+				if p == nil {
+					var err error
+					if p, err = startProcess(); err != nil {
+						errs <- err
+						return
+					}
+				}
+				var err error
+				results[i], err = p.scan(ctx, filenames[i], opts)
+				if err != nil {
+					// The worker is in an unknown state.
+					p.close()
+					p = nil
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range filenames {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	return results, err
+}
+
+// loadMu serializes loads in the calling process.
+var loadMu sync.Mutex
+
+// scanFile extracts the metadata of the module in filename, in the
+// calling process, which must have initialized MikMod.
+func scanFile(filename string, duration bool) Metadata {
+	md := Metadata{File: filename}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		md.Error = err.Error()
+		return md
+	}
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	m, err := mikmod.LoadModuleFromSlice(data)
+	if err != nil {
+		md.Error = err.Error()
+		return md
+	}
+	defer m.Close()
+	md.Title = m.Title()
+	md.Format = m.Type().String()
+	md.Tracker = m.Tracker()
+	md.Channels = m.NumChannels()
+	md.Positions = m.NumPositions()
+	md.Patterns = m.NumPatterns()
+	md.Instruments = m.NumInstruments()
+	md.Samples = m.NumSamples()
+	md.Comment = m.Comment()
+	if duration {
+		if md.Duration, err = mikmod.MeasureDuration(m); err != nil {
+			return Metadata{File: filename, Error: err.Error()}
+		}
+	}
+	return md
+}
+
+// request asks a worker process to scan a file.
+type request struct {
+	File     string `json:"file"`
+	Duration bool   `json:"duration"`
+}
+
+// ServeWorker returns immediately, unless the program was started by
+// Scan as a worker process, in which case it scans the files Scan asks
+// for and exits.  Call it first thing in main, before the program does
+// anything else, if it scans in worker processes.
+func ServeWorker() {
+	if os.Getenv(workerEnv) == "" {
+		return
+	}
+	if err := mikmod.InitNoSound(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Results go to a pipe of their own, so that output of the
+	// program or of libmikmod cannot corrupt them.
+	out := json.NewEncoder(os.NewFile(3, "results"))
+	in := json.NewDecoder(os.Stdin)
+	for {
+		var req request
+		if err := in.Decode(&req); err != nil {
+			mikmod.Uninit()
+			os.Exit(0)
+		}
+		if err := out.Encode(scanFile(req.File, req.Duration)); err != nil {
+			os.Exit(1)
+		}
+	}
+}
+
+// process is a worker process.
+type process struct {
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+	enc  *json.Encoder
+	dec  *json.Decoder
+	done chan struct{}
+}
+
+// startProcess starts a worker process.
+func startProcess() (*process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), workerEnv+"=1")
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{w}
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("scan: starting a worker: %w", err)
+	}
+	p := &process{cmd: cmd, in: in, enc: json.NewEncoder(in), dec: json.NewDecoder(r), done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		r.Close()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// scan has the worker scan filename.  It returns an error, along with
+// the metadata reporting it, if the worker did not answer, in which
+// case it must not be used again.
+func (p *process) scan(ctx context.Context, filename string, opts Options) (Metadata, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	type result struct {
+		md  Metadata
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		var r result
+		if r.err = p.enc.Encode(request{File: filename, Duration: opts.Duration}); r.err == nil {
+			r.err = p.dec.Decode(&r.md)
+		}
+		results <- r
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case r := <-results:
+		if r.err == nil {
+			return r.md, nil
+		}
+		err = errors.New("scan: worker exited while scanning")
+	case <-timer.C:
+		err = errors.New("scan: scanning timed out")
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	p.cmd.Process.Kill()
+	return Metadata{File: filename, Error: err.Error()}, err
+}
+
+// close stops the worker, if it is still running, and waits for it to
+// exit.
+func (p *process) close() {
+	p.in.Close()
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+}
@@ -0,0 +1,169 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+typedef struct {
+	int playing;
+	SLONG position;
+	ULONG frequency;
+	UWORD volume;
+	ULONG level;
+} voiceState;
+
+// channelStates fills s with the state of the voices playing the
+// first n channels.
+static void channelStates(voiceState *s, int n) {
+	int ch;
+	for (ch = 0; ch < n; ch++) {
+		int voice = Player_GetChannelVoice(ch);
+		if (voice < 0 || Voice_Stopped(voice)) {
+			s[ch].playing = 0;
+			continue;
+		}
+		s[ch].playing = 1;
+		s[ch].position = Voice_GetPosition(voice);
+		s[ch].frequency = Voice_GetFrequency(voice);
+		s[ch].volume = Voice_GetVolume(voice);
+		s[ch].level = Voice_RealVolume(voice);
+	}
+}
+*/
+import "C"
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxVoiceSetVolume is the largest volume set by Voice_SetVolume.
+const maxVoiceSetVolume = 256
+
+// VoiceFrame is the state of the voice playing a channel as of one
+// update.
+type VoiceFrame struct {
+	// Time is when the update was mixed.
+	Time time.Time
+
+	// Playing is false if no voice played the channel.  The other
+	// fields are then zero.
+	Playing bool
+
+	// Position is the sample frame reached in the voice's sample.
+	Position int
+
+	// Frequency is the playback rate of the sample, in Hz.
+	Frequency int
+
+	// Volume is the volume of the voice, between 0 and 1, and Level
+	// its actual output level, between 0 and 1, which also depends
+	// on the sample data around Position.
+	Volume float64
+	Level  float64
+}
+
+// Scope records the state of the voices playing each channel over the
+// last updates, for per-channel oscilloscope displays.  libmikmod's
+// mixer does not expose the output of individual voices, so scopes
+// draw from the position in each voice's sample, or from the level.
+// The recording is delayed by the output latency so that it matches
+// what is heard.  Call Stop when done with it.
+type Scope struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	frames [][]VoiceFrame
+	next   int
+	count  int
+}
+
+var (
+	scopesMu sync.Mutex
+	scopes   []*Scope
+)
+
+// NewScope returns a scope recording the last n updates of each
+// channel.  As the update loop runs every 10ms, n = 10 covers about a
+// tenth of a second.
+func NewScope(n int) *Scope {
+	s := &Scope{
+		delay:  OutputLatency().Total(),
+		frames: make([][]VoiceFrame, C.UF_MAXCHAN),
+	}
+	for ch := range s.frames {
+		s.frames[ch] = make([]VoiceFrame, max(n, 1))
+	}
+	scopesMu.Lock()
+	scopes = append(scopes, s)
+	scopesMu.Unlock()
+	return s
+}
+
+// Stop stops recording.
+func (s *Scope) Stop() {
+	scopesMu.Lock()
+	scopes = slices.DeleteFunc(scopes, func(other *Scope) bool { return other == s })
+	scopesMu.Unlock()
+}
+
+// Channel returns the recorded states of the voice playing channel ch,
+// oldest first, up to the one being heard now.
+func (s *Scope) Channel(ch int) []VoiceFrame {
+	if ch < 0 || ch >= len(s.frames) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ring := s.frames[ch]
+	heard := time.Now().Add(-s.delay)
+	var frames []VoiceFrame
+	for i := range s.count {
+		f := ring[(s.next-s.count+i+len(ring))%len(ring)]
+		if f.Time.After(heard) {
+			break
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// record records the states of the voices playing the channels.
+func (s *Scope) record(now time.Time, states []C.voiceState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch, ring := range s.frames {
+		f := VoiceFrame{Time: now}
+		if ch < len(states) && states[ch].playing != 0 {
+			st := states[ch]
+			f.Playing = true
+			f.Position = int(st.position)
+			f.Frequency = int(st.frequency)
+			f.Volume = float64(st.volume) / maxVoiceSetVolume
+			f.Level = float64(st.level) / maxVoiceVolume
+		}
+		ring[s.next] = f
+	}
+	s.next = (s.next + 1) % len(s.frames[0])
+	s.count = min(s.count+1, len(s.frames[0]))
+}
+
+// updateScopes records the states of the voices of the playing module
+// in the scopes.  It is called by the update loop.
+func updateScopes() {
+	scopesMu.Lock()
+	defer scopesMu.Unlock()
+	if len(scopes) == 0 {
+		return
+	}
+	var states []C.voiceState
+	if m := playingModule(); m != nil {
+		states = make([]C.voiceState, min(m.NumChannels(), C.UF_MAXCHAN))
+		if len(states) > 0 {
+			C.channelStates(&states[0], C.int(len(states)))
+		}
+	}
+	now := time.Now()
+	for _, s := range scopes {
+		s.record(now, states)
+	}
+}
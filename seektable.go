@@ -0,0 +1,117 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// SeekTable maps the playing time of a module's song to the song
+// positions and rows playing, taking speed and tempo changes, pattern
+// breaks and position jumps into account.
+type SeekTable struct {
+	rows     []seekRow
+	duration time.Duration
+
+	// loops is true if the song loops back rather than ending.
+	loops bool
+}
+
+// seekRow is a row of the song, along with the time it starts at and
+// the speed and tempo it plays with.
+type seekRow struct {
+	at           time.Duration
+	pos, row     int
+	speed, tempo int
+}
+
+// SeekPoint is a point of a song: the song position and row playing at
+// a time.
+type SeekPoint struct {
+	Position int
+	Row      int
+	Time     time.Duration
+}
+
+// BuildSeekTable walks the song once, as the player would, and returns
+// its seek table, which later calls, and Seek, reuse.
+func (m *Module) BuildSeekTable() *SeekTable {
+	if t := m.seekTable.Load(); t != nil {
+		return t
+	}
+	t := &SeekTable{}
+	timing := m.initialTiming()
+	t.loops = !m.walkSong(0, func(pos int, r PatternRow) {
+		s := seekRow{at: t.duration, pos: pos, row: r.Row}
+		t.duration += timing.row(r)
+		s.speed, s.tempo = timing.speed, timing.tempo
+		t.rows = append(t.rows, s)
+	})
+	m.seekTable.Store(t)
+	return t
+}
+
+// Duration returns the duration of a pass through the song.
+func (t *SeekTable) Duration() time.Duration { return t.duration }
+
+// Locate returns the row playing at time d, or false if d is not
+// within the song.
+func (t *SeekTable) Locate(d time.Duration) (SeekPoint, bool) {
+	r, ok := t.find(d)
+	return SeekPoint{Position: r.pos, Row: r.row, Time: r.at}, ok
+}
+
+// find returns the row playing at time d, or false if d is not within
+// the song.
+func (t *SeekTable) find(d time.Duration) (seekRow, bool) {
+	if d < 0 || d >= t.duration {
+		return seekRow{}, false
+	}
+	return t.rows[sort.Search(len(t.rows), func(i int) bool { return t.rows[i].at > d })-1], true
+}
+
+// Time returns the time at which row row of song position pos starts,
+// e.g. to show progress, or false if the song does not play it.
+func (t *SeekTable) Time(pos, row int) (time.Duration, bool) {
+	for _, r := range t.rows {
+		if r.pos == pos && r.row == row {
+			return r.at, true
+		}
+	}
+	return 0, false
+}
+
+var errSeekOutOfRange = errors.New("mikmod: seeking outside of the song")
+
+// Seek jumps to time d of the playing module's song, building its seek
+// table if needed.  Playback resumes at the start of the row playing
+// at d, with the speed and tempo in effect there.
+func Seek(d time.Duration) error {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return errNothingPlaying
+	}
+	r, ok := m.BuildSeekTable().find(d)
+	if !ok {
+		return errSeekOutOfRange
+	}
+	do(func() {
+		C.Player_SetPosition(C.UWORD(r.pos))
+		// As with seek, the player jumps to the row in patbrk on
+		// the next tick, and keeps the speed, tempo and time set.
+		C.MikMod_Lock()
+		m.module.patbrk = C.UWORD(r.row)
+		m.module.sngspd = C.UWORD(r.speed)
+		m.module.bpm = C.UWORD(r.tempo)
+		m.module.sngtime = C.ULONG(r.at * 1024 / time.Second)
+		C.MikMod_Unlock()
+	})
+	return nil
+}
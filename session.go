@@ -0,0 +1,50 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Session identifies the playback of a module, from the time it is
+// started, by Play, Swap, a playlist or QueueNext, until another one
+// is.  Errors reported asynchronously and clock events carry the
+// session during which they arose, so that a late error from the
+// previous song is not blamed on the one starting.  Resuming a module
+// after recovering from an error starts a new session too.  Sessions
+// are numbered from 1 in the order they start.
+type Session uint64
+
+// currentSession is the last session started.
+var currentSession atomic.Uint64
+
+// CurrentSession returns the session of the module playing or played
+// last, or 0 if none was started.
+func CurrentSession() Session { return Session(currentSession.Load()) }
+
+// newSession starts a new session and returns it.
+func newSession() Session { return Session(currentSession.Add(1)) }
+
+// SessionError is an error reported to the error handler, along with
+// the session during which it arose.
+type SessionError struct {
+	Session Session
+	Err     error
+}
+
+// Error returns the description of the error.
+func (e *SessionError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the error.
+func (e *SessionError) Unwrap() error { return e.Err }
+
+// SessionOf returns the session during which err, an error passed to
+// the error handler, arose, and false if err carries no session.
+func SessionOf(err error) (Session, bool) {
+	var e *SessionError
+	if errors.As(err, &e) {
+		return e.Session, true
+	}
+	return 0, false
+}
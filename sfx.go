@@ -0,0 +1,274 @@
+package mikmod
+
+/*
+#include <stdlib.h>
+#include <mikmod.h>
+
+// playOn plays sample s on voice v with the sample's own volume,
+// panning and rate, as Sample_Play does.
+static void playOn(SBYTE v, SAMPLE *s) {
+	Voice_Play(v, s, 0);
+	Voice_SetVolume(v, s->volume << 2);
+	Voice_SetPanning(v, s->panning);
+	Voice_SetFrequency(v, s->speed);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Sample is a sound effect, played by libmikmod on the voices reserved
+// with Options.SFXVoices.  Remember to Close it when done.
+type Sample struct {
+	sample *C.SAMPLE
+
+	// filename or data is what the sample was loaded from, kept for
+	// SwitchDriver.
+	filename string
+	data     []byte
+}
+
+// LoadSampleFromFile loads a sound effect from the WAV file designated
+// by filename.
+func LoadSampleFromFile(filename string) (*Sample, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	fn := mikmodString(filename)
+	defer C.free(unsafe.Pointer(fn))
+	s, err := loadSample(func() *C.SAMPLE { return C.Sample_Load(fn) })
+	if err != nil {
+		return nil, fmt.Errorf("mikmod: loading sample %s: %w", filename, err)
+	}
+	s.filename = filename
+	return s, nil
+}
+
+// LoadSampleFromSlice loads a sound effect from WAV data.  The sample
+// keeps a reference to b, so b must not be modified afterwards.
+func LoadSampleFromSlice(b []byte) (*Sample, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, ErrNotAStream
+	}
+	s, err := loadSample(func() *C.SAMPLE {
+		return C.Sample_LoadMem((*C.char)(unsafe.Pointer(&b[0])), C.int(len(b)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.data = b
+	return s, nil
+}
+
+// loadSample loads a sample using load.
+func loadSample(load func() *C.SAMPLE) (*Sample, error) {
+	var (
+		s   *Sample
+		err error
+	)
+	lerr := withLibrary(func() {
+		do(func() {
+			if sample := load(); sample != nil {
+				s = &Sample{sample: sample}
+			} else {
+				err = mikmodError()
+			}
+		})
+	})
+	if lerr != nil {
+		return nil, lerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	trackSample(s)
+	return s, nil
+}
+
+// Close frees the sample.  Voices playing it must be stopped first.
+// Closing a sample again, or after Uninit closed it, does nothing.
+func (s *Sample) Close() error {
+	if s != nil && untrackSample(s) {
+		s.free()
+	}
+	return nil
+}
+
+// free frees the sample's memory in libmikmod.
+func (s *Sample) free() {
+	do(func() { C.Sample_Free(s.sample) })
+	s.sample = nil
+}
+
+// SampleOptions controls how PlaySample plays a sample.
+type SampleOptions struct {
+	// Priority ranks the sound effect, e.g. for ducking; see
+	// SetDucking.  Higher values are more important.
+	Priority int
+
+	// Critical keeps the voice from being reused for another sound
+	// effect until the sample ends.  When all voices are reserved
+	// for critical samples, PlaySample fails.
+	Critical bool
+
+	// Steal is the policy applied if all voices are busy.  If
+	// StealDefault, the policy set by SetStealPolicy applies.
+	Steal StealPolicy
+
+	// OnEnd, if not nil, is called on its own goroutine once the
+	// sound effect ends, is stopped or is cut off by another, e.g.
+	// to chain sounds or close the sample.  Ends are noticed by the
+	// update loop, within an update period.
+	OnEnd func()
+}
+
+// sfxVoice is a sound effect started by PlaySample.
+type sfxVoice struct {
+	priority int
+	critical bool
+	started  time.Time
+	onEnd    func()
+}
+
+// sfxMu guards sfxVoices, the sound effects started by PlaySample, by
+// voice.  It is only locked on the actor.
+var (
+	sfxMu     sync.Mutex
+	sfxVoices = map[int]sfxVoice{}
+)
+
+// PlaySample plays sample s on one of the voices reserved for sound
+// effects, and returns a handle to the voice.  If all of them are
+// busy, a voice is stolen according to the steal policy, but critical
+// sound effects are never cut off.  Sound effects are mixed by the update loop, so they
+// are only heard while a module plays.
+func PlaySample(s *Sample, opts SampleOptions) (Voice, error) {
+	if err := checkInitialized(); err != nil {
+		return Voice{}, err
+	}
+	if s == nil || s.sample == nil {
+		return Voice{}, ErrClosed
+	}
+	policy := opts.Steal
+	if policy == StealDefault {
+		policy = StealPolicy(stealPolicy.Load())
+	}
+	voice := call(func() Voice {
+		sfxMu.Lock()
+		defer sfxMu.Unlock()
+		voice := pickVoice(policy, opts.Priority)
+		if voice < 0 {
+			return Voice{}
+		}
+		endSFX(voice)
+		C.playOn(C.SBYTE(voice), s.sample)
+		sfxVoices[voice] = sfxVoice{
+			priority: opts.Priority,
+			critical: opts.Critical,
+			started:  time.Now(),
+			onEnd:    opts.OnEnd,
+		}
+		return newVoice(voice)
+	})
+	if voice == (Voice{}) {
+		return Voice{}, errNoVoice
+	}
+	return voice, nil
+}
+
+var errNoVoice = errors.New("mikmod: no voice available for the sample")
+
+// SetVoiceVolume sets the volume of voice v, between 0 and 1.  Unlike
+// Voice.SetVolume, it applies to whatever the voice plays.
+func SetVoiceVolume(v int, vol float64) {
+	do(func() { C.Voice_SetVolume(C.SBYTE(v), C.UWORD(clamp(vol, 0, 1)*maxVoiceSetVolume+0.5)) })
+}
+
+// SetVoicePanning sets the position of voice v in the stereo field,
+// from -1 for left to 1 for right.
+func SetVoicePanning(v int, pan float64) {
+	do(func() { C.Voice_SetPanning(C.SBYTE(v), C.ULONG((clamp(pan, -1, 1)+1)/2*C.PAN_RIGHT+0.5)) })
+}
+
+// SetVoiceFrequency sets the playback rate of voice v, in Hz.
+func SetVoiceFrequency(v int, hz int) {
+	do(func() { C.Voice_SetFrequency(C.SBYTE(v), C.ULONG(hz)) })
+}
+
+// StopVoice stops voice v, unless it plays a reserved channel; see
+// ReserveChannel.
+func StopVoice(v int) {
+	do(func() {
+		if !voiceReserved(v) {
+			C.Voice_Stop(C.SBYTE(v))
+		}
+	})
+}
+
+// VoiceStopped returns true if voice v is not playing, and false
+// otherwise.
+func VoiceStopped(v int) bool {
+	return call(func() bool { return goBool(C.Voice_Stopped(C.SBYTE(v))) })
+}
+
+// playingSFXPriority returns the highest priority of the sound effects
+// playing, and false if none is, forgetting those that stopped.  It
+// expects to be called on the actor, e.g. by the update loop.
+func playingSFXPriority() (int, bool) {
+	sfxMu.Lock()
+	defer sfxMu.Unlock()
+	top, found := 0, false
+	for voice, v := range sfxVoices {
+		if C.Voice_Stopped(C.SBYTE(voice)) != 0 {
+			endSFX(voice)
+			continue
+		}
+		if !found || v.priority > top {
+			top, found = v.priority, true
+		}
+	}
+	return top, found
+}
+
+// updateSFX forgets the sound effects that ended, calling their OnEnd
+// functions.  It is called by the update loop.
+func updateSFX() {
+	sfxMu.Lock()
+	defer sfxMu.Unlock()
+	for voice := range sfxVoices {
+		if C.Voice_Stopped(C.SBYTE(voice)) != 0 {
+			endSFX(voice)
+		}
+	}
+}
+
+// endSFX forgets the sound effect started on voice, if any, calling its
+// OnEnd function.  It expects sfxMu to be locked.
+func endSFX(voice int) {
+	v, ok := sfxVoices[voice]
+	if !ok {
+		return
+	}
+	delete(sfxVoices, voice)
+	if v.onEnd != nil {
+		go v.onEnd()
+	}
+}
+
+// endAllSFX forgets all the sound effects, calling their OnEnd
+// functions, as their voices were stopped.  It expects sfxMu to be
+// locked.
+func endAllSFX() {
+	for voice := range sfxVoices {
+		endSFX(voice)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build cgo
+
+package mikmod_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/death/go-mikmod"
+)
+
+// testSample returns a WAV file holding a short 8-bit mono click.
+func testSample(t *testing.T) []byte {
+	t.Helper()
+	f := mikmod.Format{SampleRate: 22050, Channels: 1, BitsPerSample: 8}
+	pcm := bytes.Repeat([]byte{0x80, 0xff, 0x80, 0x00}, 256)
+	var b bytes.Buffer
+	if err := mikmod.WriteWAVHeader(&b, f, uint32(len(pcm))); err != nil {
+		t.Fatal(err)
+	}
+	b.Write(pcm)
+	return b.Bytes()
+}
+
+func TestPlaySample(t *testing.T) {
+	err := mikmod.InitWithOptions(mikmod.Options{
+		Drivers:   []*mikmod.Driver{mikmod.DriverNoSound},
+		Driver:    mikmod.DriverNoSound,
+		SFXVoices: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mikmod.Uninit()
+	s, err := mikmod.LoadSampleFromSlice(testSample(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := range 4 {
+		if _, err := mikmod.PlaySample(s, mikmod.SampleOptions{}); err != nil {
+			t.Fatalf("PlaySample %d: %v", i, err)
+		}
+	}
+}
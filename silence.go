@@ -0,0 +1,72 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+// silent returns true if no voice produces sound.
+static int silent(void) {
+	int i;
+	for (i = 0; i < md_sngchn; i++)
+		if (!Voice_Stopped(i) && Voice_RealVolume(i) != 0)
+			return 0;
+	return 1;
+}
+*/
+import "C"
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// silenceTimeout holds the duration set by SetSilenceTimeout.
+var silenceTimeout atomic.Int64
+
+// SetSilenceTimeout makes modules end once they have been silent for
+// d, after having produced sound, so that songs ending with many rows
+// of silence or an empty looping pattern do not hold up a playlist.
+// Silence is detected from the voices, so it works with any driver.
+// If d is 0, which is the default, silence is not detected.
+func SetSilenceTimeout(d time.Duration) { silenceTimeout.Store(int64(d)) }
+
+// silenceState tracks the silence of the playing module.  It is only
+// used by the update loop.
+var silenceState struct {
+	module *Module
+	heard  bool
+	since  time.Time
+}
+
+// applySilence ends the playing module if it has been silent for
+// longer than the silence timeout.  It is called by the update loop.
+func applySilence() {
+	d := time.Duration(silenceTimeout.Load())
+	m := playingModule()
+	s := &silenceState
+	if d == 0 || m == nil {
+		s.module = nil
+		return
+	}
+	if s.module != m {
+		s.module, s.heard = m, false
+	}
+	if C.silent() == 0 {
+		s.heard, s.since = true, time.Time{}
+		return
+	}
+	if !s.heard || int(m.module.sngpos) >= int(m.module.numpos) {
+		return
+	}
+	if s.since.IsZero() {
+		s.since = time.Now()
+		return
+	}
+	if time.Since(s.since) >= d {
+		C.Player_SetPosition(m.module.numpos)
+		s.heard = false
+		// Keep the pass policy from restarting the song.
+		if passState.module == m {
+			passState.finished = true
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package mikmod
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Sink is a destination for 16-bit PCM data, e.g. a file, a network
+// peer or an encoder feeding a streaming server, fed by RenderToSink
+// and Stream.CopyTo.  Implement it to add outputs without touching the
+// package.
+type Sink interface {
+	// WriteSamples writes interleaved samples, a whole number of
+	// frames of channels samples each, played at sampleRate Hz.  The
+	// sink must not keep samples after returning.
+	WriteSamples(samples []int16, sampleRate, channels int) error
+
+	// Close flushes and releases the sink.
+	Close() error
+}
+
+var errSinkFormat = errors.New("mikmod: sinks need 16-bit output")
+
+// RenderToSink renders the module, from start to end, into sink, as
+// fast as possible rather than in real time.  Any module currently
+// playing is stopped.  The sink is not closed.
+func RenderToSink(m *Module, sink Sink) error {
+	f := currentFormat()
+	if f.BitsPerSample != 16 {
+		return errSinkFormat
+	}
+	_, err := RenderTo(m, &sinkWriter{sink: sink, format: f})
+	return err
+}
+
+// CopyTo reads the stream until it ends, writing its PCM data to sink,
+// as fast as the sink accepts it.  The sink is not closed.
+func (s *Stream) CopyTo(sink Sink) error {
+	f := s.Format()
+	if f.BitsPerSample != 16 {
+		return errSinkFormat
+	}
+	buf := make([]byte, renderBufferSize-renderBufferSize%f.FrameSize())
+	w := &sinkWriter{sink: sink, format: f}
+	for {
+		n, err := s.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sinkWriter is an io.Writer passing 16-bit PCM data in format to a
+// sink.
+type sinkWriter struct {
+	sink    Sink
+	format  Format
+	samples []int16
+}
+
+func (w *sinkWriter) Write(b []byte) (int, error) {
+	w.samples = decodeInt16(w.samples[:0], b)
+	if err := w.sink.WriteSamples(w.samples, w.format.SampleRate, w.format.Channels); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// decodeInt16 appends the little-endian 16-bit samples of b to s.
+func decodeInt16(s []int16, b []byte) []int16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		s = append(s, int16(binary.LittleEndian.Uint16(b[i:])))
+	}
+	return s
+}
+
+// encodeInt16 appends samples to b as little-endian 16-bit PCM data.
+func encodeInt16(b []byte, samples []int16) []byte {
+	for _, v := range samples {
+		b = binary.LittleEndian.AppendUint16(b, uint16(v))
+	}
+	return b
+}
+
+// BufferSink is a Sink keeping the samples in memory.
+type BufferSink struct {
+	// Samples are the samples written, interleaved, and SampleRate
+	// and Channels their format, as of the last write.
+	Samples    []int16
+	SampleRate int
+	Channels   int
+}
+
+// WriteSamples appends samples to the buffer.
+func (b *BufferSink) WriteSamples(samples []int16, sampleRate, channels int) error {
+	b.Samples = append(b.Samples, samples...)
+	b.SampleRate, b.Channels = sampleRate, channels
+	return nil
+}
+
+// Close does nothing.
+func (b *BufferSink) Close() error { return nil }
+
+// writerSink is a Sink writing raw PCM data to w.
+type writerSink struct {
+	w   io.Writer
+	buf []byte
+
+	// before, if not nil, is called before each write.
+	before func() error
+}
+
+// NewWriterSink returns a Sink writing raw little-endian 16-bit PCM
+// data to w, e.g. a pipe to an external encoder.  Closing the sink
+// closes w if it is an io.Closer.
+func NewWriterSink(w io.Writer) Sink { return &writerSink{w: w} }
+
+// NewConnSink returns a Sink sending raw little-endian 16-bit PCM data
+// over c, e.g. to a peer on the network.  If timeout is not 0, each
+// write fails once it takes longer, so that a stalled peer cannot hold
+// up rendering.  Closing the sink closes c.
+func NewConnSink(c net.Conn, timeout time.Duration) Sink {
+	s := &writerSink{w: c}
+	if timeout > 0 {
+		s.before = func() error { return c.SetWriteDeadline(time.Now().Add(timeout)) }
+	}
+	return s
+}
+
+func (s *writerSink) WriteSamples(samples []int16, sampleRate, channels int) error {
+	if s.before != nil {
+		if err := s.before(); err != nil {
+			return err
+		}
+	}
+	s.buf = encodeInt16(s.buf[:0], samples)
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+func (s *writerSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// fileSink is a Sink writing a WAV file.
+type fileSink struct {
+	f      *os.File
+	format Format
+	size   uint32
+	buf    []byte
+}
+
+// CreateFileSink creates a Sink writing a WAV file designated by
+// filename, in the format of the first samples written.  The file is
+// complete once the sink is closed.
+func CreateFileSink(filename string) (Sink, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{f: f}, nil
+}
+
+func (s *fileSink) WriteSamples(samples []int16, sampleRate, channels int) error {
+	if s.format.SampleRate == 0 {
+		s.format = Format{SampleRate: sampleRate, Channels: channels, BitsPerSample: 16}
+		if err := writeWAVHeader(s.f, s.format, 0); err != nil {
+			return err
+		}
+	}
+	s.buf = encodeInt16(s.buf[:0], samples)
+	n, err := s.f.Write(s.buf)
+	s.size += uint32(n)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	if s.format.SampleRate == 0 {
+		// Nothing was written: describe silence in the output
+		// format.
+		s.format = Format{SampleRate: currentFormat().SampleRate, Channels: 2, BitsPerSample: 16}
+		if err := writeWAVHeader(s.f, s.format, 0); err != nil {
+			s.f.Close()
+			return err
+		}
+	}
+	if err := finishWAV(s.f, s.format, s.size, nil); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
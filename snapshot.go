@@ -0,0 +1,113 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+// Snapshot is a snapshot of the live player state, which can be
+// restored onto another module, e.g. a freshly loaded revision of the
+// same song for A/B comparisons.
+type Snapshot struct {
+	// Position and Row are the song position and row reached.
+	Position int `json:"position"`
+	Row      int `json:"row"`
+
+	// Speed and Tempo are the current speed and tempo, which may
+	// differ from the module's initial ones.
+	Speed int `json:"speed"`
+	Tempo int `json:"tempo"`
+
+	// SongVolume is the song's global volume, between 0 and 128.
+	SongVolume int `json:"songVolume"`
+
+	// Muted and Panning hold the mute state and panning of each
+	// channel.
+	Muted   []bool `json:"muted"`
+	Panning []int  `json:"panning"`
+
+	// Paused is true if the player was paused.
+	Paused bool `json:"paused"`
+
+	// Volume is MikMod's global volume, and Gain the module's gain.
+	Volume float64 `json:"volume"`
+	Gain   float64 `json:"gain"`
+}
+
+// TakeSnapshot returns a snapshot of the state of the playing module.
+func TakeSnapshot() (Snapshot, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return Snapshot{}, errNothingPlaying
+	}
+	s := Snapshot{
+		Position: Position(),
+		Row:      Row(),
+		Paused:   Paused(),
+		Volume:   Volume(),
+		Gain:     m.Gain(),
+	}
+	for ch := 0; ch < m.NumChannels(); ch++ {
+		s.Muted = append(s.Muted, ChannelMuted(ch))
+	}
+
+	do(func() {
+		C.MikMod_Lock()
+		defer C.MikMod_Unlock()
+		s.Speed = int(m.module.sngspd)
+		s.Tempo = int(m.module.bpm)
+		s.SongVolume = int(m.module.volume)
+		for ch := 0; ch < m.NumChannels() && ch < C.UF_MAXCHAN; ch++ {
+			s.Panning = append(s.Panning, int(m.module.panning[ch]))
+		}
+	})
+	return s, nil
+}
+
+// Restore plays m with the state in the snapshot.  Channels that m
+// does not have are ignored.
+func (s Snapshot) Restore(m *Module) error {
+	m.SetGain(s.Gain)
+	SetVolume(s.Volume)
+	if err := Play(m); err != nil {
+		return err
+	}
+	seek(m, s.Position, s.Row)
+	do(func() {
+		if s.Speed > 0 {
+			C.Player_SetSpeed(C.UWORD(s.Speed))
+		}
+		if s.Tempo > 0 {
+			C.Player_SetTempo(C.UWORD(s.Tempo))
+		}
+		C.Player_SetVolume(C.SWORD(s.SongVolume))
+	})
+	for ch, muted := range s.Muted {
+		if ch >= m.NumChannels() {
+			break
+		}
+		if muted {
+			MuteChannel(ch)
+		} else {
+			UnmuteChannel(ch)
+		}
+	}
+
+	do(func() {
+		C.MikMod_Lock()
+		defer C.MikMod_Unlock()
+		for ch, pan := range s.Panning {
+			if ch >= m.NumChannels() {
+				break
+			}
+			m.module.panning[ch] = C.UWORD(pan)
+		}
+	})
+
+	if s.Paused != Paused() {
+		TogglePause()
+	}
+	return nil
+}
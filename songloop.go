@@ -0,0 +1,87 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	loopHandlerMu sync.Mutex
+	loopHandler   func(m *Module, pos int)
+)
+
+// endOnLoop is set by SetEndOnLoop.
+var endOnLoop atomic.Bool
+
+// SetLoopHandler registers fn to be called when the playing module
+// loops, i.e. jumps back to a song position it already played in the
+// current pass, as songs ending with a position jump do.  pos is the
+// position jumped to.  Sections looped with LoopBetween are not
+// reported.  fn is called on its own goroutine, so it may
+// safely call back into this package.  Passing nil removes the
+// handler.
+func SetLoopHandler(fn func(m *Module, pos int)) {
+	loopHandlerMu.Lock()
+	defer loopHandlerMu.Unlock()
+	loopHandler = fn
+}
+
+// SetEndOnLoop makes modules end when they loop, as detected for
+// SetLoopHandler, instead of playing on forever.  It takes precedence
+// over the pass policy.  The default is false.
+func SetEndOnLoop(on bool) { endOnLoop.Store(on) }
+
+// songLoopState tracks the positions played by the playing module in
+// the current pass.  It is only used by the update loop.
+var songLoopState struct {
+	module  *Module
+	visited []bool
+	lastPos int
+}
+
+// detectSongLoop reports the playing module looping to the loop
+// handler, and ends it if SetEndOnLoop is on.  It is called by the
+// update loop.
+func detectSongLoop() {
+	m := playingModule()
+	s := &songLoopState
+	if m == nil {
+		s.module = nil
+		return
+	}
+	pos, numPos := int(m.module.sngpos), int(m.module.numpos)
+	if s.module != m {
+		s.module, s.visited, s.lastPos = m, make([]bool, numPos), -1
+	}
+	if pos == s.lastPos || pos >= numPos {
+		return
+	}
+	s.lastPos = pos
+	if !s.visited[pos] {
+		s.visited[pos] = true
+		return
+	}
+	clear(s.visited)
+	s.visited[pos] = true
+	if region.Load() != nil {
+		return
+	}
+
+	loopHandlerMu.Lock()
+	fn := loopHandler
+	loopHandlerMu.Unlock()
+	if fn != nil {
+		go fn(m, pos)
+	}
+	if endOnLoop.Load() {
+		C.Player_SetPosition(C.UWORD(numPos))
+		if passState.module == m {
+			passState.finished = true
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "time"
+
+// walkSong goes through the rows of the song from position start, as
+// the player would, following pattern breaks and position jumps, and
+// calls fn with each row, along with its position.  It stops when the
+// song ends, either at its last position or at an end marker, or comes
+// back to a row already walked, i.e. loops, and returns true in the
+// first case and false in the second.  Only ProTracker breaks and
+// jumps are taken into account.
+func (m *Module) walkSong(start int, fn func(pos int, r PatternRow)) bool {
+	orders := m.Orders()
+	type songRow struct{ pos, row int }
+	walked := map[songRow]bool{}
+	pos, row := start, 0
+	for pos >= 0 && pos < len(orders) && orders[pos] != C.LAST_PATTERN {
+		numRows := m.patternLength(orders, pos)
+		if row >= numRows {
+			pos, row = pos+1, 0
+			continue
+		}
+		if walked[songRow{pos, row}] {
+			return false
+		}
+		walked[songRow{pos, row}] = true
+		r := m.PatternRows(orders[pos], row, 1)[0]
+		fn(pos, r)
+		nextPos, nextRow := pos, row+1
+		for _, c := range r.Cells {
+			switch c.Effect {
+			case 0xB:
+				nextPos, nextRow = c.Param, 0
+			case 0xD:
+				if nextPos == pos {
+					nextPos = pos + 1
+				}
+				// The loaders decode the break row already.
+				nextRow = c.Param
+			}
+		}
+		pos, row = nextPos, nextRow
+	}
+	return true
+}
+
+// songTiming follows the speed and tempo of a song being walked.
+type songTiming struct{ speed, tempo int }
+
+// initialTiming returns the speed and tempo the song starts with.
+func (m *Module) initialTiming() songTiming {
+	return songTiming{int(m.module.initspeed), int(m.module.inittempo)}
+}
+
+// row applies the speed and tempo changes of row r, and returns how
+// long the row plays, including any pattern delay.
+func (t *songTiming) row(r PatternRow) time.Duration {
+	repeat := 1
+	for _, c := range r.Cells {
+		if c.Speed > 0 {
+			t.speed = c.Speed
+		}
+		if c.Tempo > 0 {
+			t.tempo = c.Tempo
+		}
+		if c.Effect == 0xE && c.Param>>4 == 0xE {
+			repeat = 1 + c.Param&0xf
+		}
+	}
+	return time.Duration(repeat) * rowDuration(t.speed, t.tempo)
+}
+
+// loopsBack returns true if row row of song position pos ends a
+// pattern loop (E6x), making the player jump back within the position.
+func (m *Module) loopsBack(pos, row int) bool {
+	orders := m.Orders()
+	if pos < 0 || pos >= len(orders) {
+		return false
+	}
+	for _, r := range m.PatternRows(orders[pos], row, 1) {
+		for _, c := range r.Cells {
+			if c.Effect == 0xE && c.Param>>4 == 6 && c.Param&0xf > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
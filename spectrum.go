@@ -0,0 +1,152 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"math"
+	"math/cmplx"
+	"sync"
+	"time"
+)
+
+// spectrumSize is the number of frames analyzed by a Spectrum.  It
+// must be a power of two.
+const spectrumSize = 2048
+
+// spectrumMinFrequency is the lowest frequency of a Spectrum's bands,
+// in Hz.
+const spectrumMinFrequency = 20
+
+// Spectrum is a spectrum analyzer for visualizers.  Feed it the PCM
+// data mixed by MikMod by registering its Write method with SetTap,
+// then call Bands once per video frame.  As mixing runs ahead of the
+// speakers, the analysis is delayed by the output latency so that it
+// matches what is heard.
+type Spectrum struct {
+	mu      sync.Mutex
+	format  Format
+	delay   int
+	ring    []float64
+	written int
+	edges   []int
+	window  []float64
+	bins    []complex128
+}
+
+// NewSpectrum returns a spectrum analyzer splitting the audible range
+// into the given number of logarithmically spaced bands, for data in
+// the format returned by OutputFormat.
+func NewSpectrum(bands int) *Spectrum {
+	s := &Spectrum{
+		format: currentFormat(),
+		window: make([]float64, spectrumSize),
+		bins:   make([]complex128, spectrumSize),
+	}
+	for i := range s.window {
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/spectrumSize)
+	}
+	s.edges = bandEdges(bands, s.format.SampleRate)
+	s.SetDelay(OutputLatency().Total())
+	return s
+}
+
+// bandEdges returns the FFT bins at which bands logarithmically spaced
+// between spectrumMinFrequency and the Nyquist frequency start, along
+// with the bin at which the last band ends.  Each band spans at least
+// one bin, as far as there are bins.
+func bandEdges(bands int, rate int) []int {
+	edges := make([]int, bands+1)
+	nyquist := float64(rate) / 2
+	ratio := nyquist / spectrumMinFrequency
+	for i := range edges {
+		f := spectrumMinFrequency * math.Pow(ratio, float64(i)/float64(bands))
+		bin := int(math.Round(f * spectrumSize / float64(rate)))
+		if i > 0 {
+			bin = max(bin, edges[i-1]+1)
+		}
+		edges[i] = min(bin, spectrumSize/2)
+	}
+	return edges
+}
+
+// SetDelay sets how far the analysis lags behind the data written,
+// e.g. 0 when the data comes from a Stream rather than an output
+// driver.
+func (s *Spectrum) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = int(d.Seconds() * float64(s.format.SampleRate))
+	s.ring = make([]float64, spectrumSize+s.delay)
+	s.written = 0
+}
+
+// Write records PCM data b, mixing its channels down to mono.  It
+// never fails, so that it may be passed to SetTap.
+func (s *Spectrum) Write(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.format
+	if f.Channels == 0 {
+		return
+	}
+	n := f.numSamples(b) / f.Channels
+	for i := range n {
+		var v float64
+		for c := range f.Channels {
+			v += f.sampleAt(b, i*f.Channels+c)
+		}
+		s.ring[s.written%len(s.ring)] = v / float64(f.Channels)
+		s.written++
+	}
+}
+
+// Bands returns the magnitude of each band, as heard now, where 1 is
+// the magnitude of a full-scale sine wave.
+func (s *Spectrum) Bands() []float64 {
+	s.mu.Lock()
+	end := s.written - s.delay
+	for i := range s.bins {
+		j := end - spectrumSize + i
+		v := 0.0
+		if j >= 0 {
+			v = s.ring[j%len(s.ring)] * s.window[i]
+		}
+		s.bins[i] = complex(v, 0)
+	}
+	fft(s.bins)
+	bands := make([]float64, len(s.edges)-1)
+	for i := range bands {
+		for _, c := range s.bins[s.edges[i]:s.edges[i+1]] {
+			bands[i] = max(bands[i], cmplx.Abs(c)*4/spectrumSize)
+		}
+	}
+	s.mu.Unlock()
+	return bands
+}
+
+// fft replaces x, whose length is a power of two, with its discrete
+// Fourier transform.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			t := complex(1, 0)
+			for k := range size / 2 {
+				a, b := x[start+k], x[start+k+size/2]*t
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				t *= w
+			}
+		}
+	}
+}
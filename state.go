@@ -0,0 +1,144 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// PlaybackState is the state saved by SaveState, identifying the
+// playing module and the position reached.
+type PlaybackState struct {
+	// Filename is the file the module was loaded from, if any.
+	Filename string `json:"filename,omitempty"`
+
+	// SHA256 is the digest of the module's data, used to check that
+	// the module restored is the one saved.
+	SHA256 []byte `json:"sha256,omitempty"`
+
+	// Position and Row are the song position and row reached.
+	Position int `json:"position"`
+	Row      int `json:"row"`
+
+	// Volume is MikMod's global volume, and Gain the module's gain.
+	Volume float64 `json:"volume"`
+	Gain   float64 `json:"gain"`
+}
+
+var (
+	errNothingPlaying = errors.New("mikmod: no module is playing")
+	errNoFilename     = errors.New("mikmod: saved module was not loaded from a file")
+	errStateMismatch  = errors.New("mikmod: module does not match saved state")
+)
+
+// SaveState returns the state of playback, so that it can be resumed
+// with RestoreState after the program restarts.
+func SaveState() ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return nil, errNothingPlaying
+	}
+	s := PlaybackState{
+		Filename: m.filename,
+		Position: Position(),
+		Row:      Row(),
+		Volume:   Volume(),
+		Gain:     m.Gain(),
+	}
+	digest, err := m.sha256()
+	if err != nil {
+		return nil, err
+	}
+	s.SHA256 = digest
+	return json.Marshal(s)
+}
+
+// RestoreState loads the module designated by a state saved by
+// SaveState and plays it from the saved position.  Playback resumes at
+// the start of the saved row, so the restored position is
+// approximate.  The caller is responsible for closing the module.
+func RestoreState(b []byte) (*Module, error) {
+	var s PlaybackState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if s.Filename == "" {
+		return nil, errNoFilename
+	}
+	m, err := LoadModuleFromFile(s.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreState(m, s); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// RestoreStateTo is like RestoreState, but plays the module m, loaded
+// by the caller, e.g. from memory.
+func RestoreStateTo(b []byte, m *Module) error {
+	var s PlaybackState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return restoreState(m, s)
+}
+
+// restoreState checks that m is the module saved in s, and plays it
+// from the saved position.
+func restoreState(m *Module, s PlaybackState) error {
+	if s.SHA256 != nil {
+		digest, err := m.sha256()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(digest, s.SHA256) {
+			return errStateMismatch
+		}
+	}
+	m.SetGain(s.Gain)
+	SetVolume(s.Volume)
+	if err := Play(m); err != nil {
+		return err
+	}
+	seek(m, s.Position, s.Row)
+	return nil
+}
+
+// seek jumps to row row of song position pos of the playing module m.
+func seek(m *Module, pos int, row int) {
+	do(func() {
+		C.Player_SetPosition(C.UWORD(pos))
+		// The player jumps to the row in patbrk when it handles
+		// the position change on the next tick.
+		C.MikMod_Lock()
+		m.module.patbrk = C.UWORD(row)
+		C.MikMod_Unlock()
+	})
+}
+
+// sha256 returns the SHA-256 digest of the data the module was loaded
+// from.
+func (m *Module) sha256() ([]byte, error) {
+	if m.digest != nil || m.filename == "" {
+		return m.digest, nil
+	}
+	data, err := os.ReadFile(m.filename)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	m.digest = digest[:]
+	return m.digest, nil
+}
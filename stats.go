@@ -0,0 +1,100 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats are statistics about the current session, i.e. since MikMod
+// was initialized or ResetStats was called, e.g. to profile the CPU
+// use of playback.
+type Stats struct {
+	// Since is when the session started.
+	Since time.Time `json:"since"`
+
+	// Ticks is the number of player ticks mixed, by the update loop
+	// as well as by Streams and renders.
+	Ticks uint64 `json:"ticks"`
+
+	// Updates is the number of times the update loop serviced
+	// MikMod, and AverageUpdateTime and MaxUpdateTime the average
+	// and longest time an update took.
+	Updates           uint64        `json:"updates"`
+	AverageUpdateTime time.Duration `json:"averageUpdateTime"`
+	MaxUpdateTime     time.Duration `json:"maxUpdateTime"`
+
+	// PeakVoices is the largest number of voices playing at once.
+	PeakVoices int `json:"peakVoices"`
+
+	// Loads is the number of modules loaded or that failed to load,
+	// and LoadTime and MaxLoadTime the total and longest time
+	// libmikmod took to load one.
+	Loads       uint64        `json:"loads"`
+	LoadTime    time.Duration `json:"loadTime"`
+	MaxLoadTime time.Duration `json:"maxLoadTime"`
+}
+
+// stats holds the statistics returned by ReadStats.
+var stats struct {
+	since         atomic.Int64
+	ticks         atomic.Uint32 // playerTicks at the start
+	updates       atomic.Uint64
+	updateTime    atomic.Int64
+	maxUpdateTime atomic.Int64
+	peakVoices    atomic.Int64
+	loads         atomic.Uint64
+	loadTime      atomic.Int64
+	maxLoadTime   atomic.Int64
+}
+
+// ReadStats returns the statistics of the current session.  Ticks
+// wrap around every 2^32.
+func ReadStats() Stats {
+	s := Stats{
+		Since:         time.Unix(0, stats.since.Load()),
+		Ticks:         uint64(playerTicks() - stats.ticks.Load()),
+		Updates:       stats.updates.Load(),
+		MaxUpdateTime: time.Duration(stats.maxUpdateTime.Load()),
+		PeakVoices:    int(stats.peakVoices.Load()),
+		Loads:         stats.loads.Load(),
+		LoadTime:      time.Duration(stats.loadTime.Load()),
+		MaxLoadTime:   time.Duration(stats.maxLoadTime.Load()),
+	}
+	if s.Updates > 0 {
+		s.AverageUpdateTime = time.Duration(stats.updateTime.Load() / int64(s.Updates))
+	}
+	return s
+}
+
+// ResetStats starts a new session, clearing the statistics.  It is
+// called by Init.
+func ResetStats() {
+	stats.since.Store(time.Now().UnixNano())
+	stats.ticks.Store(playerTicks())
+	stats.updates.Store(0)
+	stats.updateTime.Store(0)
+	stats.maxUpdateTime.Store(0)
+	stats.peakVoices.Store(0)
+	stats.loads.Store(0)
+	stats.loadTime.Store(0)
+	stats.maxLoadTime.Store(0)
+}
+
+// recordLoad records a module load that took d.
+func recordLoad(d time.Duration) {
+	stats.loads.Add(1)
+	stats.loadTime.Add(int64(d))
+	storeMax(&stats.maxLoadTime, int64(d))
+}
+
+// storeMax stores v in x if it is larger than the value held.
+func storeMax(x *atomic.Int64, v int64) {
+	for {
+		old := x.Load()
+		if v <= old || x.CompareAndSwap(old, v) {
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "time"
+
+// PlayerStatus is a snapshot of the player's state.
+type PlayerStatus struct {
+	// Playing is true if a module is playing, even if paused.
+	Playing bool
+
+	// Paused is true if the playing module is paused.
+	Paused bool
+
+	// Suspended is true if the device was suspended by Suspend.
+	Suspended bool
+
+	// Module is the playing module, or nil if none is.
+	Module *Module
+
+	// Title is the title of the playing module.
+	Title string
+
+	// Elapsed is the playing time of the module so far.
+	Elapsed time.Duration
+
+	// Position is the index of the current song position, and Row
+	// the current row in the pattern it plays.
+	Position int
+	Row      int
+
+	// Speed is the number of ticks per row, and Tempo the number of
+	// beats per minute.
+	Speed int
+	Tempo int
+
+	// ActiveChannels is the number of channels in use, including
+	// those used by new note actions.
+	ActiveChannels int
+
+	// Volume is MikMod's global volume, and MusicVolume the volume
+	// of the music after fades and gain, both between 0 and 1.
+	// Level is the music level set by fades, and Gain the playing
+	// module's gain.
+	Volume      float64
+	MusicVolume float64
+	Level       float64
+	Gain        float64
+
+	// Format is the format of MikMod's output.
+	Format Format
+
+	// DriverName and DriverAlias identify the output driver, if
+	// MikMod is initialized.
+	DriverName  string
+	DriverAlias string
+}
+
+// Status returns a consistent snapshot of the player's state, captured
+// in one go while the player is locked.
+func Status() PlayerStatus {
+	mu.Lock()
+	defer mu.Unlock()
+	return call(status)
+}
+
+// status is like Status, but expects mu to be locked.
+func status() PlayerStatus {
+	C.MikMod_Lock()
+	defer C.MikMod_Unlock()
+
+	s := PlayerStatus{
+		Suspended:   suspended != nil,
+		Volume:      float64(C.md_volume) / 128,
+		MusicVolume: float64(C.md_musicvolume) / 128,
+		Level:       musicLevel(),
+		Format:      currentFormat(),
+	}
+	if initCount > 0 && C.md_driver != nil {
+		s.DriverName, s.DriverAlias = driverStrings(C.md_driver)
+	}
+	// Player_Active and Player_Paused lock MikMod themselves, so
+	// read the state they report directly.
+	m := playingModule()
+	if m == nil || int(m.module.sngpos) >= int(m.module.numpos) {
+		return s
+	}
+	s.Playing = true
+	s.Paused = goBool(m.module.forbid)
+	s.Module = m
+	s.Title = m.Title()
+	s.Elapsed = m.Elapsed()
+	s.Position = int(m.module.sngpos)
+	s.Row = int(m.module.patpos)
+	s.Speed = m.Speed()
+	s.Tempo = m.Tempo()
+	s.ActiveChannels = int(m.module.totalchn)
+	s.Gain = m.Gain()
+	return s
+}
+
+// statusDriver caches the name and alias of the driver last reported
+// by Status, so that polling Status does not convert them each time.
+// It is guarded by mu.
+var statusDriver struct {
+	driver      *C.MDRIVER
+	name, alias string
+}
+
+// driverStrings returns the name and alias of driver.  It expects mu
+// to be locked.
+func driverStrings(driver *C.MDRIVER) (string, string) {
+	if statusDriver.driver != driver {
+		d := &Driver{driver: driver}
+		statusDriver.driver, statusDriver.name, statusDriver.alias = driver, d.Name(), d.Alias()
+	}
+	return statusDriver.name, statusDriver.alias
+}
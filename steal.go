@@ -0,0 +1,83 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import "sync/atomic"
+
+// StealPolicy selects the sound effect cut off by PlaySample when all
+// the voices reserved for sound effects are busy.  Critical sound
+// effects are never cut off.
+type StealPolicy int
+
+const (
+	// StealDefault stands for the policy set by SetStealPolicy in
+	// SampleOptions, and for StealOldest in SetStealPolicy.
+	StealDefault StealPolicy = iota
+
+	// StealOldest cuts off the sound effect started first, as
+	// libmikmod's Sample_Play does.
+	StealOldest
+
+	// StealQuietest cuts off the sound effect with the lowest output
+	// level.
+	StealQuietest
+
+	// StealLowestPriority cuts off the sound effect with the lowest
+	// priority, provided it is not higher than the new one's, and
+	// the oldest of those with the same priority.
+	StealLowestPriority
+
+	// StealNone makes PlaySample fail rather than cut off a sound
+	// effect.
+	StealNone
+)
+
+// stealPolicy holds the policy set by SetStealPolicy.
+var stealPolicy atomic.Int32
+
+// SetStealPolicy sets the policy applied by PlaySample when its
+// options do not specify one.
+func SetStealPolicy(p StealPolicy) { stealPolicy.Store(int32(p)) }
+
+// pickVoice returns a voice reserved for sound effects to play a sound
+// effect of priority priority on, a free one if possible, or one
+// stolen according to policy, or -1 if there is none.  It expects
+// sfxMu to be locked and to be called on the actor.
+func pickVoice(policy StealPolicy, priority int) int {
+	first, n := int(C.md_sngchn), int(C.md_sfxchn)
+	victim := -1
+	var best sfxVoice
+	var bestLevel C.ULONG
+	for voice := first; voice < first+n; voice++ {
+		if C.Voice_Stopped(C.SBYTE(voice)) != 0 {
+			return voice
+		}
+		v, ok := sfxVoices[voice]
+		if ok && v.critical {
+			continue
+		}
+		level := C.Voice_RealVolume(C.SBYTE(voice))
+		better := victim < 0
+		switch policy {
+		case StealQuietest:
+			better = better || level < bestLevel
+		case StealLowestPriority:
+			if v.priority > priority {
+				continue
+			}
+			better = better || v.priority < best.priority ||
+				v.priority == best.priority && v.started.Before(best.started)
+		case StealNone:
+			better = false
+		default:
+			better = better || v.started.Before(best.started)
+		}
+		if better {
+			victim, best, bestLevel = voice, v, level
+		}
+	}
+	return victim
+}
@@ -0,0 +1,105 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"io"
+)
+
+// StreamOptions controls the behavior of a Stream.
+type StreamOptions struct {
+	// Loop makes the stream honor the module's loop settings, in
+	// which case it may never end.  By default looping is disabled
+	// and the stream ends with the song.
+	Loop bool
+
+	// Filters are applied, in order, to the PCM data before it is
+	// returned by Read.  They are only applied to 16-bit output.
+	Filters []Filter
+
+	// Speed, if not 0, changes the playback speed without changing
+	// the pitch, e.g. 0.8 to practice along with a module at a
+	// slower tempo.  It requires 16-bit output.
+	Speed float64
+
+	// SampleRate, if not 0, is the sample rate to convert the PCM
+	// data to, in Hz, e.g. to feed a device running at 48000 Hz, and
+	// Resample the quality of the conversion.  It requires 16-bit
+	// output.
+	SampleRate int
+	Resample   ResampleQuality
+}
+
+// Stream is an io.Reader yielding the PCM data of a playing module.
+// The data is mixed on demand as it is read, rather than pushed to
+// an output driver by the update loop.  Remember to Close it when
+// done.
+type Stream struct {
+	format  Format
+	stop    func()
+	filters filterChain
+
+	// read reads the PCM data, changed as StreamOptions requested.
+	read func(p []byte) (int, error)
+}
+
+// NewStream starts playing a module as a stream.  Any module currently
+// playing is stopped, and the module should not be played by other
+// means while the stream is open.
+func NewStream(m *Module, opts StreamOptions) (*Stream, error) {
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+	if err := m.usable(); err != nil {
+		return nil, err
+	}
+	s := &Stream{filters: filterChain{filters: opts.Filters}}
+	var err error
+	s.read, s.format, err = streamReader(s.mix, currentFormat(), opts.Speed, opts.SampleRate, opts.Resample)
+	if err != nil {
+		return nil, err
+	}
+	s.stop = startPull(m, opts.Loop)
+	return s, nil
+}
+
+// Format returns the format of the PCM data yielded by the stream.
+func (s *Stream) Format() Format { return s.format }
+
+// Read reads up to len(p) bytes of PCM data, always a whole number of
+// sample frames.  It returns io.EOF when the song has ended.
+func (s *Stream) Read(p []byte) (int, error) {
+	if s.stop == nil {
+		return 0, errStreamClosed
+	}
+	n, err := s.read(p)
+	s.filters.apply(p[:n], s.format)
+	return n, err
+}
+
+// mix mixes up to len(p) bytes of PCM data.
+func (s *Stream) mix(p []byte) (int, error) {
+	if !IsPlaying() {
+		return 0, io.EOF
+	}
+	if len(p) < currentFormat().FrameSize() {
+		return 0, io.ErrShortBuffer
+	}
+	return mix(p), nil
+}
+
+// Close stops the stream's module.
+func (s *Stream) Close() error {
+	if s.stop == nil {
+		return errStreamClosed
+	}
+	s.stop()
+	s.stop = nil
+	return nil
+}
+
+var errStreamClosed = errors.New("mikmod: stream is closed")
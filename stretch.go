@@ -0,0 +1,217 @@
+package mikmod
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Parameters of the time stretcher, in seconds: the length of the
+// segments overlapped, and how far from its nominal position each
+// segment may be taken to line up with the previous one.
+const (
+	stretchSegment   = 0.04
+	stretchTolerance = 0.01
+)
+
+// stretchStride is the step between the samples compared when lining
+// up segments, trading accuracy for speed.
+const stretchStride = 4
+
+var (
+	errInvalidSpeed  = errors.New("mikmod: speed must be positive")
+	errStretchFormat = errors.New("mikmod: changing speed needs 16-bit output")
+)
+
+// stretcher changes the speed of 16-bit PCM data without changing its
+// pitch, using waveform-similarity overlap-add: the output is built
+// from overlapping windowed segments of the input, taken at intervals
+// scaled by the speed, each shifted slightly to line up with the
+// previous segment's waveform.
+type stretcher struct {
+	read     func(b []byte) (int, error)
+	channels int
+	speed    float64
+
+	// n is the segment length and hop the output step, half of it,
+	// in frames, and tolerance the largest shift of a segment.
+	n, hop, tolerance int
+	window            []float64
+
+	// in holds the interleaved input not consumed yet, pos the
+	// nominal position of the next segment in it, and next the
+	// position of the natural continuation of the last segment, or -1
+	// before the first one.
+	in   []float64
+	pos  float64
+	next int
+
+	// overlap is the second half of the last segment, to be added to
+	// the next one, and out the output not returned yet.
+	overlap []float64
+	out     []int16
+
+	raw []byte
+	eof bool
+
+	// end is the number of input frames left once the source ended,
+	// not counting the silence appended to flush the last segments.
+	end int
+}
+
+// newStretcher returns a stretcher reading PCM data in format f from
+// read and playing it speed times faster.
+func newStretcher(read func(b []byte) (int, error), f Format, speed float64) (*stretcher, error) {
+	if speed <= 0 || math.IsNaN(speed) || math.IsInf(speed, 0) {
+		return nil, errInvalidSpeed
+	}
+	if f.BitsPerSample != 16 {
+		return nil, errStretchFormat
+	}
+	n := int(stretchSegment*float64(f.SampleRate)) &^ 1
+	s := &stretcher{
+		read:      read,
+		channels:  f.Channels,
+		speed:     speed,
+		n:         n,
+		hop:       n / 2,
+		tolerance: int(stretchTolerance * float64(f.SampleRate)),
+		window:    make([]float64, n),
+		next:      -1,
+		overlap:   make([]float64, n/2*f.Channels),
+		raw:       make([]byte, renderBufferSize-renderBufferSize%f.FrameSize()),
+	}
+	// A periodic Hann window sums to 1 when overlapped by half.
+	for i := range s.window {
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	return s, nil
+}
+
+// Read reads up to len(b) bytes of stretched PCM data, always a whole
+// number of sample frames.
+func (s *stretcher) Read(b []byte) (int, error) {
+	frameSize := 2 * s.channels
+	if len(b) < frameSize {
+		return 0, io.ErrShortBuffer
+	}
+	want := len(b) / 2
+	want -= want % s.channels
+	for len(s.out) < want {
+		ok, err := s.step()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+	}
+	if len(s.out) == 0 {
+		return 0, io.EOF
+	}
+	n := min(want, len(s.out))
+	for i, v := range s.out[:n] {
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(v))
+	}
+	s.out = s.out[:copy(s.out, s.out[n:])]
+	return n * 2, nil
+}
+
+// frames returns the number of input frames buffered.
+func (s *stretcher) frames() int { return len(s.in) / s.channels }
+
+// fill reads input until at least n frames are buffered, or the source
+// ends, in which case it appends the silence needed to flush the last
+// segments.
+func (s *stretcher) fill(n int) error {
+	for s.frames() < n && !s.eof {
+		m, err := s.read(s.raw)
+		for i := 0; i+1 < m; i += 2 {
+			s.in = append(s.in, float64(int16(binary.LittleEndian.Uint16(s.raw[i:]))))
+		}
+		if err == io.EOF || err == nil && m == 0 {
+			s.eof = true
+			s.end = s.frames()
+			s.in = append(s.in, make([]float64, (s.n+s.tolerance)*s.channels)...)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// step adds a segment to the output, and returns false once the input
+// is exhausted and the last segment flushed.
+func (s *stretcher) step() (bool, error) {
+	if err := s.fill(int(s.pos) + s.tolerance + s.n); err != nil {
+		return false, err
+	}
+	if s.eof && int(s.pos) >= s.end {
+		if s.overlap == nil {
+			return false, nil
+		}
+		s.emit(s.overlap)
+		s.overlap = nil
+		return true, nil
+	}
+	start := s.align()
+	c := s.channels
+	segment := s.in[start*c : (start+s.n)*c]
+	half := s.hop * c
+	for i := range half {
+		s.out = append(s.out, clampInt16(s.overlap[i]+segment[i]*s.window[i/c]))
+	}
+	for i := range s.overlap {
+		s.overlap[i] = segment[half+i] * s.window[s.hop+i/c]
+	}
+	s.next = start + s.hop
+	s.pos += float64(s.hop) * s.speed
+	s.discard()
+	return true, nil
+}
+
+// align returns the start of the next segment: the position within
+// the tolerance of its nominal position whose waveform best matches
+// the natural continuation of the previous segment.
+func (s *stretcher) align() int {
+	nominal := int(s.pos)
+	if s.next < 0 {
+		return nominal
+	}
+	c := s.channels
+	target := s.in[s.next*c:]
+	best, bestScore := nominal, math.Inf(-1)
+	for k := max(nominal-s.tolerance, 0); k <= nominal+s.tolerance; k++ {
+		candidate := s.in[k*c:]
+		score := 0.0
+		for i := 0; i < s.hop*c; i += stretchStride * c {
+			score += target[i] * candidate[i]
+		}
+		if score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best
+}
+
+// discard drops the input frames that no later segment can use.
+func (s *stretcher) discard() {
+	drop := min(s.next, int(s.pos)-s.tolerance)
+	if drop <= 0 {
+		return
+	}
+	s.in = s.in[:copy(s.in, s.in[drop*s.channels:])]
+	s.pos -= float64(drop)
+	s.next -= drop
+	if s.eof {
+		s.end -= drop
+	}
+}
+
+// emit appends interleaved samples to the output.
+func (s *stretcher) emit(samples []float64) {
+	for _, v := range samples {
+		s.out = append(s.out, clampInt16(v))
+	}
+}
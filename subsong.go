@@ -0,0 +1,82 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"time"
+)
+
+// Subsong is one of the independent songs of a module, such as the
+// tunes of a game soundtrack stored in one file, separated by end
+// markers or reachable from no other position.
+type Subsong struct {
+	// Start is the song position the subsong starts from, and
+	// Positions the positions it plays, in the order they are first
+	// played.
+	Start     int
+	Positions []int
+
+	// Duration is the duration of a pass through the subsong,
+	// estimated from its speed and tempo changes.
+	Duration time.Duration
+}
+
+// Subsongs returns the subsongs of the module, the first one being the
+// song played by default.  Most modules have a single one.
+func (m *Module) Subsongs() []Subsong {
+	orders := m.Orders()
+	walked := make([]bool, len(orders))
+	var subsongs []Subsong
+	for start, pat := range orders {
+		if walked[start] || pat >= m.NumPatterns() {
+			continue
+		}
+		s := Subsong{Start: start}
+		timing := m.initialTiming()
+		m.walkSong(start, func(pos int, r PatternRow) {
+			if !walked[pos] {
+				walked[pos] = true
+				s.Positions = append(s.Positions, pos)
+			}
+			s.Duration += timing.row(r)
+		})
+		if len(s.Positions) > 0 {
+			subsongs = append(subsongs, s)
+		}
+	}
+	return subsongs
+}
+
+var errInvalidSubsong = errors.New("mikmod: invalid subsong")
+
+// PlaySubsong plays subsong i of module m, as returned by Subsongs,
+// from its start.  If the module loops, the subsong starts over when
+// it ends rather than the module's main song.
+func PlaySubsong(m *Module, i int) error {
+	if err := m.usable(); err != nil {
+		return err
+	}
+	subsongs := m.Subsongs()
+	if i < 0 || i >= len(subsongs) {
+		return errInvalidSubsong
+	}
+	if err := Play(m); err != nil {
+		return err
+	}
+	start := subsongs[i].Start
+	do(func() {
+		if i > 0 {
+			C.MikMod_Lock()
+			m.module.reppos = C.UWORD(start)
+			C.MikMod_Unlock()
+		}
+		// Going through position 0 resets the player's state.
+		C.Player_SetPosition(0)
+		C.Player_SetPosition(C.UWORD(start))
+	})
+	return nil
+}
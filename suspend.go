@@ -0,0 +1,77 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+// suspension records what was playing when the device was suspended.
+type suspension struct {
+	module *Module
+	order  int
+}
+
+// suspended is the state saved by Suspend, or nil if the device is not
+// suspended.  It is guarded by mu.
+var suspended *suspension
+
+// Suspend stops the update loop and the output driver, releasing the
+// audio device, e.g. when the application loses focus or the machine
+// goes to sleep.  Call ResumeDevice to reopen the driver and continue
+// the module that was playing from the position where it stopped.
+// Play, Swap and Stop discard a suspension.
+func Suspend() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return ErrNotInitialized
+	}
+	if suspended != nil {
+		return nil
+	}
+	s := &suspension{module: playingModule()}
+	if s.module != nil {
+		s.order = Position()
+	}
+	stop()
+	do(func() { C.MikMod_DisableOutput() })
+	suspended = s
+	logger().Info("mikmod: suspended")
+	return nil
+}
+
+// ResumeDevice reopens the output driver after Suspend and resumes the
+// module that was playing.  It does nothing if the device is not
+// suspended.
+func ResumeDevice() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return ErrNotInitialized
+	}
+	s := suspended
+	if s == nil {
+		return nil
+	}
+	// The device may have changed while suspended, so reopen the
+	// driver rather than only restarting it.
+	if err := reset(); err != nil {
+		return err
+	}
+	suspended = nil
+	logger().Info("mikmod: resumed device")
+	if s.module == nil {
+		return nil
+	}
+	play(s.module)
+	SetPosition(s.order)
+	return nil
+}
+
+// IsSuspended returns true if the device was suspended by Suspend and
+// not resumed yet.
+func IsSuspended() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return suspended != nil
+}
@@ -0,0 +1,162 @@
+package mikmod
+
+/*
+#include <stdlib.h>
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+var errUnknownDriver = errors.New("mikmod: no registered driver has that alias")
+
+// SwitchDriver moves playback to the registered driver with alias
+// name, e.g. when a laptop is docked, passing it args as DriverArgs.
+// The module playing, if any, resumes at the row where it was.
+//
+// Changing drivers shuts the previous one down along with the
+// samples it holds, so the loaded modules and sound effects are
+// loaded again from the data or files they were loaded from; their
+// MuteInstrument and RemapInstrument changes are lost, and they should
+// not be used by other goroutines until SwitchDriver returns.  Modules
+// loaded by LoadModuleFromSliceNoClone cannot be loaded again, and are
+// left closed.  If the new driver cannot be initialized, the previous
+// one is restored and the error returned.
+func SwitchDriver(name string, args string) error {
+	libMu.Lock()
+	defer libMu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	if initCount == 0 {
+		return ErrNotInitialized
+	}
+	device := driverFromAlias(name)
+	if device == 0 {
+		return fmt.Errorf("%w: %s", errUnknownDriver, name)
+	}
+	cur := playingModule()
+	var pos, row int
+	if cur != nil {
+		pos, row = Position(), Row()
+	}
+	suspended = nil
+	stop()
+
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	for m := range liveModules {
+		do(func() {
+			m.clearInstrumentEdits()
+			C.Player_Free(m.module)
+		})
+	}
+	for s := range liveSamples {
+		do(func() { C.Sample_Free(s.sample) })
+	}
+
+	prevOptions, prevDevice := options, C.md_device
+	options.Driver, options.DriverPreference, options.DriverArgs = nil, []string{name}, args
+	do(func() { C.md_device = C.UWORD(device) })
+	err := reset()
+	if err != nil {
+		logger().Info("mikmod: switching driver failed", "driver", name, "err", err)
+		options = prevOptions
+		do(func() { C.md_device = prevDevice })
+		if rerr := reset(); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+	}
+
+	for m := range liveModules {
+		if rerr := m.reload(); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+	}
+	for s := range liveSamples {
+		if rerr := s.reload(); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+	}
+	if cur != nil && cur.module != nil {
+		play(cur)
+		seek(cur, pos, row)
+	}
+	return err
+}
+
+// reload loads the module again from the data or file it was loaded
+// from, after its libmikmod module was freed, keeping its settings.  A
+// file is passed through the translators again, as when it was loaded.
+// It leaves m closed if loading fails.
+func (m *Module) reload() error {
+	loop, fadeout := m.module.loop, m.module.fadeout
+	data := m.data
+	if data == nil && m.filename != "" && hasTranslators() {
+		var err error
+		if data, err = translateFile(m.filename); err != nil {
+			m.module = nil
+			delete(liveModules, m)
+			return err
+		}
+	}
+	var err error
+	do(func() {
+		var module *C.MODULE
+		switch {
+		case data != nil:
+			module, _ = loadMem(data)
+		case m.filename == "":
+			err = errNoData
+		default:
+			fn := mikmodString(m.filename)
+			defer C.free(unsafe.Pointer(fn))
+			module = C.Player_Load(fn, 128, C.BOOL(0))
+		}
+		m.module = module
+		if module == nil && err == nil {
+			err = mikmodError()
+		}
+	})
+	if err != nil {
+		delete(liveModules, m)
+		return loadError(m.filename, -1, err)
+	}
+	m.module.loop, m.module.fadeout = loop, fadeout
+	protrackerMu.Lock()
+	defer protrackerMu.Unlock()
+	if m.ptMode != ProTrackerDefault {
+		do(func() {
+			m.ptSaved = m.protrackerSettings()
+			m.applyProTrackerMode()
+		})
+	}
+	return nil
+}
+
+// reload loads the sound effect again from the data or file it was
+// loaded from, after its libmikmod sample was freed.  It leaves s
+// closed if loading fails.
+func (s *Sample) reload() error {
+	var err error
+	do(func() {
+		if s.data != nil {
+			s.sample = C.Sample_LoadMem((*C.char)(unsafe.Pointer(&s.data[0])), C.int(len(s.data)))
+		} else {
+			fn := mikmodString(s.filename)
+			defer C.free(unsafe.Pointer(fn))
+			s.sample = C.Sample_Load(fn)
+		}
+		if s.sample == nil {
+			err = mikmodError()
+		}
+	})
+	if err != nil {
+		delete(liveSamples, s)
+		return fmt.Errorf("mikmod: loading sample %s: %w", s.filename, err)
+	}
+	return nil
+}
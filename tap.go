@@ -0,0 +1,51 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+
+extern void goTap(unsigned char *data, size_t len);
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+var (
+	tapMu sync.Mutex
+	tap   func(b []byte)
+)
+
+// SetTap registers fn to receive a copy of every buffer mixed by
+// MikMod's software mixer, as it is sent to the output driver or read
+// from a Stream, in the format returned by OutputFormat.  fn is called
+// on the mixing goroutine with the mixer locked, so it must return
+// quickly and must not call back into this package.  Passing nil
+// removes the tap.
+func SetTap(fn func(b []byte)) {
+	tapMu.Lock()
+	tap = fn
+	tapMu.Unlock()
+	do(func() { enableTap(fn != nil) })
+}
+
+// enableTap installs the mixer callback calling the tap, or removes it
+// if on is false.
+func enableTap(on bool) {
+	if on {
+		C.VC_SetCallback(C.MikMod_callback_t(C.goTap))
+	} else {
+		C.VC_SetCallback(nil)
+	}
+}
+
+//export goTap
+func goTap(data *C.uchar, n C.size_t) {
+	tapMu.Lock()
+	fn := tap
+	tapMu.Unlock()
+	if fn != nil {
+		fn(C.GoBytes(unsafe.Pointer(data), C.int(n)))
+	}
+}
@@ -0,0 +1,92 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"sync"
+	"time"
+)
+
+// Ticker wakes up the update loop, as a time.Ticker does by default.
+// Options.NewTicker can supply another implementation, e.g. one driven
+// by a game's frame loop, or a ManualTicker in tests.
+type Ticker interface {
+	// C returns the channel on which ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop stops the ticker.  No more ticks are delivered.
+	Stop()
+}
+
+// timeTicker is a Ticker backed by a time.Ticker.
+type timeTicker struct{ t *time.Ticker }
+
+func (t timeTicker) C() <-chan time.Time { return t.t.C }
+func (t timeTicker) Stop()               { t.t.Stop() }
+
+// newTicker returns the ticker of the update loop, which wakes up
+// every period.  It expects mu to be locked.
+func (opts Options) newTicker(period time.Duration) Ticker {
+	if opts.NewTicker != nil {
+		return opts.NewTicker(period)
+	}
+	return timeTicker{time.NewTicker(period)}
+}
+
+// ManualTicker is a Ticker that only ticks when told to, so that tests
+// can advance playback deterministically, without sleeping.  Module
+// playing time then follows the number of ticks rather than the wall
+// clock, as long as output goes to a driver that does not pace itself,
+// such as DriverNoSound.
+type ManualTicker struct {
+	c    chan time.Time
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+// NewManualTicker returns a ManualTicker, stopped until the update
+// loop starts with it.  Set Options.NewTicker to its NewTicker method.
+func NewManualTicker() *ManualTicker {
+	t := &ManualTicker{c: make(chan time.Time), stop: make(chan struct{})}
+	close(t.stop)
+	return t
+}
+
+// NewTicker restarts the ticker and returns it, ignoring period.
+func (t *ManualTicker) NewTicker(period time.Duration) Ticker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stop = make(chan struct{})
+	return t
+}
+
+// C returns the channel on which ticks are delivered.
+func (t *ManualTicker) C() <-chan time.Time { return t.c }
+
+// Stop stops the ticker.  Ticks are discarded until it is restarted.
+func (t *ManualTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+}
+
+// Tick delivers n ticks, each servicing UpdateBatch update periods.
+// It returns once the update loop received the last one, and so
+// finished the updates of the previous ones, or once the ticker is
+// stopped.
+func (t *ManualTicker) Tick(n int) {
+	t.mu.Lock()
+	stop := t.stop
+	t.mu.Unlock()
+	for range n {
+		select {
+		case t.c <- time.Now():
+		case <-stop:
+			return
+		}
+	}
+}
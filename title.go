@@ -0,0 +1,65 @@
+package mikmod
+
+/*
+#include <stdlib.h>
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// ReadTitle returns the title of the module in the file designated by
+// filename without loading it.  libmikmod loads all sample data into
+// the mixer when loading a module, and cannot defer it, so scanning a
+// collection of large modules for their titles is much faster and
+// lighter with ReadTitle than with LoadModuleFromFile.  Translators are
+// not consulted.  To load large modules with a lower peak memory, see
+// LoadModuleFromFileMapped.
+func ReadTitle(filename string) (string, error) {
+	if err := checkInitialized(); err != nil {
+		return "", err
+	}
+	fn := mikmodString(filename)
+	defer C.free(unsafe.Pointer(fn))
+	title, err := readTitle(func() *C.CHAR { return C.Player_LoadTitle(fn) })
+	if err != nil {
+		return "", fmt.Errorf("mikmod: reading title of %s: %w", filename, err)
+	}
+	return title, nil
+}
+
+// ReadTitleFromSlice is like ReadTitle, but reads the module from b.
+func ReadTitleFromSlice(b []byte) (string, error) {
+	if err := checkInitialized(); err != nil {
+		return "", err
+	}
+	if len(b) == 0 {
+		return "", ErrNotAModule
+	}
+	return readTitle(func() *C.CHAR {
+		return C.Player_LoadTitleMem((*C.char)(unsafe.Pointer(&b[0])), C.int(len(b)))
+	})
+}
+
+// readTitle reads a module's title using load, decoding it as the
+// text of a module loaded now would be.
+func readTitle(load func() *C.CHAR) (string, error) {
+	var (
+		title string
+		err   error
+	)
+	do(func() {
+		s := load()
+		if s == nil {
+			err = mikmodError()
+			return
+		}
+		defer C.MikMod_free(unsafe.Pointer(s))
+		b := []byte(C.GoString((*C.char)(s)))
+		title = decodeText(b, CurrentCharset(), "")
+	})
+	return title, err
+}
@@ -0,0 +1,154 @@
+package mikmod
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// UntrustedOptions limits what LoadUntrusted accepts.
+type UntrustedOptions struct {
+	// MaxSize is the largest module accepted, in bytes.  If 0,
+	// DefaultMaxUntrustedSize is used.
+	MaxSize int
+
+	// Timeout is how long loading may take.  If 0,
+	// DefaultUntrustedTimeout is used.
+	Timeout time.Duration
+
+	// Formats lists the formats accepted.  If empty, any format
+	// detected by Module.Type is accepted, but data in an unknown
+	// format is rejected.
+	Formats []ModType
+}
+
+// Defaults for UntrustedOptions.
+const (
+	DefaultMaxUntrustedSize = 16 << 20
+	DefaultUntrustedTimeout = 5 * time.Second
+)
+
+// Maximum number of channels and positions of an acceptable module.
+const (
+	maxUntrustedChannels  = 64
+	maxUntrustedPositions = 256
+)
+
+// maxPendingUntrusted is the number of untrusted loads that may run at
+// the same time, including loads that timed out but did not return
+// yet.
+const maxPendingUntrusted = 4
+
+// untrustedSlots limits the number of pending untrusted loads.
+var untrustedSlots = make(chan struct{}, maxPendingUntrusted)
+
+// Errors returned by LoadUntrusted.
+var (
+	ErrTooLarge          = errors.New("mikmod: module is too large")
+	ErrUnsupportedFormat = errors.New("mikmod: unsupported module format")
+	ErrLoadTimeout       = errors.New("mikmod: loading the module timed out")
+	ErrInvalidModule     = errors.New("mikmod: invalid module")
+)
+
+// LoadUntrusted loads a module from data supplied by an untrusted
+// party, e.g. a user upload.  It rejects data that is too large or in
+// a format not accepted by opts, gives up on loads that take too long,
+// converts panics in translators into errors, and rejects modules whose
+// structure is implausible.
+//
+// libmikmod's loaders run in-process, so a crash in one still takes
+// the program down; services that must survive any input should load
+// uploads in a separate process, e.g. cmd/modinfo.  A load that timed
+// out keeps running in the background until it returns, when the
+// module is closed.
+func LoadUntrusted(data []byte, opts UntrustedOptions) (*Module, error) {
+	maxSize := opts.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxUntrustedSize
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultUntrustedTimeout
+	}
+	if len(data) > maxSize {
+		return nil, ErrTooLarge
+	}
+	if err := checkInitialized(); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case untrustedSlots <- struct{}{}:
+	case <-timer.C:
+		return nil, ErrLoadTimeout
+	}
+
+	type result struct {
+		m   *Module
+		err error
+	}
+	results := make(chan result, 1)
+	abandoned := make(chan struct{})
+	go func() {
+		defer func() { <-untrustedSlots }()
+		m, err := loadUntrusted(slices.Clone(data), opts)
+		select {
+		case results <- result{m, err}:
+		case <-abandoned:
+			if m != nil {
+				m.Close()
+			}
+		}
+	}()
+	select {
+	case r := <-results:
+		return r.m, r.err
+	case <-timer.C:
+		close(abandoned)
+		return nil, ErrLoadTimeout
+	}
+}
+
+// loadUntrusted loads a module from data and checks it against opts.
+func loadUntrusted(data []byte, opts UntrustedOptions) (m *Module, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			m, err = nil, fmt.Errorf("%w: loader panicked: %v", ErrInvalidModule, p)
+		}
+	}()
+	data, err = translate(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrInvalidModule
+	}
+	t := detectModType(data, "")
+	if t == ModTypeUnknown || len(opts.Formats) > 0 && !slices.Contains(opts.Formats, t) {
+		return nil, ErrUnsupportedFormat
+	}
+	m, err = loadModule(data, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPlausible(m); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// checkPlausible returns ErrInvalidModule if m's structure is beyond
+// what any tracker produces.
+func checkPlausible(m *Module) error {
+	switch {
+	case m.NumChannels() < 1 || m.NumChannels() > maxUntrustedChannels:
+		return fmt.Errorf("%w: %d channels", ErrInvalidModule, m.NumChannels())
+	case m.NumPositions() < 1 || m.NumPositions() > maxUntrustedPositions:
+		return fmt.Errorf("%w: %d positions", ErrInvalidModule, m.NumPositions())
+	}
+	return nil
+}
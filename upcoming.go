@@ -0,0 +1,91 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"time"
+	"unsafe"
+)
+
+// UpcomingNote is a note the playing module is about to play.
+type UpcomingNote struct {
+	// Channel is the channel playing the note, and Note and
+	// Instrument are as in PatternCell.
+	Channel    int
+	Note       int
+	Instrument int
+
+	// Position and Row are where the note is in the song.
+	Position int
+	Row      int
+
+	// In is the time until the player reaches the note.  Add
+	// OutputLatency to find out when it is heard.
+	In time.Duration
+}
+
+// UpcomingNotes returns the notes in the rows of the playing module
+// following the current row, up to rows rows ahead, in order, e.g. to
+// drive the note highway of a rhythm game.  Times assume that the
+// song plays on in order at its current speed and tempo, so pattern
+// jumps and tempo changes within the coming rows are not anticipated.
+// It returns nil if no module is playing.
+func UpcomingNotes(rows int) []UpcomingNote {
+	mu.Lock()
+	defer mu.Unlock()
+	m := playingModule()
+	if m == nil {
+		return nil
+	}
+	var pos, row, tick, speed, tempo int
+	do(func() {
+		mod := m.module
+		pos, row, tick = int(mod.sngpos), int(mod.patpos), int(mod.vbtick)
+		speed, tempo = int(mod.sngspd), int(mod.bpm)
+	})
+	if tempo == 0 {
+		return nil
+	}
+	tickDuration := 5 * time.Second / time.Duration(2*tempo)
+	orders := m.Orders()
+	var notes []UpcomingNote
+	for ahead := 1; ahead <= rows; ahead++ {
+		if row++; row >= m.patternLength(orders, pos) {
+			if pos, row = pos+1, 0; pos >= len(orders) {
+				if !m.Loop() {
+					break
+				}
+				pos = int(m.module.reppos)
+			}
+			if m.patternLength(orders, pos) == 0 {
+				break
+			}
+		}
+		in := time.Duration(ahead*speed-tick) * tickDuration
+		for _, r := range m.PatternRows(orders[pos], row, 1) {
+			for ch, c := range r.Cells {
+				if c.Note >= 0 {
+					notes = append(notes, UpcomingNote{
+						Channel:    ch,
+						Note:       c.Note,
+						Instrument: c.Instrument,
+						Position:   pos,
+						Row:        row,
+						In:         in,
+					})
+				}
+			}
+		}
+	}
+	return notes
+}
+
+// patternLength returns the number of rows of the pattern at song
+// position pos, or 0 if there is none.
+func (m *Module) patternLength(orders []int, pos int) int {
+	mod := m.module
+	if pos < 0 || pos >= len(orders) || orders[pos] >= int(mod.numpat) || mod.pattrows == nil {
+		return 0
+	}
+	return int(unsafe.Slice(mod.pattrows, mod.numpat)[orders[pos]])
+}
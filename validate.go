@@ -0,0 +1,111 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// maxValidNote is the number of notes libmikmod plays, 10 octaves from
+// C-0.
+const maxValidNote = 10 * 12
+
+// IssueKind is the kind of problem Validate found.
+type IssueKind int
+
+const (
+	// IssueMissingPattern is a song position playing a pattern the
+	// module does not have.
+	IssueMissingPattern IssueKind = iota
+
+	// IssueInvalidLoop is a looping sample whose loop is empty or
+	// extends past its end.
+	IssueInvalidLoop
+
+	// IssueNoteRange is a note libmikmod cannot play.
+	IssueNoteRange
+
+	// IssueInstrumentRange is a note played with an instrument or
+	// sample the module does not have.
+	IssueInstrumentRange
+)
+
+// Issue is a problem Validate found in a module.  The fields not
+// relevant to its kind are -1.
+type Issue struct {
+	Kind     IssueKind
+	Position int
+	Pattern  int
+	Row      int
+	Channel  int
+	Sample   int
+
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the issue's message.
+func (i Issue) String() string { return i.Message }
+
+// Validate checks the consistency of the module's song, patterns and
+// samples, e.g. to flag broken files in an archive before they reach
+// players, and returns the problems found.  libmikmod plays most such
+// modules anyway, so the issues are warnings rather than errors.
+func (m *Module) Validate() ([]Issue, error) {
+	if err := m.usable(); err != nil {
+		return nil, err
+	}
+	var issues []Issue
+	add := func(i Issue, format string, args ...any) {
+		i.Message = fmt.Sprintf(format, args...)
+		issues = append(issues, i)
+	}
+	none := Issue{Position: -1, Pattern: -1, Row: -1, Channel: -1, Sample: -1}
+
+	numPat := m.NumPatterns()
+	for pos, pat := range m.Orders() {
+		if pat != C.LAST_PATTERN && pat >= numPat {
+			i := none
+			i.Kind, i.Position, i.Pattern = IssueMissingPattern, pos, pat
+			add(i, "position %d plays pattern %d of %d", pos, pat, numPat)
+		}
+	}
+
+	for smp, s := range m.Info().Samples {
+		if s.Looping && (s.LoopStart >= s.LoopEnd || s.LoopEnd > s.Length) {
+			i := none
+			i.Kind, i.Sample = IssueInvalidLoop, smp
+			add(i, "sample %d loops from %d to %d of %d", smp, s.LoopStart, s.LoopEnd, s.Length)
+		}
+	}
+
+	numIns := int(m.module.numsmp)
+	if m.module.flags&C.UF_INST != 0 {
+		numIns = int(m.module.numins)
+	}
+	for pat := range numPat {
+		numRows := 0
+		if m.module.pattrows != nil {
+			numRows = int(unsafe.Slice(m.module.pattrows, m.module.numpat)[pat])
+		}
+		for _, r := range m.PatternRows(pat, 0, numRows) {
+			for ch, c := range r.Cells {
+				i := none
+				i.Pattern, i.Row, i.Channel = pat, r.Row, ch
+				if c.Note >= maxValidNote {
+					i.Kind = IssueNoteRange
+					add(i, "pattern %d row %d channel %d plays note %d", pat, r.Row, ch, c.Note)
+				}
+				if c.Instrument >= numIns {
+					i.Kind = IssueInstrumentRange
+					add(i, "pattern %d row %d channel %d plays instrument %d of %d", pat, r.Row, ch, c.Instrument+1, numIns)
+				}
+			}
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,95 @@
+package mikmod
+
+/*
+#include <mikmod.h>
+*/
+import "C"
+
+// maxSampleVoices is the number of voices whose sounds are counted
+// for Voice, as the size of voicePlays in instruments.go.
+const maxSampleVoices = 256
+
+// Voice is a handle to a voice of libmikmod's mixer, as returned by
+// PlaySample and ChannelVoice.  It refers to the sound the voice
+// played when the handle was obtained: once the voice starts another
+// sound, e.g. because it was stolen for another sound effect, the
+// handle goes stale, Alive returns false and its other methods do
+// nothing.  The zero Voice is stale.
+type Voice struct {
+	index int
+	plays uint32 // voicePlays(index) when the handle was obtained, plus 1
+}
+
+// newVoice returns a handle to the sound playing on voice v.  It
+// expects to be called on the actor.
+func newVoice(v int) Voice {
+	if v < 0 || v >= maxSampleVoices {
+		return Voice{}
+	}
+	return Voice{index: v, plays: voicePlays(v) + 1}
+}
+
+// Index returns the index of the voice in libmikmod's mixer.
+func (v Voice) Index() int { return v.index }
+
+// current returns true if the voice still plays the sound the handle
+// refers to, though maybe stopped.  It expects to be called on the
+// actor.
+func (v Voice) current() bool {
+	return v.plays != 0 && voicePlays(v.index)+1 == v.plays
+}
+
+// Alive returns true if the voice is still playing the sound the
+// handle refers to, and false if the sound ended or the voice moved on
+// to another.
+func (v Voice) Alive() bool {
+	return call(func() bool { return v.current() && C.Voice_Stopped(C.SBYTE(v.index)) == 0 })
+}
+
+// with calls fn with the voice index if the handle is current.
+func (v Voice) with(fn func(C.SBYTE)) {
+	do(func() {
+		if v.current() {
+			fn(C.SBYTE(v.index))
+		}
+	})
+}
+
+// SetVolume sets the volume of the voice, between 0 and 1.
+func (v Voice) SetVolume(vol float64) {
+	v.with(func(i C.SBYTE) { C.Voice_SetVolume(i, C.UWORD(clamp(vol, 0, 1)*maxVoiceSetVolume+0.5)) })
+}
+
+// SetPanning sets the position of the voice in the stereo field, from
+// -1 for left to 1 for right.
+func (v Voice) SetPanning(pan float64) {
+	v.with(func(i C.SBYTE) { C.Voice_SetPanning(i, C.ULONG((clamp(pan, -1, 1)+1)/2*C.PAN_RIGHT+0.5)) })
+}
+
+// SetFrequency sets the playback rate of the voice, in Hz.
+func (v Voice) SetFrequency(hz int) {
+	v.with(func(i C.SBYTE) { C.Voice_SetFrequency(i, C.ULONG(hz)) })
+}
+
+// Stop stops the voice.
+func (v Voice) Stop() {
+	v.with(func(i C.SBYTE) {
+		if !voiceReserved(int(i)) {
+			C.Voice_Stop(i)
+		}
+	})
+}
+
+// ChannelVoice returns the voice playing channel ch of the playing
+// module, and false if none does.  The module's player moves channels
+// between voices, e.g. for new notes with new note actions, so the
+// handle goes stale with the note.
+func ChannelVoice(ch int) (Voice, bool) {
+	var v Voice
+	do(func() {
+		if voice := int(C.Player_GetChannelVoice(C.UBYTE(ch))); voice >= 0 {
+			v = newVoice(voice)
+		}
+	})
+	return v, v.plays != 0
+}
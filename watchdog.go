@@ -0,0 +1,78 @@
+//go:build cgo
+
+package mikmod
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is reported to the error handler when an update takes
+// longer than Options.WatchdogTimeout.
+var ErrStalled = errors.New("mikmod: update loop stalled")
+
+// updateStarted is the time at which the update loop's current update
+// started, in nanoseconds since the Unix epoch, or 0 between updates.
+var updateStarted atomic.Int64
+
+// updateSession is the session in which the update loop's current, or
+// last, update started.
+var updateSession atomic.Uint64
+
+// runningUpdateSession returns the session of the update in progress,
+// or the current session between updates.
+func runningUpdateSession() Session {
+	if updateStarted.Load() != 0 {
+		return Session(updateSession.Load())
+	}
+	return CurrentSession()
+}
+
+// watchdog checks that updates take less than timeout until finish is
+// closed, reporting ErrStalled once per stall, and restarting
+// playback after the stall if restart is set.  session is the session
+// started with the update loop; stalls are reported as errors of the
+// session the stalled update started in.
+func watchdog(finish <-chan struct{}, session Session, timeout time.Duration, restart bool) {
+	ticker := time.NewTicker(max(timeout/4, updatePeriod))
+	defer ticker.Stop()
+	var stalled int64
+	for {
+		select {
+		case <-ticker.C:
+			started := updateStarted.Load()
+			if started == 0 || started == stalled ||
+				time.Since(time.Unix(0, started)) < timeout {
+				continue
+			}
+			stalled = started
+			if s := Session(updateSession.Load()); s > session {
+				session = s
+			}
+			reportSessionError(session, ErrStalled)
+			if restart && recovering.CompareAndSwap(false, true) {
+				go restartAfterStall(finish, started, session)
+			}
+		case <-finish:
+			return
+		}
+	}
+}
+
+// restartAfterStall waits for the update that started at started to
+// return, then resets the output driver and resumes playback of
+// session, unless finish is closed first.
+func restartAfterStall(finish <-chan struct{}, started int64, session Session) {
+	ticker := time.NewTicker(updatePeriod)
+	defer ticker.Stop()
+	for updateStarted.Load() == started {
+		select {
+		case <-ticker.C:
+		case <-finish:
+			recovering.Store(false)
+			return
+		}
+	}
+	recoverPlayback(true, session)
+}
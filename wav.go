@@ -0,0 +1,121 @@
+package mikmod
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAVE format tags.
+const (
+	wavFormatPCM   = 1
+	wavFormatFloat = 3
+)
+
+// wavHeaderSize is the size of the header written by writeWAVHeader.
+const wavHeaderSize = 44
+
+// WAVStreamSize is the largest data size a WAV header can describe.
+// Pass it to WriteWAVHeader for streams of unknown length; most
+// readers then play until the data ends.
+const WAVStreamSize = math.MaxUint32 - (wavHeaderSize - 8)
+
+// WriteWAVHeader writes a canonical RIFF WAVE header describing
+// dataSize bytes of PCM data in format f, e.g. to wrap the data read
+// from a Stream.
+func WriteWAVHeader(w io.Writer, f Format, dataSize uint32) error {
+	return writeWAVHeader(w, f, dataSize)
+}
+
+// writeWAVHeader writes a canonical RIFF WAVE header describing
+// dataSize bytes of PCM data in format f.
+func writeWAVHeader(w io.Writer, f Format, dataSize uint32) error {
+	return writeWAVHeaderTrailer(w, f, dataSize, 0)
+}
+
+// writeWAVHeaderTrailer is like writeWAVHeader, but accounts for
+// trailerSize bytes of chunks following the data in the RIFF size.
+func writeWAVHeaderTrailer(w io.Writer, f Format, dataSize, trailerSize uint32) error {
+	tag := uint16(wavFormatPCM)
+	if f.IsFloat() {
+		tag = wavFormatFloat
+	}
+	var h [wavHeaderSize]byte
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], wavHeaderSize-8+dataSize+trailerSize)
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], tag)
+	binary.LittleEndian.PutUint16(h[22:], uint16(f.Channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(f.SampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(f.SampleRate*f.FrameSize()))
+	binary.LittleEndian.PutUint16(h[32:], uint16(f.FrameSize()))
+	binary.LittleEndian.PutUint16(h[34:], uint16(f.BitsPerSample))
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], dataSize)
+	_, err := w.Write(h[:])
+	return err
+}
+
+// wavTag is an entry of a LIST/INFO chunk: a four-character ID, such
+// as INAM for the title, and its value.
+type wavTag struct {
+	id, value string
+}
+
+// wavTags returns the LIST/INFO entries describing module m.
+func wavTags(m *Module) []wavTag {
+	software := "go-mikmod"
+	if major, minor, rev := Version(); major != 0 {
+		software = fmt.Sprintf("libmikmod %d.%d.%d (go-mikmod)", major, minor, rev)
+	}
+	return []wavTag{
+		{"INAM", m.Title()},
+		{"ISRF", m.Tracker()},
+		{"ICMT", m.Comment()},
+		{"ISFT", software},
+	}
+}
+
+// wavInfoChunk returns a LIST chunk of type INFO holding the tags with
+// a value, or nil if there are none.
+func wavInfoChunk(tags []wavTag) []byte {
+	var b []byte
+	for _, t := range tags {
+		if t.value == "" {
+			continue
+		}
+		b = append(b, t.id...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(t.value)+1))
+		b = append(b, t.value...)
+		b = append(b, 0)
+		if len(b)%2 != 0 {
+			b = append(b, 0)
+		}
+	}
+	if b == nil {
+		return nil
+	}
+	chunk := []byte("LIST")
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(4+len(b)))
+	chunk = append(chunk, "INFO"...)
+	return append(chunk, b...)
+}
+
+// finishWAV writes the chunks following dataSize bytes of PCM data in
+// format f, which were written to w after a provisional header, then
+// rewrites the header.
+func finishWAV(w io.WriteSeeker, f Format, dataSize uint32, trailer []byte) error {
+	if dataSize%2 != 0 {
+		trailer = append([]byte{0}, trailer...)
+	}
+	if _, err := w.Write(trailer); err != nil {
+		return err
+	}
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeWAVHeaderTrailer(w, f, dataSize, uint32(len(trailer)))
+}
@@ -0,0 +1,51 @@
+// AudioWorklet processor playing interleaved PCM chunks posted by the
+// webaudio package.  It asks for another chunk, by posting null, when
+// fewer than lowWater samples are queued, and stops once it receives
+// null back and has played everything.
+class MikModProcessor extends AudioWorkletProcessor {
+  constructor(options) {
+    super();
+    this.lowWater = options.processorOptions.lowWater;
+    this.queue = [];
+    this.offset = 0;
+    this.queued = 0;
+    this.requested = false;
+    this.ended = false;
+    this.port.onmessage = (e) => {
+      this.requested = false;
+      if (e.data === null) {
+        this.ended = true;
+        return;
+      }
+      this.queue.push(e.data);
+      this.queued += e.data.length;
+    };
+  }
+
+  process(inputs, outputs) {
+    const out = outputs[0];
+    const frames = out[0].length;
+    for (let i = 0; i < frames; i++) {
+      for (let c = 0; c < out.length; c++) {
+        let v = 0;
+        if (this.queue.length > 0) {
+          const chunk = this.queue[0];
+          v = chunk[this.offset++];
+          this.queued--;
+          if (this.offset >= chunk.length) {
+            this.queue.shift();
+            this.offset = 0;
+          }
+        }
+        out[c][i] = v;
+      }
+    }
+    if (!this.requested && !this.ended && this.queued < this.lowWater) {
+      this.requested = true;
+      this.port.postMessage(null);
+    }
+    return !this.ended || this.queue.length > 0;
+  }
+}
+
+registerProcessor("mikmod", MikModProcessor);
@@ -0,0 +1,229 @@
+//go:build js && wasm
+
+// Package webaudio plays MikMod modules in the browser, through the
+// Web Audio API, when the program is compiled with GOOS=js and
+// GOARCH=wasm.
+//
+// Modules are mixed by a mikmod.Stream, which under wasm is backed by
+// the package's pure Go ProTracker player, or by libmikmod if it was
+// compiled to wasm as well.  The mixed output is posted in chunks to
+// an AudioWorklet, which plays it on the browser's audio thread.
+package webaudio
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"syscall/js"
+
+	"github.com/death/go-mikmod"
+)
+
+// chunkFrames is the number of sample frames posted to the worklet at
+// a time.
+const chunkFrames = 4096
+
+// processorSource is the source of the AudioWorklet processor.
+//
+//go:embed processor.js
+var processorSource string
+
+// Player plays a module through Web Audio.  Remember to Close it when
+// done.
+type Player struct {
+	stream  *mikmod.Stream
+	context js.Value
+	gain    js.Value
+	node    js.Value
+	onNeed  js.Func
+
+	// need is signaled when the worklet asks for another chunk, and
+	// done is closed when the player is closed.
+	need      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// mu keeps Close from closing the stream while it is read.
+	mu sync.Mutex
+}
+
+// NewPlayer prepares to play a module through Web Audio, creating an
+// AudioContext at the stream's sample rate.  Any module currently
+// playing is stopped.  It must not be called from a JavaScript
+// callback, as it waits for the worklet to load.
+//
+// Browsers only let audio start in response to user input, so call
+// Play from an input event handler, e.g. a button's click handler.
+func NewPlayer(m *mikmod.Module) (*Player, error) {
+	stream, err := mikmod.NewStream(m, mikmod.StreamOptions{Loop: true})
+	if err != nil {
+		return nil, err
+	}
+	f := stream.Format()
+	ctor := js.Global().Get("AudioContext")
+	if ctor.IsUndefined() {
+		stream.Close()
+		return nil, errors.New("webaudio: Web Audio is not available")
+	}
+	context := ctor.New(map[string]any{"sampleRate": f.SampleRate})
+	context.Call("suspend")
+
+	if err := addModule(context); err != nil {
+		context.Call("close")
+		stream.Close()
+		return nil, err
+	}
+
+	node := js.Global().Get("AudioWorkletNode").New(context, "mikmod", map[string]any{
+		"numberOfInputs":     0,
+		"numberOfOutputs":    1,
+		"outputChannelCount": []any{f.Channels},
+		"processorOptions":   map[string]any{"lowWater": 2 * chunkFrames * f.Channels},
+	})
+	gain := context.Call("createGain")
+	node.Call("connect", gain)
+	gain.Call("connect", context.Get("destination"))
+
+	p := &Player{
+		stream:  stream,
+		context: context,
+		gain:    gain,
+		node:    node,
+		need:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	p.onNeed = js.FuncOf(func(this js.Value, args []js.Value) any {
+		select {
+		case p.need <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	node.Get("port").Set("onmessage", p.onNeed)
+	go p.feed()
+	return p, nil
+}
+
+// addModule loads the processor into context's AudioWorklet.
+func addModule(context js.Value) error {
+	blob := js.Global().Get("Blob").New(
+		[]any{processorSource},
+		map[string]any{"type": "text/javascript"})
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+	defer js.Global().Get("URL").Call("revokeObjectURL", url)
+	_, err := await(context.Get("audioWorklet").Call("addModule", url))
+	return err
+}
+
+// feed posts a chunk of mixed output to the worklet whenever it asks
+// for one, and null once the song has ended.
+func (p *Player) feed() {
+	f := p.stream.Format()
+	buf := make([]byte, chunkFrames*f.FrameSize())
+	port := p.node.Get("port")
+	for {
+		select {
+		case <-p.need:
+		case <-p.done:
+			return
+		}
+		p.mu.Lock()
+		select {
+		case <-p.done:
+			p.mu.Unlock()
+			return
+		default:
+		}
+		n, err := p.stream.Read(buf)
+		p.mu.Unlock()
+		if n > 0 {
+			chunk := float32Array(buf[:n], f)
+			port.Call("postMessage", chunk, []any{chunk.Get("buffer")})
+		}
+		if err != nil {
+			if err != io.EOF {
+				js.Global().Get("console").Call("error", "webaudio: "+err.Error())
+			}
+			port.Call("postMessage", js.Null())
+			return
+		}
+	}
+}
+
+// Play starts or resumes playback.
+func (p *Player) Play() { p.context.Call("resume") }
+
+// Pause pauses playback.
+func (p *Player) Pause() { p.context.Call("suspend") }
+
+// IsPlaying returns true if the player is playing, and false
+// otherwise.
+func (p *Player) IsPlaying() bool { return p.context.Get("state").String() == "running" }
+
+// SetVolume sets the player's volume, from 0 to 1.
+func (p *Player) SetVolume(v float64) { p.gain.Get("gain").Set("value", v) }
+
+// Close stops playback and frees the player.
+func (p *Player) Close() error {
+	err := errors.New("webaudio: player is closed")
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.node.Get("port").Set("onmessage", js.Null())
+		p.node.Call("disconnect")
+		p.context.Call("close")
+		p.onNeed.Release()
+		p.mu.Lock()
+		err = p.stream.Close()
+		p.mu.Unlock()
+	})
+	return err
+}
+
+// float32Array converts PCM data in format f to a Float32Array.
+func float32Array(b []byte, f mikmod.Format) js.Value {
+	size := f.BitsPerSample / 8
+	n := len(b) / size
+	out := make([]byte, n*4)
+	for i := range n {
+		var v float32
+		switch f.BitsPerSample {
+		case 8:
+			v = float32(int(b[i])-128) / 128
+		case 16:
+			v = float32(int16(binary.LittleEndian.Uint16(b[i*2:]))) / 32768
+		case 32:
+			v = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+		binary.LittleEndian.PutUint32(out[i*4:], math.Float32bits(v))
+	}
+	bytes := js.Global().Get("Uint8Array").New(len(out))
+	js.CopyBytesToJS(bytes, out)
+	return js.Global().Get("Float32Array").New(bytes.Get("buffer"))
+}
+
+// await waits for a promise to settle, returning its value or an
+// error if it was rejected.
+func await(promise js.Value) (js.Value, error) {
+	type result struct {
+		value js.Value
+		err   error
+	}
+	ch := make(chan result, 1)
+	then := js.FuncOf(func(this js.Value, args []js.Value) any {
+		ch <- result{value: args[0]}
+		return nil
+	})
+	defer then.Release()
+	catch := js.FuncOf(func(this js.Value, args []js.Value) any {
+		ch <- result{err: fmt.Errorf("webaudio: %s", args[0].Call("toString").String())}
+		return nil
+	})
+	defer catch.Release()
+	promise.Call("then", then, catch)
+	r := <-ch
+	return r.value, r.err
+}